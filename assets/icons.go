@@ -0,0 +1,149 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Limits for user-provided icons
+const (
+	MaxIconFileSize  = 256 * 1024
+	MaxIconDimension = 256
+)
+
+// IconSet holds the tray icon for each alert level, in ICO format
+type IconSet struct {
+	Green  []byte
+	Yellow []byte
+	Red    []byte
+}
+
+// DefaultIcons returns the embedded icon set
+func DefaultIcons() IconSet {
+	return IconSet{Green: IconGreen, Yellow: IconYellow, Red: IconRed}
+}
+
+// LoadIconSet reads green, yellow and red icons from dir, each named after
+// its level with an .ico or .png extension (e.g. green.ico, red.png). Icons
+// that are missing or invalid fall back to the embedded ones; the returned
+// error describes every icon that was skipped.
+func LoadIconSet(dir string) (IconSet, error) {
+	set := DefaultIcons()
+	if dir == "" {
+		return set, nil
+	}
+
+	var errs []error
+	for _, icon := range []struct {
+		name string
+		dst  *[]byte
+	}{
+		{"green", &set.Green},
+		{"yellow", &set.Yellow},
+		{"red", &set.Red},
+	} {
+		data, err := loadIcon(dir, icon.name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s icon: %w", icon.name, err))
+			continue
+		}
+		*icon.dst = data
+	}
+	return set, errors.Join(errs...)
+}
+
+// loadIcon reads and validates one icon, preferring .ico over .png
+func loadIcon(dir, name string) ([]byte, error) {
+	for _, ext := range []string{".ico", ".png"} {
+		path := filepath.Join(dir, name+ext)
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", path)
+		}
+		if info.Size() > MaxIconFileSize {
+			return nil, fmt.Errorf("%s is larger than %d bytes", path, MaxIconFileSize)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if ext == ".png" {
+			return pngToICO(data)
+		}
+		if err := validateICO(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("no %s.ico or %s.png in %s", name, name, dir)
+}
+
+// validateICO checks the ICO header and that every image entry lies within
+// the file
+func validateICO(data []byte) error {
+	const headerSize, entrySize = 6, 16
+	if len(data) < headerSize {
+		return errors.New("too short to be an ICO file")
+	}
+	if binary.LittleEndian.Uint16(data[0:2]) != 0 || binary.LittleEndian.Uint16(data[2:4]) != 1 {
+		return errors.New("not an ICO file")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	if count == 0 {
+		return errors.New("ICO file contains no images")
+	}
+	if len(data) < headerSize+count*entrySize {
+		return errors.New("ICO directory is truncated")
+	}
+	for i := 0; i < count; i++ {
+		entry := data[headerSize+i*entrySize:]
+		size := int64(binary.LittleEndian.Uint32(entry[8:12]))
+		offset := int64(binary.LittleEndian.Uint32(entry[12:16]))
+		if size == 0 || offset+size > int64(len(data)) {
+			return fmt.Errorf("ICO image %d lies outside the file", i)
+		}
+	}
+	return nil
+}
+
+// pngToICO validates a PNG and wraps it in a single-image ICO container,
+// which the Windows tray accepts (PNG-compressed icons need Vista or later)
+func pngToICO(data []byte) ([]byte, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PNG: %w", err)
+	}
+	if cfg.Width > MaxIconDimension || cfg.Height > MaxIconDimension {
+		return nil, fmt.Errorf("PNG is %dx%d, larger than %dx%d", cfg.Width, cfg.Height, MaxIconDimension, MaxIconDimension)
+	}
+	// Decode fully so truncated image data is caught now, not by the tray
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("invalid PNG: %w", err)
+	}
+
+	var buf bytes.Buffer
+	header := []uint16{0, 1, 1} // reserved, type icon, one image
+	binary.Write(&buf, binary.LittleEndian, header)
+	buf.WriteByte(byte(cfg.Width % 256)) // 0 means 256
+	buf.WriteByte(byte(cfg.Height % 256))
+	buf.WriteByte(0)                                    // palette size
+	buf.WriteByte(0)                                    // reserved
+	binary.Write(&buf, binary.LittleEndian, uint16(1))  // color planes
+	binary.Write(&buf, binary.LittleEndian, uint16(32)) // bits per pixel
+	binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	binary.Write(&buf, binary.LittleEndian, uint32(6+16))
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,65 @@
+package assets
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePNG(t *testing.T, path string, size int) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmbeddedIconsAreValid(t *testing.T) {
+	for name, data := range map[string][]byte{"green": IconGreen, "yellow": IconYellow, "red": IconRed} {
+		if err := validateICO(data); err != nil {
+			t.Errorf("embedded %s icon: %v", name, err)
+		}
+	}
+}
+
+func TestLoadIconSet(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "green.png"), 32)
+	writePNG(t, filepath.Join(dir, "yellow.png"), MaxIconDimension+1)
+	if err := os.WriteFile(filepath.Join(dir, "red.ico"), []byte("not an icon"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := LoadIconSet(dir)
+	if err == nil {
+		t.Error("LoadIconSet() error = nil, want errors for the oversized and invalid icons")
+	}
+	if bytes.Equal(set.Green, IconGreen) {
+		t.Error("Valid green.png was not used")
+	}
+	if err := validateICO(set.Green); err != nil {
+		t.Errorf("Converted green icon is not a valid ICO: %v", err)
+	}
+	if !bytes.Equal(set.Yellow, IconYellow) {
+		t.Error("Oversized yellow.png should fall back to the embedded icon")
+	}
+	if !bytes.Equal(set.Red, IconRed) {
+		t.Error("Invalid red.ico should fall back to the embedded icon")
+	}
+}
+
+func TestLoadIconSetWithoutDir(t *testing.T) {
+	set, err := LoadIconSet("")
+	if err != nil {
+		t.Fatalf("LoadIconSet(\"\") error = %v", err)
+	}
+	if !bytes.Equal(set.Green, IconGreen) || !bytes.Equal(set.Red, IconRed) {
+		t.Error("LoadIconSet(\"\") should return the embedded icons")
+	}
+}
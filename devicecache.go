@@ -0,0 +1,46 @@
+package main
+
+import (
+	"home-sentry/pkg/network"
+	"sync"
+	"time"
+)
+
+// deviceCacheTTL is how long a scan result is reused when the device list is
+// opened before a fresh scan runs instead
+const deviceCacheTTL = 5 * time.Minute
+
+// deviceCache holds the result of the last network scan. It has its own lock
+// because the menus read it while scanMutex only keeps scans from overlapping.
+// The returned slices are shared and must not be modified.
+type deviceCache struct {
+	mu        sync.RWMutex
+	devices   []network.NetworkDevice
+	scannedAt time.Time
+}
+
+// Store replaces the cached devices with the result of a scan finished at now
+func (c *deviceCache) Store(devices []network.NetworkDevice, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.devices = devices
+	c.scannedAt = now
+}
+
+// Fresh returns the cached devices if a scan found any less than ttl before now
+func (c *deviceCache) Fresh(now time.Time, ttl time.Duration) ([]network.NetworkDevice, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.scannedAt.IsZero() || len(c.devices) == 0 || now.Sub(c.scannedAt) >= ttl {
+		return nil, false
+	}
+	return c.devices, true
+}
+
+// Last returns the devices of the last scan, however old, and whether any
+// scan has run
+func (c *deviceCache) Last() ([]network.NetworkDevice, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.devices, !c.scannedAt.IsZero()
+}
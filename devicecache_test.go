@@ -0,0 +1,40 @@
+package main
+
+import (
+	"home-sentry/pkg/network"
+	"testing"
+	"time"
+)
+
+func TestDeviceCache(t *testing.T) {
+	var cache deviceCache
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if _, ok := cache.Fresh(start, deviceCacheTTL); ok {
+		t.Error("Fresh() before any scan = ok, want a scan")
+	}
+	if _, scanned := cache.Last(); scanned {
+		t.Error("Last() before any scan reports a scan")
+	}
+
+	devices := []network.NetworkDevice{{IP: "192.168.1.20", MAC: "aa-bb-cc-dd-ee-ff"}}
+	cache.Store(devices, start)
+	if got, ok := cache.Fresh(start.Add(deviceCacheTTL-time.Second), deviceCacheTTL); !ok || len(got) != 1 {
+		t.Errorf("Fresh() within the TTL = %v, %v, want the cached device", got, ok)
+	}
+	if _, ok := cache.Fresh(start.Add(deviceCacheTTL), deviceCacheTTL); ok {
+		t.Error("Fresh() once the TTL is over = ok, want a new scan")
+	}
+	if got, scanned := cache.Last(); !scanned || len(got) != 1 {
+		t.Errorf("Last() after the TTL = %v, %v, want the expired devices", got, scanned)
+	}
+
+	// A scan that found nothing is never fresh, but still counts as a scan
+	cache.Store(nil, start)
+	if _, ok := cache.Fresh(start, deviceCacheTTL); ok {
+		t.Error("Fresh() after an empty scan = ok, want a new scan")
+	}
+	if got, scanned := cache.Last(); !scanned || len(got) != 0 {
+		t.Errorf("Last() after an empty scan = %v, %v, want no devices", got, scanned)
+	}
+}
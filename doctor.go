@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/logger"
+	"home-sentry/pkg/network"
+	"home-sentry/pkg/sentry"
+	"home-sentry/pkg/startup"
+	"os"
+)
+
+// checkStatus is the outcome of one doctor check. Warnings point at something
+// worth fixing; failures mean Home Sentry cannot protect the machine.
+type checkStatus string
+
+const (
+	checkOK      checkStatus = "ok"
+	checkWarning checkStatus = "warning"
+	checkFailure checkStatus = "failure"
+)
+
+// doctorCheck is one line of the doctor report
+type doctorCheck struct {
+	Name   string      `json:"name"`
+	Status checkStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+// doctorReport is what `doctor --json` prints
+type doctorReport struct {
+	Version string        `json:"version"`
+	Healthy bool          `json:"healthy"` // No check failed
+	Checks  []doctorCheck `json:"checks"`
+}
+
+// runDoctor checks the installation and returns the process exit code: 1 if
+// any check failed, 0 otherwise (warnings included)
+func runDoctor(args []string) int {
+	asJSON := false
+	for _, arg := range args {
+		switch arg {
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Println("Usage: home-sentry doctor [--json]")
+			return 2
+		}
+	}
+
+	report := doctorChecks()
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to encode report:", err)
+			return 1
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("Home Sentry v%s - Doctor\n", Version)
+		fmt.Println("-------------------")
+		for _, check := range report.Checks {
+			line := fmt.Sprintf("[%-7s] %s", check.Status, check.Name)
+			if check.Detail != "" {
+				line += ": " + check.Detail
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if !report.Healthy {
+		return 1
+	}
+	return 0
+}
+
+// doctorChecks runs every health check
+func doctorChecks() doctorReport {
+	report := doctorReport{Version: Version, Healthy: true}
+	add := func(name string, status checkStatus, detail string) {
+		report.Checks = append(report.Checks, doctorCheck{Name: name, Status: status, Detail: detail})
+		if status == checkFailure {
+			report.Healthy = false
+		}
+	}
+
+	settings, err := config.Load()
+	if err != nil {
+		add("settings", checkFailure, "cannot read settings: "+err.Error())
+	} else {
+		add("settings", checkOK, config.GetSettingsPath())
+
+		if settings.HomeConfigured() {
+			add("home network", checkOK, string(settings.HomeDetection))
+		} else {
+			add("home network", checkFailure, "not set, run set-home or set-home-gateway")
+		}
+		switch {
+		case !settings.HasDeviceConfigured():
+			add("monitored device", checkFailure, fmt.Sprintf("no device set for %s detection, run set-device or choose one in the tray", settings.DetectionType))
+		case settings.DetectionType == config.DetectionTypeMAC && network.IsRandomizedMAC(settings.PhoneMAC):
+			add("monitored device", checkWarning, "randomized (private) MAC, it changes if the phone forgets the network; consider ip or mdns detection")
+		default:
+			add("monitored device", checkOK, "")
+		}
+		if settings.IsPaused {
+			add("protection", checkWarning, "paused")
+		} else {
+			add("protection", checkOK, "active")
+		}
+		switch {
+		case settings.DetectionMode != config.DetectionModeActive || network.IsElevated():
+			add("elevation", checkOK, "")
+		case settings.ARPRefresh == config.ARPRefreshDelete:
+			add("elevation", checkWarning, "not elevated, stale ARP entries cannot be cleared before each check")
+		default:
+			add("elevation", checkOK, "not elevated, the phone is probed with pings and TCP instead")
+		}
+	}
+
+	if err := network.CheckTools(); err != nil {
+		add("network tools", checkFailure, err.Error())
+	} else {
+		add("network tools", checkOK, "")
+	}
+
+	if ssid := network.GetCurrentSSID(); ssid == network.UnknownSSID {
+		add("wifi", checkWarning, "not connected")
+	} else {
+		add("wifi", checkOK, config.SanitizeDisplayString(ssid))
+	}
+
+	if err := checkLogDirWritable(); err != nil {
+		add("log directory", checkFailure, err.Error())
+	} else {
+		add("log directory", checkOK, logger.GetLogDir())
+	}
+
+	if _, err := sentry.LoadStats(); err != nil {
+		add("state file", checkWarning, fmt.Sprintf("unreadable, it will be reset: %v", err))
+	} else {
+		add("state file", checkOK, sentry.StateFilePath())
+	}
+
+	if startup.IsEnabled() {
+		add("auto-start", checkOK, "")
+	} else {
+		add("auto-start", checkWarning, "disabled, protection stops after a reboot")
+	}
+
+	return report
+}
+
+// checkLogDirWritable creates and removes a file in the log directory
+func checkLogDirWritable() error {
+	dir := logger.GetLogDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	file, err := os.CreateTemp(dir, "doctor-*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// runValidate checks the settings file without saving corrections and returns
+// the process exit code: 1 if the file is unreadable or has warnings
+func runValidate() int {
+	path := config.GetSettingsPath()
+	warnings, err := config.Validate()
+	if err != nil {
+		fmt.Printf("%s: %v\n", path, err)
+		return 1
+	}
+	if len(warnings) == 0 {
+		fmt.Printf("%s: OK\n", path)
+		return 0
+	}
+
+	fmt.Printf("%s: %d problem(s), these values would be reset on load:\n", path, len(warnings))
+	for _, warning := range warnings {
+		fmt.Println("  - " + config.RemoveControlChars(warning))
+	}
+	return 1
+}
@@ -5,168 +5,271 @@ import (
 	"home-sentry/pkg/config"
 	"home-sentry/pkg/custommenu"
 	"home-sentry/pkg/logger"
-	"home-sentry/pkg/network"
+	"home-sentry/pkg/sentry"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/widget"
 )
 
+// customMenuIndent marks submenu entries, which the popup lists inline
+const customMenuIndent = "    "
+
 var (
 	fyneApp   fyne.App
 	popupMenu *custommenu.PopupMenu
 
-	// Menu item references for dynamic updates
-	menuStatus        *custommenu.MenuItem
-	menuLocation      *custommenu.MenuItem
-	menuWiFi          *custommenu.MenuItem
-	menuPhoneMAC      *custommenu.MenuItem
-	menuVersion       *custommenu.MenuItem
-	menuPause         *custommenu.MenuItem
-	menuShutdownTimer *custommenu.MenuItem
+	// Rendered items for dynamic updates; only touched on the Fyne thread
+	fyneItems       map[*menuEntry]customItem
+	customDevices   []*menuEntry
+	statusIndicator *custommenu.StatusIndicator
 )
 
+// customItem is a rendered popup entry; indent is kept so refreshed titles
+// stay aligned under their parent
+type customItem struct {
+	item   *custommenu.MenuItem
+	indent string
+}
+
 // initFyneApp initializes the Fyne application and custom menu
 func initFyneApp() {
 	fyneApp = app.NewWithID("com.homesentry.app")
+	settings, _ := config.Load()
+	custommenu.ApplyTheme(menuTheme(settings.Theme))
 	fyneApp.Settings().SetTheme(&custommenu.CustomTheme{})
 
 	popupMenu = custommenu.NewPopupMenu(fyneApp, "Home Sentry")
-	buildCustomMenu()
+	buildCustomMenu(currentMenuState())
 }
 
-// buildCustomMenu creates all menu items
-func buildCustomMenu() {
-	settings, _ := config.Load()
-	currentSSID := network.GetCurrentSSID()
-	safeSSID := config.SanitizeDisplayString(currentSSID)
-
-	// Status info (disabled/grayed)
-	menuStatus = popupMenu.AddDisabledItem("Status: Starting...")
+// buildCustomMenu renders the menu model into the popup. Submenus are
+// listed inline below their (disabled) parent entry.
+func buildCustomMenu(state menuState) {
+	popupMenu.Clear()
+	fyneItems = make(map[*menuEntry]customItem)
 
-	locationText := "📍 Roaming"
-	if currentSSID == settings.HomeSSID && settings.HomeSSID != "" {
-		locationText = "🏠 At Home"
+	for _, entry := range menuModel {
+		addCustomEntry(entry, "", state)
+		if entry.HostsDevices {
+			for _, device := range customDevices {
+				addCustomEntry(device, customMenuIndent, state)
+			}
+		}
 	}
-	menuLocation = popupMenu.AddDisabledItem(locationText)
 
-	menuWiFi = popupMenu.AddDisabledItem(fmt.Sprintf("📶 WiFi: %s", safeSSID))
+	popupMenu.Build()
+	refreshFyneMenu(state)
+}
 
-	phoneDisplay := "Not Set"
-	if settings.PhoneMAC != "" {
-		phoneDisplay = config.SanitizeDisplayString(settings.PhoneMAC)
+func addCustomEntry(entry *menuEntry, indent string, state menuState) {
+	if entry.Separator {
+		popupMenu.AddSeparator()
+		return
 	}
-	menuPhoneMAC = popupMenu.AddDisabledItem(fmt.Sprintf("📱 Phone: %s", phoneDisplay))
 
-	menuVersion = popupMenu.AddDisabledItem(fmt.Sprintf("ℹ️ Version: %s", Version))
+	var item *custommenu.MenuItem
+	title := indent + entry.Title(state)
+	if entry.ShowsStatus {
+		item = popupMenu.AddStatusItem(title)
+		statusIndicator = item.Indicator
+	} else if entry.OnClick == nil || len(entry.Submenu) > 0 {
+		item = popupMenu.AddDisabledItem(title)
+	} else {
+		item = popupMenu.AddItem(title, func() {
+			// Handlers may block (scans, settings I/O), so keep them off the UI thread
+			go func() {
+				entry.OnClick()
+				refreshMenus()
+			}()
+		})
+	}
+	fyneItems[entry] = customItem{item: item, indent: indent}
 
-	popupMenu.AddSeparator()
+	for _, child := range entry.Submenu {
+		addCustomEntry(child, indent+customMenuIndent, state)
+	}
+}
 
-	// Actions
-	popupMenu.AddItem("🏠 Set Current WiFi as Home", func() {
-		ssid := network.GetCurrentSSID()
-		if err := config.Update(ssid, ""); err != nil {
-			logger.Error("Failed to set home SSID: %v", err)
+// refreshFyneMenu applies the current titles and visibility to the popup.
+// Must run on the Fyne thread.
+func refreshFyneMenu(state menuState) {
+	if statusIndicator != nil {
+		statusIndicator.SetStatus(statusLevel(state.SentryStatus))
+	}
+	for entry, rendered := range fyneItems {
+		rendered.item.SetText(rendered.indent + entry.Title(state))
+		if entry.Visible == nil {
+			continue
+		}
+		if entry.Visible(state) {
+			rendered.item.Show()
 		} else {
-			safeSSID := config.SanitizeDisplayString(ssid)
-			logger.Info("Home SSID set to: %s", safeSSID)
+			rendered.item.Hide()
 		}
-		updateCustomMenuDisplay()
-	})
+	}
+}
 
-	popupMenu.AddItem("📱 Select Monitored Device", func() {
-		logger.Info("Select Device clicked - scanning...")
-		devices := network.ScanNetworkDevices()
-		if len(devices) > 0 {
-			// Devices are already sanitized by ScanNetworkDevices
-			if err := config.Update("", devices[0].MAC); err != nil {
-				logger.Error("Failed to set device MAC: %v", err)
-			} else {
-				safeMAC := config.SanitizeDisplayString(devices[0].MAC)
-				logger.Info("Auto-selected first device: %s", safeMAC)
-			}
-		}
-		updateCustomMenuDisplay()
-	})
+// statusLevel maps a sentry status to the color of its tray icon
+func statusLevel(status sentry.SentryStatus) custommenu.StatusLevel {
+	switch status {
+	case sentry.StatusShutdownImminent:
+		return custommenu.StatusDanger
+	case sentry.StatusGracePeriod, sentry.StatusPaused, sentry.StatusNoNetwork, sentry.StatusWaitingForPhone, sentry.StatusCooldown:
+		return custommenu.StatusWarning
+	default:
+		return custommenu.StatusSafe
+	}
+}
 
-	popupMenu.AddSeparator()
+// menuTheme maps the theme setting to the custom menu's, dark unless set
+func menuTheme(name string) custommenu.Theme {
+	switch name {
+	case config.ThemeLight:
+		return custommenu.ThemeLight
+	case config.ThemeSystem:
+		return custommenu.ThemeSystem
+	default:
+		return custommenu.ThemeDark
+	}
+}
 
-	pauseText := "⏸️ Pause Protection"
-	if settings.IsPaused {
-		pauseText = "▶️ Resume Protection"
+// applyCustomMenuTheme recolors the popup for a newly chosen theme
+func applyCustomMenuTheme(name string) {
+	if fyneApp == nil {
+		return
 	}
-	menuPause = popupMenu.AddItem(pauseText, func() {
-		settings, _ := config.Load()
-		if settings.IsPaused {
-			config.SetPaused(false)
-			menuPause.SetText("⏸️ Pause Protection")
-			logger.Info("Protection resumed")
-		} else {
-			config.SetPaused(true)
-			menuPause.SetText("▶️ Resume Protection")
-			logger.Info("Protection paused")
-		}
+	state := currentMenuState()
+	fyne.Do(func() {
+		custommenu.ApplyTheme(menuTheme(name))
+		fyneApp.Settings().SetTheme(&custommenu.CustomTheme{})
+		// Existing items keep the colors they were rendered with
+		buildCustomMenu(state)
 	})
+}
 
-	menuShutdownTimer = popupMenu.AddItem(fmt.Sprintf("⏱ Shutdown Timer (%ds)", settings.ShutdownDelay), func() {
-		// Cycle through options: 10 -> 30 -> 60 -> 300 -> 10
-		settings, _ := config.Load()
-		var newDelay int
-		switch settings.ShutdownDelay {
-		case 10:
-			newDelay = 30
-		case 30:
-			newDelay = 60
-		case 60:
-			newDelay = 300
-		default:
-			newDelay = 10
-		}
-		config.SetShutdownDelay(newDelay)
-		menuShutdownTimer.SetText(fmt.Sprintf("⏱ Shutdown Timer (%ds)", newDelay))
-		logger.Info("Shutdown timer set to %ds", newDelay)
+// showDevicesInCustomMenu rebuilds the popup with the scanned devices listed
+// under the device entry
+func showDevicesInCustomMenu(entries []*menuEntry) {
+	if fyneApp == nil {
+		return
+	}
+	state := currentMenuState()
+	fyne.Do(func() {
+		customDevices = entries
+		buildCustomMenu(state)
 	})
+}
 
-	popupMenu.AddSeparator()
-
-	popupMenu.AddItem("❌ Quit", func() {
-		logger.Info("User requested quit from custom menu")
-		popupMenu.Hide()
-		fyneApp.Quit()
-	})
+// showManualMACDialog prompts for a device MAC address and saves it as the
+// monitored device. Useful when the phone is asleep and missing from scans.
+func showManualMACDialog() {
+	if fyneApp == nil {
+		return
+	}
 
-	popupMenu.Build()
-}
+	fyne.Do(func() {
+		w := fyneApp.NewWindow("Enter Device MAC")
 
-// updateCustomMenuDisplay updates the dynamic menu items
-func updateCustomMenuDisplay() {
-	settings, _ := config.Load()
-	currentSSID := network.GetCurrentSSID()
-	safeSSID := config.SanitizeDisplayString(currentSSID)
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("AA:BB:CC:DD:EE:FF")
+		entry.Validator = func(text string) error {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				return config.NewValidationError("MAC required", "Enter the MAC address of your phone")
+			}
+			if _, err := config.SanitizeMAC(text); err != nil {
+				return err
+			}
+			return nil
+		}
 
-	if menuLocation != nil {
-		if currentSSID == settings.HomeSSID && settings.HomeSSID != "" {
-			menuLocation.SetText("🏠 At Home")
-		} else {
-			menuLocation.SetText("📍 Roaming")
+		form := &widget.Form{
+			Items: []*widget.FormItem{
+				{Text: "MAC", Widget: entry, HintText: "AA:BB:CC:DD:EE:FF or AA-BB-CC-DD-EE-FF"},
+			},
+			SubmitText: "Save",
+			OnSubmit: func() {
+				mac := strings.TrimSpace(entry.Text)
+				if err := config.Update("", mac); err != nil {
+					logger.Error("Failed to set device MAC: %v", err)
+					entry.SetValidationError(err)
+					return
+				}
+				sanitizedMAC, _ := config.SanitizeMAC(mac)
+				logger.Info("Device MAC set manually: %s", sanitizedMAC)
+				go refreshMenus()
+				w.Close()
+			},
+			OnCancel: func() {
+				w.Close()
+			},
 		}
-	}
 
-	if menuWiFi != nil {
-		menuWiFi.SetText(fmt.Sprintf("📶 WiFi: %s", safeSSID))
+		w.SetContent(form)
+		w.Resize(fyne.NewSize(380, 120))
+		w.CenterOnScreen()
+		w.Show()
+		w.Canvas().Focus(entry)
+	})
+}
+
+// showPINDialog asks for the PIN and runs onVerified once it matches.
+// Without the Fyne app there is no way to ask, so the action is refused.
+func showPINDialog(title string, onVerified func()) {
+	if fyneApp == nil {
+		logger.Warn("%s refused: PIN required but no window available", title)
+		return
 	}
 
-	if menuPhoneMAC != nil {
-		if settings.PhoneMAC != "" {
-			safeMAC := config.SanitizeDisplayString(settings.PhoneMAC)
-			menuPhoneMAC.SetText(fmt.Sprintf("📱 Phone: %s", safeMAC))
-		} else {
-			menuPhoneMAC.SetText("📱 Phone: Not Set")
+	fyne.Do(func() {
+		w := fyneApp.NewWindow(title)
+
+		entry := widget.NewPasswordEntry()
+		entry.SetPlaceHolder("PIN")
+
+		form := &widget.Form{
+			Items: []*widget.FormItem{
+				{Text: "PIN", Widget: entry, HintText: "Enter your PIN to continue"},
+			},
+			SubmitText: "OK",
+			OnSubmit: func() {
+				settings, err := config.Load()
+				if err != nil {
+					logger.Error("Failed to load settings for PIN check: %v", err)
+					entry.SetValidationError(err)
+					return
+				}
+				if wait := pinAttempts.Wait(time.Now()); wait > 0 {
+					logger.Warn("%s refused: PIN entered during the %s wait after a wrong one", title, wait.Round(time.Second))
+					entry.SetText("")
+					entry.SetValidationError(config.NewValidationError("PIN", fmt.Sprintf("Too many wrong PINs, try again in %s", wait.Round(time.Second))))
+					return
+				}
+				if !settings.VerifyPIN(strings.TrimSpace(entry.Text)) {
+					failures, delay := pinAttempts.Fail(time.Now())
+					logger.Warn("%s: incorrect PIN entered (%d in a row), next attempt in %s", title, failures, delay)
+					entry.SetText("")
+					entry.SetValidationError(config.NewValidationError("PIN", "Incorrect PIN"))
+					return
+				}
+				pinAttempts.Succeed()
+				w.Close()
+				go onVerified()
+			},
+			OnCancel: func() {
+				w.Close()
+			},
 		}
-	}
 
-	if menuShutdownTimer != nil {
-		menuShutdownTimer.SetText(fmt.Sprintf("⏱ Shutdown Timer (%ds)", settings.ShutdownDelay))
-	}
+		w.SetContent(form)
+		w.Resize(fyne.NewSize(320, 120))
+		w.CenterOnScreen()
+		w.Show()
+		w.Canvas().Focus(entry)
+	})
 }
 
 // showCustomMenu toggles the custom popup menu
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"home-sentry/assets"
 	"home-sentry/pkg/config"
+	"home-sentry/pkg/dock"
 	"home-sentry/pkg/logger"
 	"home-sentry/pkg/network"
+	"home-sentry/pkg/paths"
 	"home-sentry/pkg/sentry"
 	"home-sentry/pkg/startup"
+	"home-sentry/pkg/update"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -22,32 +29,50 @@ import (
 var Version = "dev"
 
 var (
-	sentryManager   *sentry.SentryManager
-	mStatus         *systray.MenuItem
-	mLocation       *systray.MenuItem
-	mWiFi           *systray.MenuItem
-	mPhoneMAC       *systray.MenuItem
-	mPause          *systray.MenuItem
-	mAutoStart      *systray.MenuItem
-	mShutdownTimer  *systray.MenuItem
-	mCancelShutdown *systray.MenuItem
-	deviceSubmenus  []*systray.MenuItem
-	cachedDevices   []network.NetworkDevice
-	hasScanned      bool
-	scanMutex       sync.Mutex
-	ctx             context.Context
-	cancel          context.CancelFunc
+	sentryManager  *sentry.SentryManager
+	trayIcons      = assets.DefaultIcons()
+	trayItems      map[*menuEntry]*systray.MenuItem
+	deviceSlots    = make(map[*menuEntry][]*deviceSlot)
+	scannedDevices deviceCache
+	scanMutex      sync.Mutex
+	ctx            context.Context
+	cancel         context.CancelFunc
+
+	// User templates for the tray title and tooltip; empty uses the defaults
+	trayTitleTemplate   string
+	trayTooltipTemplate string
 )
 
 func main() {
+	// Global flags go before the command and apply to every one
+	args, verbose := parseGlobalFlags(os.Args[1:])
+	os.Args = append(os.Args[:1], args...)
+
 	// Initialize logger
 	logDir := logger.GetLogDir()
-	if err := logger.Init(logDir, logger.INFO); err != nil {
+	logLevel := logger.INFO
+	if verbose {
+		logLevel = logger.DEBUG
+	}
+	// Load errors fall back to defaults, which include the default retention
+	startupSettings, _ := config.Load()
+	if err := logger.Init(logDir, logLevel, startupSettings.LogRetention()); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 		// Continue without file logging
 	}
+	logger.SetConsoleOutput(verbose || wantsConsoleLog(os.Args[1:]))
+	if startupSettings.UseEventLog {
+		if err := logger.EnableEventLog(); err != nil {
+			logger.Warn("Not writing to the event log: %v", err)
+		}
+	}
 
 	logger.Info("Home Sentry v%s starting", Version)
+	if _, source := paths.Base(); source == "APPDATA" {
+		logger.Debug("Keeping files in %s", paths.AppDir())
+	} else {
+		logger.Warn("APPDATA is not set, keeping files in %s (base from %s)", paths.AppDir(), source)
+	}
 
 	if len(os.Args) < 2 {
 		runWithTray()
@@ -61,6 +86,8 @@ func main() {
 		runScan()
 	case "wifi":
 		runWifiScan()
+	case "peripherals":
+		runPeripherals()
 	case "status":
 		runStatus()
 	case "set-home":
@@ -69,6 +96,12 @@ func main() {
 			return
 		}
 		runSetHome(os.Args[2])
+	case "set-home-gateway":
+		mac := ""
+		if len(os.Args) > 2 {
+			mac = os.Args[2]
+		}
+		runSetHomeGateway(mac)
 	case "set-device":
 		if len(os.Args) < 3 {
 			fmt.Println("Usage: home-sentry set-device <mac>")
@@ -76,16 +109,56 @@ func main() {
 			return
 		}
 		runSetDevice(os.Args[2])
+	case "get":
+		if code := runGet(os.Args[2:]); code != 0 {
+			logger.Close()
+			os.Exit(code)
+		}
+	case "set":
+		if code := runSet(os.Args[2:]); code != 0 {
+			logger.Close()
+			os.Exit(code)
+		}
+	case "profile":
+		if code := runProfile(os.Args[2:]); code != 0 {
+			logger.Close()
+			os.Exit(code)
+		}
 	case "pause":
-		runSetPaused(true)
+		runPause(os.Args[2:])
 	case "resume":
 		runSetPaused(false)
 	case "run":
 		runWithTray()
 	case "version":
 		fmt.Printf("Home Sentry v%s\n", Version)
+		if len(os.Args) > 2 && os.Args[2] == "--check" {
+			runVersionCheck()
+		}
 	case "logs":
 		runShowLogs()
+	case "uninstall":
+		runUninstall(os.Args[2:])
+	case "doctor":
+		if code := runDoctor(os.Args[2:]); code != 0 {
+			logger.Close()
+			os.Exit(code)
+		}
+	case "validate":
+		if code := runValidate(); code != 0 {
+			logger.Close()
+			os.Exit(code)
+		}
+	case "--simulate", "simulate":
+		scenario := ""
+		if len(os.Args) > 2 {
+			scenario = os.Args[2]
+		}
+		runSimulate(scenario)
+	case "rehearse":
+		runRehearse()
+	case "watch":
+		runWatch()
 	default:
 		printHelp()
 	}
@@ -102,13 +175,16 @@ func runWithTray() {
 	go func() {
 		sig := <-sigChan
 		logger.Info("Received signal %v, shutting down", sig)
-		cancel()
+		shutdown()
 		if fyneApp != nil {
 			fyneApp.Quit()
 		}
 		systray.Quit()
 	}()
 
+	// Both the tray and the custom menu render from the same model
+	menuModel = buildMenuModel()
+
 	// Initialize Fyne app and custom menu
 	initFyneApp()
 
@@ -119,396 +195,518 @@ func runWithTray() {
 }
 
 func onReady() {
-	systray.SetIcon(assets.IconGreen)
+	settings, settingsWarnings, _ := config.LoadWithWarnings()
+	icons, err := assets.LoadIconSet(settings.IconPackDir)
+	if err != nil {
+		logger.Warn("Using built-in icons where the icon pack is unusable: %v", err)
+	}
+	trayIcons = icons
+	trayTitleTemplate = settings.TrayTitleTemplate
+	trayTooltipTemplate = settings.TrayTooltipTemplate
+
+	systray.SetIcon(trayIcons.Green)
 	systray.SetTitle("Home Sentry")
 	systray.SetTooltip("Home Sentry - Click to open menu")
 
 	// Note: We still add a minimal native menu as backup
 	// but the primary interaction is via the Fyne popup window
 
-	settings, _ := config.Load()
 	currentSSID := network.GetCurrentSSID()
 
 	sanitizedCurrentSSID, _ := config.SanitizeSSID(currentSSID)
 	sanitizedHomeSSID, _ := config.SanitizeSSID(settings.HomeSSID)
 	sanitizedPhoneMAC, _ := config.SanitizeMAC(settings.PhoneMAC)
 	logger.Info("Tray ready. SSID: %s, Home: %s, Phone MAC: %s", sanitizedCurrentSSID, sanitizedHomeSSID, sanitizedPhoneMAC)
-
-	// Status info
-	mStatus = systray.AddMenuItem("Status: Starting...", "Current status")
-	mStatus.Disable()
-
-	// Location status (At Home / Roaming)
-	locationText := "📍 Roaming"
-	if currentSSID == settings.HomeSSID && settings.HomeSSID != "" {
-		locationText = "🏠 At Home"
+	if settings.DetectionType == config.DetectionTypeMAC && network.IsRandomizedMAC(sanitizedPhoneMAC) {
+		logger.Warn("Device MAC %s is randomized and may change, consider IP or mDNS detection", sanitizedPhoneMAC)
 	}
-	mLocation = systray.AddMenuItem(locationText, "Current location")
-	mLocation.Disable()
 
-	safeCurrentSSID := config.SanitizeDisplayString(currentSSID)
-	mWiFi = systray.AddMenuItem(fmt.Sprintf("📶 WiFi: %s", safeCurrentSSID), "Current WiFi network")
-	mWiFi.Disable()
-
-	phoneDisplay := "Not Set"
-	if settings.PhoneMAC != "" {
-		phoneDisplay = config.SanitizeDisplayString(settings.PhoneMAC)
+	trayItems = make(map[*menuEntry]*systray.MenuItem)
+	for _, entry := range menuModel {
+		addTrayEntry(nil, entry)
 	}
-	mPhoneMAC = systray.AddMenuItem(fmt.Sprintf("📱 Phone: %s", phoneDisplay), "Monitored device MAC")
-	mPhoneMAC.Disable()
-
-	mVersion := systray.AddMenuItem(fmt.Sprintf("ℹ️ Version: %s", Version), "Application version")
-	mVersion.Disable()
 
-	systray.AddSeparator()
+	// Start sentry in background
+	sentryManager = sentry.NewSentryManager()
+	sentryManager.SetStatusCallback(onStatusChange)
+	go sentryManager.StartMonitor()
+	go runUpdateChecker(ctx)
+	go runAutoStartChecker(ctx)
+	go runBackgroundScanner(ctx)
+	reportSettingsWarnings(settingsWarnings)
 
-	// Actions
-	mSetHome := systray.AddMenuItem("🏠 Set Current WiFi as Home", "Use current network as home")
-	mSelectDevice := systray.AddMenuItem("📱 Select Monitored Device", "Choose device from network")
-	mScanDevices := mSelectDevice.AddSubMenuItem("🔄 Scan Network...", "Refresh network device list")
+	refreshMenus()
 
 	// Start auto-scan in background
 	go func() {
 		// Wait a moment for tray to settle
 		time.Sleep(1 * time.Second)
-		scanAndPopulateDevices(mSelectDevice, false)
+		scanAndPopulateDevices(false)
 	}()
 
-	systray.AddSeparator()
-
-	mPause = systray.AddMenuItem("⏸️ Pause Protection", "Temporarily disable protection")
-
-	// Auto-start toggle
-	autoStartText := "🚀 Enable Auto-Start"
-	if startup.IsEnabled() {
-		autoStartText = "✅ Auto-Start Enabled"
-	}
-	mAutoStart = systray.AddMenuItem(autoStartText, "Start Home Sentry when Windows starts")
-
-	mShutdownTimer = systray.AddMenuItem("⏱ Shutdown Timer", "Set delay before shutdown")
-	setupShutdownTimerMenu()
-
-	mCancelShutdown = systray.AddMenuItem("⚠️ Cancel Shutdown", "Cancel pending shutdown")
-	mCancelShutdown.Hide()
-
-	systray.AddSeparator()
-	mQuit := systray.AddMenuItem("❌ Quit", "Exit Home Sentry")
-
-	// Start sentry in background
-	sentryManager = sentry.NewSentryManager()
-	sentryManager.SetStatusCallback(onStatusChange)
-	go sentryManager.StartMonitor()
-
-	// Handle menu clicks
+	// Update display periodically
 	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-mSetHome.ClickedCh:
-				ssid := network.GetCurrentSSID()
-				if err := config.Update(ssid, ""); err != nil {
-					logger.Error("Failed to set home SSID: %v", err)
-				} else {
-					sanitizedSSID, _ := config.SanitizeSSID(ssid)
-					logger.Info("Home SSID set to: %s", sanitizedSSID)
-				}
-				updateInfoDisplay()
-			case <-mScanDevices.ClickedCh:
-				scanAndPopulateDevices(mSelectDevice, true)
-			case <-mPause.ClickedCh:
-				settings, _ := config.Load()
-				if settings.IsPaused {
-					config.SetPaused(false)
-					mPause.SetTitle("⏸️ Pause Protection")
-					logger.Info("Protection resumed")
-				} else {
-					config.SetPaused(true)
-					mPause.SetTitle("▶️ Resume Protection")
-					logger.Info("Protection paused")
-				}
-			case <-mAutoStart.ClickedCh:
-				enabled, err := startup.Toggle()
-				if err != nil {
-					logger.Error("Failed to toggle auto-start: %v", err)
-				} else {
-					if enabled {
-						mAutoStart.SetTitle("✅ Auto-Start Enabled")
-						logger.Info("Auto-start enabled")
-					} else {
-						mAutoStart.SetTitle("🚀 Enable Auto-Start")
-						logger.Info("Auto-start disabled")
-					}
-				}
-			case <-mCancelShutdown.ClickedCh:
-				if sentryManager.CancelShutdown() {
-					mCancelShutdown.Hide()
-					if mStatus != nil {
-						mStatus.SetTitle("Status: Shutdown Cancelled")
-					}
-					logger.Info("Shutdown cancelled by user")
-				}
-			case <-mQuit.ClickedCh:
-				logger.Info("User requested quit")
-				systray.Quit()
-
-			// Handle clicks on informational items (just logger debug)
-			case <-mStatus.ClickedCh:
-				logger.Debug("Status clicked")
-			case <-mLocation.ClickedCh:
-				logger.Debug("Location clicked")
-			case <-mWiFi.ClickedCh:
-				logger.Debug("WiFi clicked")
-			case <-mPhoneMAC.ClickedCh:
-				logger.Debug("Phone MAC clicked")
-			case <-mVersion.ClickedCh:
-				logger.Debug("Version clicked")
+			case <-ticker.C:
+				refreshMenus()
 			}
 		}
 	}()
+}
+
+// addTrayEntry renders a menu entry (and its submenu) into the native tray
+// menu, under parent when it is non-nil
+func addTrayEntry(parent *systray.MenuItem, entry *menuEntry) *systray.MenuItem {
+	if entry.Separator {
+		// The tray library only supports top-level separators
+		if parent == nil {
+			systray.AddSeparator()
+		}
+		return nil
+	}
+
+	var item *systray.MenuItem
+	if parent == nil {
+		item = systray.AddMenuItem("", entry.Tooltip)
+	} else {
+		item = parent.AddSubMenuItem("", entry.Tooltip)
+	}
+	trayItems[entry] = item
+
+	for _, child := range entry.Submenu {
+		addTrayEntry(item, child)
+	}
+
+	if entry.OnClick == nil {
+		if len(entry.Submenu) == 0 && !entry.HostsDevices {
+			item.Disable()
+		}
+		return item
+	}
 
-	// Update display periodically
 	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
-				updateInfoDisplay()
+			case <-item.ClickedCh:
+				entry.OnClick()
+				refreshMenus()
 			}
 		}
 	}()
+	return item
 }
 
-func updateInfoDisplay() {
-	settings, _ := config.Load()
-	currentSSID := network.GetCurrentSSID()
-	safeSSID := config.SanitizeDisplayString(currentSSID)
-
-	// Update location status
-	if mLocation != nil {
-		if currentSSID == settings.HomeSSID && settings.HomeSSID != "" {
-			mLocation.SetTitle("🏠 At Home")
+// refreshTrayMenu applies the current titles and visibility to the tray menu
+func refreshTrayMenu(state menuState) {
+	for entry, item := range trayItems {
+		item.SetTitle(entry.Title(state))
+		if entry.Visible == nil {
+			continue
+		}
+		if entry.Visible(state) {
+			item.Show()
 		} else {
-			mLocation.SetTitle("📍 Roaming")
+			item.Hide()
 		}
 	}
+}
 
-	if mWiFi != nil {
-		mWiFi.SetTitle(fmt.Sprintf("📶 WiFi: %s", safeSSID))
+// scanAndPopulateDevices lists the network's devices in both menus, from the
+// cache unless forceRefresh is set or the cache is older than deviceCacheTTL.
+// It returns at once if a scan is already running, so repeated clicks can't
+// queue up sweeps; that scan fills the menus and the status line.
+func scanAndPopulateDevices(forceRefresh bool) {
+	if !scanMutex.TryLock() {
+		logger.Info("Network scan already running, not starting another")
+		menuMu.Lock()
+		scanAwaited = true
+		menuMu.Unlock()
+		setStatusText("⏳ Scan already running...")
+		return
 	}
-	if mPhoneMAC != nil {
-		if settings.PhoneMAC != "" {
-			safeMAC := config.SanitizeDisplayString(settings.PhoneMAC)
-			mPhoneMAC.SetTitle(fmt.Sprintf("📱 Phone: %s", safeMAC))
-		} else {
-			mPhoneMAC.SetTitle("📱 Phone: Not Set")
-		}
+	defer scanMutex.Unlock()
+	// This scan sets the status line itself, answering any waiting click
+	defer takeScanAwaited()
+
+	// Use the cache if it is recent enough and no refresh was asked for
+	if devices, ok := scannedDevices.Fresh(time.Now(), deviceCacheTTL); ok && !forceRefresh {
+		logger.Info("Using cached network devices")
+		populateDeviceMenus(devices)
+		return
 	}
 
-	if mShutdownTimer != nil {
-		mShutdownTimer.SetTitle(fmt.Sprintf("⏱ Shutdown Timer (%ds)", settings.ShutdownDelay))
+	hideDeviceSlots()
+	setStatusText("⏳ Scanning network...")
+	logger.Info("Starting network scan (force=%v)", forceRefresh)
+
+	settings, _ := config.Load()
+	devices := scanDevices(settings)
+	populateDeviceMenus(devices)
+}
+
+// scanDevices scans the network and updates the device cache, the monitor's
+// snapshot and the new-device tracking. Callers hold scanMutex.
+func scanDevices(settings config.Settings) []network.NetworkDevice {
+	devices := network.ScanNetworkDevices(network.ScanOptionsFromSettings(settings))
+	scannedDevices.Store(devices, time.Now())
+
+	// Only the home network's devices are counted as home and tracked for
+	// new-device alerts
+	if sentryManager != nil && onHomeNetwork(settings) {
+		sentryManager.RecordScan(devices, time.Now())
+		sentryManager.ObserveDevices(devices, settings.AlertOnNewDevice)
 	}
 
-	if sentryManager != nil && mCancelShutdown != nil {
-		if sentryManager.IsShutdownPending() {
-			mCancelShutdown.Show()
+	logger.Info("Found %d devices", len(devices))
+	return devices
+}
+
+// runBackgroundScanner refreshes the device inventory every
+// BackgroundScanInterval minutes while that is enabled, so the tray list is
+// current and new devices are noticed without opening the menu. Foreign
+// networks are only scanned when BackgroundScanAway allows it.
+func runBackgroundScanner(ctx context.Context) {
+	wait := time.Minute
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		// Re-check every minute while disabled, so enabling takes effect
+		wait = time.Minute
+		settings, err := config.Load()
+		if err != nil || settings.BackgroundScanInterval == 0 {
+			continue
+		}
+		wait = time.Duration(settings.BackgroundScanInterval) * time.Minute
+		if !settings.BackgroundScanAway && !onHomeNetwork(settings) {
+			logger.Debug("Skipping background scan away from home")
+			continue
+		}
+
+		// A scan the user started is just as current
+		if !scanMutex.TryLock() {
+			continue
+		}
+		logger.Info("Starting background network scan")
+		devices := scanDevices(settings)
+		// Answer a click that came in while this scan ran; otherwise the
+		// status line keeps showing the monitor's status
+		if takeScanAwaited() {
+			populateDeviceMenus(devices)
 		} else {
-			mCancelShutdown.Hide()
+			showDeviceEntries(devices)
 		}
+		scanMutex.Unlock()
 	}
 }
 
-func setupShutdownTimerMenu() {
-	delays := []struct {
-		Seconds int
-		Label   string
-	}{
-		{10, "10 Seconds"},
-		{30, "30 Seconds"},
-		{60, "1 Minute"},
-		{300, "5 Minutes"},
-	}
+// takeScanAwaited reports whether a scan click is waiting for the running
+// scan and clears the mark
+func takeScanAwaited() bool {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+	awaited := scanAwaited
+	scanAwaited = false
+	return awaited
+}
 
-	for _, d := range delays {
-		m := mShutdownTimer.AddSubMenuItem(d.Label, fmt.Sprintf("Wait %s before shutdown", d.Label))
-		go func(val int, m *systray.MenuItem) {
-			for range m.ClickedCh {
-				config.SetShutdownDelay(val)
-				updateInfoDisplay()
-			}
-		}(d.Seconds, m)
+// onHomeNetwork reports whether the machine is on the home network, by SSID
+// or by gateway MAC depending on the home detection setting
+func onHomeNetwork(settings config.Settings) bool {
+	if settings.HomeDetection == config.HomeDetectionGateway {
+		gateway, _ := config.SanitizeMAC(network.GetDefaultGatewayMAC())
+		return settings.HomeGatewayMAC != "" && gateway == settings.HomeGatewayMAC
 	}
+	return settings.HomeSSID != "" && network.GetCurrentSSID() == settings.HomeSSID
 }
 
-func scanAndPopulateDevices(parentMenu *systray.MenuItem, forceRefresh bool) {
-	scanMutex.Lock()
-	defer scanMutex.Unlock()
+// populateDeviceMenus lists devices under the device entry of both menus
+func populateDeviceMenus(devices []network.NetworkDevice) {
+	shown := showDeviceEntries(devices)
 
-	// Use cache if available and not forced
-	if !forceRefresh && hasScanned && len(cachedDevices) > 0 {
-		logger.Info("Using cached network devices")
-		populateDeviceMenu(parentMenu, cachedDevices)
-		return
+	if shown == 0 {
+		setStatusText("Status: No devices found")
+	} else {
+		setStatusText(fmt.Sprintf("Found %d devices - select one", shown))
 	}
+}
 
-	// Helper to clear menu
-	for _, item := range deviceSubmenus {
-		item.Hide()
+// visibleDevices drops multicast addresses, the router and the settings'
+// ScanIgnoreList from the device list, unless Show All Devices is on. The
+// router stays listed while home is recognized by its MAC.
+func visibleDevices(devices []network.NetworkDevice) []network.NetworkDevice {
+	menuMu.Lock()
+	showAll := showAllDevices
+	menuMu.Unlock()
+	if showAll {
+		return devices
 	}
-	deviceSubmenus = nil
-
-	if mStatus != nil {
-		mStatus.SetTitle("⏳ Scanning network...")
+	settings, _ := config.Load()
+	gatewayMAC := ""
+	if settings.HomeDetection != config.HomeDetectionGateway {
+		gatewayMAC = network.GetDefaultGatewayMAC()
 	}
-	logger.Info("Starting network scan (force=%v)", forceRefresh)
+	return network.FilterDevices(devices, settings.ScanIgnoreList, gatewayMAC)
+}
 
-	devices := network.ScanNetworkDevices()
-	cachedDevices = devices
-	hasScanned = true
+// deviceSlot is a tray item that shows one device entry. systray can't
+// remove items, so the slots are reused from scan to scan, retitled or
+// hidden, instead of adding items every time.
+type deviceSlot struct {
+	item  *systray.MenuItem
+	mu    sync.Mutex
+	entry *menuEntry // nil while hidden
+}
 
-	logger.Info("Found %d devices", len(devices))
-	populateDeviceMenu(parentMenu, devices)
+func newDeviceSlot(parent *systray.MenuItem) *deviceSlot {
+	slot := &deviceSlot{item: parent.AddSubMenuItem("", "")}
+	go func() {
+		for range slot.item.ClickedCh {
+			slot.mu.Lock()
+			entry := slot.entry
+			slot.mu.Unlock()
+			if entry != nil && entry.OnClick != nil {
+				entry.OnClick()
+				refreshMenus()
+			}
+		}
+	}()
+	return slot
 }
 
-func populateDeviceMenu(parentMenu *systray.MenuItem, devices []network.NetworkDevice) {
-	// Clear previous device entries (again, to be safe if called from cache path)
-	for _, item := range deviceSubmenus {
-		item.Hide()
+// show makes the slot display entry
+func (d *deviceSlot) show(entry *menuEntry) {
+	d.mu.Lock()
+	d.entry = entry
+	d.mu.Unlock()
+	d.item.SetTitle(entry.Title(menuState{}))
+	d.item.SetTooltip(entry.Tooltip)
+	if entry.OnClick == nil {
+		d.item.Disable()
+	} else {
+		d.item.Enable()
 	}
-	deviceSubmenus = nil
+	d.item.Show()
+}
 
-	if len(devices) == 0 {
-		noDevices := parentMenu.AddSubMenuItem("❌ No devices found", "Try again or check WiFi connection")
-		noDevices.Disable()
-		deviceSubmenus = append(deviceSubmenus, noDevices)
-		if mStatus != nil {
-			mStatus.SetTitle("Status: No devices found")
+func (d *deviceSlot) hide() {
+	d.mu.Lock()
+	d.entry = nil
+	d.mu.Unlock()
+	d.item.Hide()
+}
+
+// hideDeviceSlots clears the device lists of the tray menu
+func hideDeviceSlots() {
+	for _, slots := range deviceSlots {
+		for _, slot := range slots {
+			slot.hide()
 		}
-		return
 	}
+}
 
-	// Add header showing device count
-	header := parentMenu.AddSubMenuItem(fmt.Sprintf("── Found %d devices ──", len(devices)), "")
-	header.Disable()
-	deviceSubmenus = append(deviceSubmenus, header)
+// showDeviceEntries replaces the device lists in both menus and returns how
+// many devices it listed. Callers hold scanMutex.
+func showDeviceEntries(devices []network.NetworkDevice) int {
+	visible := visibleDevices(devices)
+	entries := deviceEntries(visible, len(devices)-len(visible))
+	for _, host := range menuModel {
+		parent := trayItems[host]
+		if !host.HostsDevices || parent == nil {
+			continue
+		}
+		slots := deviceSlots[host]
+		for i, entry := range entries {
+			if i == len(slots) {
+				slots = append(slots, newDeviceSlot(parent))
+			}
+			slots[i].show(entry)
+		}
+		for _, slot := range slots[len(entries):] {
+			slot.hide()
+		}
+		deviceSlots[host] = slots
+	}
+	showDevicesInCustomMenu(entries)
+	return len(visible)
+}
 
-	for _, device := range devices {
-		// Sanitize all device fields before display
-		safeIP := config.SanitizeDisplayString(device.IP)
-		safeMAC := config.SanitizeDisplayString(device.MAC)
-		safeVendor := config.SanitizeDisplayString(device.Vendor)
-		safeHostname := config.SanitizeDisplayString(device.Hostname)
+func onStatusChange(change sentry.StatusChange) {
+	safeSSID := config.SanitizeDisplayString(change.SSID)
+	safeDevice := config.SanitizeDisplayString(change.Device)
 
-		// Format: "IP / MAC / Vendor" (include Hostname if known)
-		var label string
-		if device.Hostname != "Unknown" && device.Hostname != "" {
-			label = fmt.Sprintf("📱 %s (%s) / %s / %s", safeHostname, safeIP, safeMAC, safeVendor)
-		} else {
-			label = fmt.Sprintf("📱 %s / %s / %s", safeIP, safeMAC, safeVendor)
-		}
+	logger.Debug("Status changed to: %s", change.Status)
 
-		// Tooltip shows detailed info
-		tooltip := fmt.Sprintf("Click to monitor • IP: %s\nMAC: %s\nVendor: %s\nHostname: %s",
-			safeIP, safeMAC, safeVendor, safeHostname)
+	var icon []byte
+	var title, tooltip, label, statusText string
+	switch change.Status {
+	case sentry.StatusMonitoring:
+		icon, title, label = trayIcons.Green, "🟢", "Safe"
+		tooltip = fmt.Sprintf("Home Sentry - Safe\nWiFi: %s\nPhone: %s", safeSSID, safeDevice)
+		statusText = "Status: Safe 🟢"
+	case sentry.StatusGracePeriod:
+		icon, title, label = trayIcons.Yellow, "🟡", "Warning"
+		tooltip = fmt.Sprintf("Home Sentry - WARNING\nPhone not detected! (check %d)\nWiFi: %s", change.GraceCount, safeSSID)
+		statusText = "Status: Warning 🟡"
+	case sentry.StatusShutdownImminent:
+		icon, title, label = trayIcons.Red, "🔴", "Shutdown"
+		tooltip = "Home Sentry - DANGER\nShutdown imminent!"
+		statusText = "Status: SHUTDOWN 🔴"
+	case sentry.StatusPaused:
+		icon, title, label = trayIcons.Yellow, "⏸", "Paused"
+		tooltip = fmt.Sprintf("Home Sentry - Paused\nProtection disabled\nWiFi: %s", safeSSID)
+		statusText = "Status: Paused ⏸"
+	case sentry.StatusNoNetwork:
+		icon, title, label = trayIcons.Yellow, "📵", "No WiFi"
+		tooltip = "Home Sentry - No Network\nWiFi is disabled or disconnected"
+		statusText = "Status: No WiFi 📵"
+	case sentry.StatusCooldown:
+		icon, title, label = trayIcons.Yellow, "⏳", "Cooldown"
+		tooltip = fmt.Sprintf("Home Sentry - Cooldown\nPhone not detected, shutdown held after a recent one\nWiFi: %s", safeSSID)
+		statusText = "Status: Cooldown ⏳"
+	case sentry.StatusDocked:
+		icon, title, label = trayIcons.Green, "🔌", "Docked"
+		tooltip = fmt.Sprintf("Home Sentry - Docked\nA known peripheral is attached, protection disarmed\nWiFi: %s", safeSSID)
+		statusText = "Status: Docked 🔌"
+	case sentry.StatusWaitingForPhone:
+		icon, title, label = trayIcons.Yellow, "📱", "Waiting"
+		tooltip = fmt.Sprintf("Home Sentry - Waiting\nWaiting for phone...\nWiFi: %s", safeSSID)
+		statusText = "Status: Waiting for Phone 📱"
+	default:
+		icon, title, label = trayIcons.Green, "🌐", "Roaming"
+		tooltip = fmt.Sprintf("Home Sentry - Roaming\nWiFi: %s", safeSSID)
+		statusText = "Status: Roaming"
+	}
 
-		deviceItem := parentMenu.AddSubMenuItem(label, tooltip)
-		deviceSubmenus = append(deviceSubmenus, deviceItem)
+	if trayTitleTemplate != "" {
+		title = renderTrayTemplate(trayTitleTemplate, change, label)
+	}
+	if trayTooltipTemplate != "" {
+		tooltip = renderTrayTemplate(trayTooltipTemplate, change, label)
+	}
+	systray.SetIcon(icon)
+	systray.SetTooltip(tooltip)
+	systray.SetTitle(title)
+
+	menuMu.Lock()
+	lastStatus = change.Status
+	menuMu.Unlock()
+	setStatusText(statusText)
+}
 
-		// Capture values for the goroutine
-		deviceMAC := device.MAC
-		deviceHostname := device.Hostname
-		if deviceHostname == "Unknown" || deviceHostname == "" {
-			deviceHostname = device.IP
-		}
+// renderTrayTemplate fills in a user's tray title or tooltip template. Only
+// the known placeholders are replaced, so the template can't inject anything.
+func renderTrayTemplate(template string, change sentry.StatusChange, label string) string {
+	return strings.NewReplacer(
+		"{ssid}", config.SanitizeDisplayString(change.SSID),
+		"{device}", config.SanitizeDisplayString(change.Device),
+		"{status}", label,
+		"{grace}", strconv.Itoa(change.GraceCount),
+	).Replace(template)
+}
 
-		go func(mac string, name string, item *systray.MenuItem) {
-			for range item.ClickedCh {
-				if err := config.Update("", mac); err != nil {
-					logger.Error("Failed to set device MAC: %v", err)
-				} else {
-					sanitizedMAC, _ := config.SanitizeMAC(mac)
-					sanitizedName, _ := config.SanitizeSSID(name)
-					logger.Info("Device MAC set to: %s (%s)", sanitizedMAC, sanitizedName)
-				}
-				updateInfoDisplay()
-				if mStatus != nil {
-					safeName := config.SanitizeDisplayString(name)
-					mStatus.SetTitle(fmt.Sprintf("✅ Monitoring: %s", safeName))
-				}
-			}
-		}(deviceMAC, deviceHostname, deviceItem)
+// describeSettingsError turns a config error into a message that tells the
+// user whether the value was rejected or the settings file could not be saved.
+func describeSettingsError(err error) string {
+	switch {
+	case errors.Is(err, config.ErrValidation):
+		return "Invalid value: " + err.Error()
+	case errors.Is(err, config.ErrIO):
+		return "Could not read or write the settings file (is the disk full?): " + err.Error()
+	case errors.Is(err, config.ErrEncryption), errors.Is(err, config.ErrDecryption):
+		return "Settings encryption error: " + err.Error()
+	default:
+		return "Error saving settings: " + err.Error()
 	}
+}
 
-	if mStatus != nil {
-		mStatus.SetTitle(fmt.Sprintf("Found %d devices - select one", len(devices)))
+// reportSettingsError logs a failed settings change from the tray and shows a
+// notification, distinguishing rejected input from disk or encryption failures.
+func reportSettingsError(action string, err error) {
+	logger.Error("%s: %v", action, err)
+	if sentryManager == nil {
+		return
 	}
+	title := "Home Sentry - Settings Not Saved"
+	if errors.Is(err, config.ErrValidation) {
+		title = "Home Sentry - Invalid Setting"
+	}
+	sentryManager.Notify(title, describeSettingsError(err))
 }
 
-func onStatusChange(status sentry.SentryStatus) {
-	settings, _ := config.Load()
-	currentSSID := network.GetCurrentSSID()
-	safeSSID := config.SanitizeDisplayString(currentSSID)
-	safeMAC := config.SanitizeDisplayString(settings.PhoneMAC)
+func onExit() {
+	shutdown()
+}
 
-	logger.Debug("Status changed to: %s", status)
+// shutdownDeadline bounds how long exit waits for state to be persisted
+const shutdownDeadline = 3 * time.Second
 
-	switch status {
-	case sentry.StatusMonitoring:
-		systray.SetIcon(assets.IconGreen)
-		systray.SetTooltip(fmt.Sprintf("Home Sentry - Safe\nWiFi: %s\nPhone MAC: %s", safeSSID, safeMAC))
-		systray.SetTitle("🟢")
-		if mStatus != nil {
-			mStatus.SetTitle("Status: Safe 🟢")
-		}
-	case sentry.StatusGracePeriod:
-		systray.SetIcon(assets.IconYellow)
-		systray.SetTooltip(fmt.Sprintf("Home Sentry - WARNING\nPhone not detected!\nWiFi: %s", safeSSID))
-		systray.SetTitle("🟡")
-		if mStatus != nil {
-			mStatus.SetTitle("Status: Warning 🟡")
-		}
-	case sentry.StatusShutdownImminent:
-		systray.SetIcon(assets.IconRed)
-		systray.SetTooltip("Home Sentry - DANGER\nShutdown imminent!")
-		systray.SetTitle("🔴")
-		if mStatus != nil {
-			mStatus.SetTitle("Status: SHUTDOWN 🔴")
-		}
-		if mCancelShutdown != nil {
-			mCancelShutdown.Show()
-		}
-	case sentry.StatusPaused:
-		systray.SetIcon(assets.IconYellow)
-		systray.SetTooltip(fmt.Sprintf("Home Sentry - Paused\nProtection disabled\nWiFi: %s", safeSSID))
-		systray.SetTitle("⏸")
-		if mStatus != nil {
-			mStatus.SetTitle("Status: Paused ⏸")
+var shutdownOnce sync.Once
+
+// shutdown stops background work, persists the sentry state and closes the
+// log file. Both the signal handler and onExit call it; only the first call
+// does anything.
+func shutdown() {
+	shutdownOnce.Do(func() {
+		logger.Info("Home Sentry shutting down")
+		if cancel != nil {
+			cancel()
 		}
-	case sentry.StatusWaitingForPhone:
-		systray.SetIcon(assets.IconYellow)
-		systray.SetTooltip(fmt.Sprintf("Home Sentry - Waiting\nWaiting for phone...\nWiFi: %s", safeSSID))
-		systray.SetTitle("📱")
-		if mStatus != nil {
-			mStatus.SetTitle("Status: Waiting for Phone 📱")
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if sentryManager != nil {
+				sentryManager.SaveState()
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(shutdownDeadline):
+			logger.Warn("Timed out persisting state during shutdown")
 		}
-	default:
-		systray.SetIcon(assets.IconGreen)
-		systray.SetTooltip(fmt.Sprintf("Home Sentry - Roaming\nWiFi: %s", safeSSID))
-		systray.SetTitle("🌐")
-		if mStatus != nil {
-			mStatus.SetTitle("Status: Roaming")
+
+		logger.Close()
+	})
+}
+
+// wantsConsoleLog reports whether log lines should also be printed. The tray
+// build has no console, and JSON output must not be mixed with log lines.
+func wantsConsoleLog(args []string) bool {
+	if len(args) == 0 || args[0] == "run" {
+		return false
+	}
+	for _, arg := range args {
+		if arg == "--json" {
+			return false
 		}
 	}
+	return true
 }
 
-func onExit() {
-	logger.Info("Home Sentry shutting down")
-	if cancel != nil {
-		cancel()
+// parseGlobalFlags removes -v/--verbose from the arguments before the
+// command and reports whether it was given. Later ones belong to the command,
+// e.g. a setting value.
+func parseGlobalFlags(args []string) (rest []string, verbose bool) {
+	for i, arg := range args {
+		switch arg {
+		case "-v", "--verbose":
+			verbose = true
+		default:
+			return args[i:], verbose
+		}
 	}
+	return nil, verbose
 }
 
 func printHelp() {
@@ -517,19 +715,244 @@ func printHelp() {
 	fmt.Println("  (no args)         Start with system tray")
 	fmt.Println("  scan              Scan local network for devices")
 	fmt.Println("  wifi              Scan for available WiFi networks")
+	fmt.Println("  peripherals       List attached monitors and USB devices, for disarm_when_docked_to")
 	fmt.Println("  status            Show current status and settings")
 	fmt.Println("  set-home <ssid>   Set your home network SSID")
+	fmt.Println("  set-home-gateway [mac]  Recognize home by the gateway MAC (default: current gateway)")
 	fmt.Println("  set-device <mac>   Set monitored device MAC address")
-	fmt.Println("  pause             Pause protection")
+	fmt.Println("  get [key]         Print a setting by its settings.json key (no key: all settings)")
+	fmt.Println("  set <key> <value> Change a setting by its settings.json key, e.g. set grace_checks 5")
+	fmt.Println("  profile [save|use|delete <name>]  List profiles, or save/switch/remove one")
+	fmt.Println("  pause             Pause protection (--duration 2h: resume automatically)")
 	fmt.Println("  resume            Resume protection")
 	fmt.Println("  version           Show version")
+	fmt.Println("  version --check   Check GitHub for a newer release")
 	fmt.Println("  logs              Show recent log entries")
+	fmt.Println("  doctor            Check the installation (--json: machine-readable, exit code 1 on failure)")
+	fmt.Println("  validate          Check settings.json and list every value that would be reset, without saving")
+	fmt.Println("  uninstall         Remove auto-start, key, settings, state and logs (--purge: whole app-data dir)")
+	fmt.Println("  --simulate <name> Demo the protection flow with a scripted network (dry run)")
+	fmt.Println("  rehearse          Run the shutdown flow with your settings, ending in a no-op")
+	fmt.Println("  watch             Print status changes as the monitor sees them (dry run, Ctrl-C to stop)")
 	fmt.Println("  run               Start with system tray")
+	fmt.Println("Global flags (before the command):")
+	fmt.Println("  -v, --verbose     Log debug details (commands run, decisions) to the console")
+}
+
+// reportSettingsWarnings logs every invalid setting that was replaced on load
+// and sums them up in a single toast, so a hand-edited value that was reset is
+// not lost silently
+func reportSettingsWarnings(warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	for _, warning := range warnings {
+		logger.Warn("Invalid setting: %s", config.SanitizeDisplayString(warning))
+	}
+	message := "1 setting was invalid and reset to its default"
+	if len(warnings) > 1 {
+		message = fmt.Sprintf("%d settings were invalid and reset to defaults", len(warnings))
+	}
+	sentryManager.Notify("Home Sentry Settings", message+". See the log or run 'home-sentry validate'.")
+}
+
+// updateCheckInterval is how often the tray checks for a new release
+const updateCheckInterval = 24 * time.Hour
+
+// autoStartCheckInterval is how often the tray makes sure its auto-start
+// entry is still there
+const autoStartCheckInterval = time.Hour
+
+// runAutoStartChecker runs checkAutoStart now and then every
+// autoStartCheckInterval until ctx is done
+func runAutoStartChecker(ctx context.Context) {
+	ticker := time.NewTicker(autoStartCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		checkAutoStart()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAutoStart keeps the auto-start entry in line with the user's choice.
+// An existing entry records the choice for installs from before it was
+// tracked. One that disappeared (cleanup tools, policies) is put back, since
+// without it protection silently stops at the next reboot.
+func checkAutoStart() {
+	settings, err := config.Load()
+	if err != nil {
+		return
+	}
+	enabled := startup.IsEnabled()
+	switch {
+	case enabled && !settings.AutoStartWanted:
+		if err := config.SetAutoStartWanted(true); err != nil {
+			logger.Error("Failed to save the auto-start choice: %v", err)
+		}
+	case !enabled && settings.AutoStartWanted:
+		logger.Warn("The auto-start entry was removed outside Home Sentry, restoring it")
+		if err := startup.Enable(); err != nil {
+			logger.Error("Failed to restore auto-start: %v", err)
+			sentryManager.Notify("Home Sentry - Auto-Start Removed",
+				"Home Sentry will not start after a reboot. Enable Auto-Start again in the tray menu.")
+			return
+		}
+		logger.Audit("Auto-start entry restored")
+		sentryManager.Notify("Home Sentry - Auto-Start Restored",
+			"Another program removed the auto-start entry. It was put back so protection survives a reboot.")
+	}
+}
+
+func runVersionCheck() {
+	ctx, cancel := context.WithTimeout(context.Background(), update.DefaultTimeout)
+	defer cancel()
+
+	release, newer, err := update.Check(ctx, Version)
+	if err != nil {
+		fmt.Println("Update check failed:", err)
+		return
+	}
+
+	safeTag := config.SanitizeDisplayString(release.TagName)
+	if newer {
+		fmt.Printf("A newer version is available: %s\n", safeTag)
+		fmt.Printf("Download: %s\n", config.SanitizeDisplayString(release.HTMLURL))
+	} else {
+		fmt.Printf("You are up to date (latest release: %s)\n", safeTag)
+	}
+}
+
+// runUpdateChecker checks for a new release once a day while CheckForUpdates
+// is enabled, and notifies once per newly found version. It never downloads.
+func runUpdateChecker(ctx context.Context) {
+	notified := ""
+	ticker := time.NewTicker(updateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		settings, err := config.Load()
+		if err == nil && settings.CheckForUpdates {
+			checkCtx, cancelCheck := context.WithTimeout(ctx, update.DefaultTimeout)
+			release, newer, err := update.Check(checkCtx, Version)
+			cancelCheck()
+
+			if err != nil {
+				logger.Debug("Update check failed: %v", err)
+			} else if newer && release.TagName != notified {
+				notified = release.TagName
+				safeTag := config.SanitizeDisplayString(release.TagName)
+				logger.Info("A newer version is available: %s", safeTag)
+				if sentryManager != nil {
+					sentryManager.Notify("Home Sentry Update", fmt.Sprintf("Version %s is available on GitHub", safeTag))
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runSimulate(name string) {
+	scenario, ok := sentry.FindScenario(name)
+	if !ok {
+		fmt.Println("Usage: home-sentry --simulate <scenario>")
+		fmt.Println("Scenarios:")
+		for _, sc := range sentry.Scenarios() {
+			fmt.Printf("  %-15s %s\n", sc.Name, sc.Description)
+		}
+		return
+	}
+
+	fmt.Printf("Simulating %q: %s\n", scenario.Name, scenario.Description)
+	fmt.Println("Your settings are not used and nothing will actually shut down.")
+	sentry.RunSimulation(scenario, func(change sentry.StatusChange) {
+		line := fmt.Sprintf("[%s] %-16s WiFi: %s", time.Now().Format("15:04:05"), change.Status, config.SanitizeDisplayString(change.SSID))
+		if change.Status == sentry.StatusGracePeriod {
+			line += fmt.Sprintf(" (missed check %d)", change.GraceCount)
+		}
+		fmt.Println(line)
+	})
+	fmt.Println("Simulation finished.")
+}
+
+func runRehearse() {
+	settings, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading settings:", err)
+		return
+	}
+
+	rehearsal := sentry.NewRehearsal(settings, func(change sentry.StatusChange) {
+		line := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), change.Status)
+		if change.Status == sentry.StatusGracePeriod {
+			line += fmt.Sprintf(" (missed check %d)", change.GraceCount)
+		}
+		fmt.Println(line)
+	})
+	used := rehearsal.Settings()
+	fmt.Println("Rehearsing the shutdown flow with your settings. Nothing will actually shut down.")
+	fmt.Printf("The phone goes missing for %d checks, then a %d second countdown starts.\n", used.GraceChecks, used.ShutdownDelay)
+	fmt.Println("Press Enter during the countdown to cancel it.")
+
+	go func() {
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		if rehearsal.Cancel() {
+			fmt.Println("Countdown cancelled.")
+		}
+	}()
+
+	if rehearsal.Run() {
+		logger.Info("Rehearsal complete - %s would have run now", used.ShutdownAction)
+		fmt.Printf("Rehearsal complete: %s would have run now.\n", used.ShutdownAction)
+	} else {
+		fmt.Println("Rehearsal cancelled before the countdown ran out.")
+	}
+}
+
+// runWatch runs a dry-run monitor with the user's settings and prints every
+// status change until Ctrl-C
+func runWatch() {
+	var last sentry.StatusChange
+	sm := sentry.NewWatch(func(change sentry.StatusChange) {
+		if change == last {
+			return
+		}
+		last = change
+		line := fmt.Sprintf("[%s] %-16s WiFi: %s  Device: %s", time.Now().Format("15:04:05"), change.Status,
+			config.SanitizeDisplayString(change.SSID), config.SanitizeDisplayString(change.Device))
+		if change.GraceCount > 0 {
+			line += fmt.Sprintf("  Missed checks: %d", change.GraceCount)
+		}
+		if change.ShutdownPending {
+			line += "  Countdown running"
+		}
+		fmt.Println(line)
+	})
+
+	fmt.Println("Watching status changes with your settings. This is a dry run: nothing shuts down")
+	fmt.Println("and the tray app's state is not touched. Press Ctrl-C to stop.")
+	go sm.StartMonitor()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+	fmt.Println("Stopped watching.")
 }
 
 func runScan() {
 	fmt.Println("Scanning network (this may take a few seconds)...")
-	devices := network.ScanNetworkDevices()
+	settings, _ := config.Load()
+	devices := network.ScanNetworkDevices(network.ScanOptionsFromSettings(settings))
 
 	fmt.Println("IP\t\t\tMAC\t\t\tHostname")
 	fmt.Println("---------------------------------------------------------")
@@ -555,6 +978,29 @@ func runWifiScan() {
 	}
 }
 
+// runPeripherals lists the attached peripherals and marks the one that
+// disarms protection, if any
+func runPeripherals() {
+	peripherals, err := dock.Peripherals()
+	if err != nil {
+		fmt.Println("Some peripherals could not be listed:", err)
+	}
+	settings, _ := config.Load()
+	docked := dock.Match(peripherals, settings.DisarmWhenDockedTo)
+	fmt.Println("Add the full device ID in parentheses to disarm_when_docked_to; it includes the serial")
+	fmt.Println("number or port, so it only matches your own dock.")
+	for _, peripheral := range peripherals {
+		marker := " "
+		if peripheral == docked {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, config.SanitizeDisplayString(peripheral))
+	}
+	if docked != "" {
+		fmt.Println("* matches disarm_when_docked_to: protection is disarmed")
+	}
+}
+
 func runStatus() {
 	settings, err := config.Load()
 	if err != nil {
@@ -569,28 +1015,79 @@ func runStatus() {
 
 	fmt.Printf("Home Sentry v%s\n", Version)
 	fmt.Println("-------------------")
+	fmt.Printf("Device Name:    %s\n", config.SanitizeDisplayString(settings.MachineName()))
+	if settings.ActiveProfile != "" {
+		fmt.Printf("Profile:        %s\n", config.SanitizeDisplayString(settings.ActiveProfile))
+	}
 	fmt.Printf("Current SSID:   %s\n", safeCurrentSSID)
 	fmt.Printf("Home SSID:      %s\n", safeHomeSSID)
+	if settings.HomeDetection == config.HomeDetectionGateway {
+		fmt.Printf("Home Gateway:   %s\n", config.SanitizeDisplayString(settings.HomeGatewayMAC))
+	}
 	fmt.Printf("Phone MAC:      %s\n", safeMAC)
+	if settings.DetectionType == config.DetectionTypeMDNS {
+		fmt.Printf("Phone Name:     %s\n", config.SanitizeDisplayString(settings.PhoneHostname))
+	}
 	fmt.Printf("Detection:      %s\n", settings.DetectionType)
-	fmt.Printf("Paused:         %v\n", settings.IsPaused)
+	fmt.Printf("Detection Mode: %s\n", settings.DetectionMode)
+	if ends := settings.PauseEnds(); !ends.IsZero() {
+		fmt.Printf("Paused:         true (auto-resume in %s)\n", formatDuration(time.Until(ends)))
+	} else {
+		fmt.Printf("Paused:         %v\n", settings.IsPaused)
+	}
 	fmt.Printf("Grace Checks:   %d\n", settings.GraceChecks)
 	fmt.Printf("Poll Interval:  %ds\n", settings.PollInterval)
 	fmt.Printf("Ping Timeout:   %dms\n", settings.PingTimeoutMs)
 	fmt.Printf("Settings File:  %s\n", config.GetSettingsPath())
 	fmt.Printf("Log Directory:  %s\n", logger.GetLogDir())
 
-	if currentSSID == settings.HomeSSID {
+	if onHomeNetwork(settings) {
 		fmt.Println("Status:         AT HOME")
 	} else {
 		fmt.Println("Status:         ROAMING")
 	}
+
+	stats, err := sentry.LoadStats()
+	if err != nil {
+		fmt.Println("Error loading statistics:", err)
+		return
+	}
+	fmt.Printf("Phone Seen:     %s\n", formatLastSeen(stats.LastPhoneSeen, time.Now()))
+	fmt.Printf("Shutdowns:      %d\n", stats.Shutdowns)
+	fmt.Printf("Cancelled:      %d\n", stats.Cancels)
+}
+
+// formatDuration renders a duration coarsely, e.g. "2d 3h" or "14m"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	default:
+		return "<1m"
+	}
+}
+
+// formatLastSeen describes when the phone was last detected
+func formatLastSeen(seen, now time.Time) string {
+	if seen.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%s ago)", seen.Local().Format("2006-01-02 15:04"), formatDuration(now.Sub(seen)))
 }
 
 func runSetHome(ssid string) {
 	err := config.Update(ssid, "")
 	if err != nil {
-		fmt.Println("Error saving settings:", err)
+		fmt.Println(describeSettingsError(err))
 		return
 	}
 	sanitizedSSID, _ := config.SanitizeSSID(ssid)
@@ -599,6 +1096,23 @@ func runSetHome(ssid string) {
 	logger.Info("Home SSID set via CLI: %s", sanitizedSSID)
 }
 
+func runSetHomeGateway(mac string) {
+	if mac == "" {
+		mac = network.GetDefaultGatewayMAC()
+		if mac == "" {
+			fmt.Println("Error: Could not determine the default gateway's MAC address")
+			return
+		}
+	}
+	if err := config.SetHomeGateway(mac); err != nil {
+		fmt.Println(describeSettingsError(err))
+		return
+	}
+	sanitizedMAC, _ := config.SanitizeMAC(mac)
+	fmt.Printf("Home is now recognized by gateway MAC: %s\n", config.SanitizeDisplayString(sanitizedMAC))
+	logger.Info("Home gateway set via CLI: %s", sanitizedMAC)
+}
+
 func runSetDevice(mac string) {
 	if !config.ValidateMAC(mac) {
 		safeMAC := config.SanitizeDisplayString(mac)
@@ -608,13 +1122,196 @@ func runSetDevice(mac string) {
 	}
 	err := config.Update("", mac)
 	if err != nil {
-		fmt.Println("Error saving settings:", err)
+		fmt.Println(describeSettingsError(err))
 		return
 	}
 	sanitizedMAC, _ := config.SanitizeMAC(mac)
 	safeDisplay := config.SanitizeDisplayString(sanitizedMAC)
 	fmt.Printf("Monitored Device MAC updated to: %s\n", safeDisplay)
 	logger.Info("Device MAC set via CLI: %s", sanitizedMAC)
+	if network.IsRandomizedMAC(sanitizedMAC) {
+		fmt.Println("Warning: this is a randomized (private) MAC address. The phone may pick a new one,")
+		fmt.Println("e.g. after forgetting the network, and would then look absent. Consider IP or mDNS")
+		fmt.Println("detection: home-sentry set detection_type ip")
+		logger.Warn("Device MAC %s is randomized and may change", sanitizedMAC)
+	}
+}
+
+// runGet handles "get [key]" and returns the process exit code
+func runGet(args []string) int {
+	if len(args) > 1 {
+		fmt.Println("Usage: home-sentry get [key]")
+		return 2
+	}
+	settings, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading settings:", err)
+		return 1
+	}
+
+	if len(args) == 1 {
+		value, err := settings.Get(args[0])
+		if err != nil {
+			fmt.Println(config.RemoveControlChars(err.Error()))
+			return 1
+		}
+		fmt.Println(config.RemoveControlChars(value))
+		return 0
+	}
+	for _, key := range config.SettingKeys() {
+		if value, err := settings.Get(key); err == nil {
+			fmt.Printf("%s = %s\n", key, config.RemoveControlChars(value))
+		}
+	}
+	return 0
+}
+
+// runSet handles "set <key> <value>" and returns the process exit code. With
+// a PIN required, changing any setting asks for it.
+func runSet(args []string) int {
+	if len(args) != 2 {
+		fmt.Println("Usage: home-sentry set <key> <value>")
+		fmt.Println("Example: home-sentry set scan_ignore_list \"B8:27:EB, Espressif\"")
+		return 2
+	}
+	key, value := args[0], args[1]
+
+	settings, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading settings:", err)
+		return 1
+	}
+	if settings.RequirePIN && settings.ShutdownPIN != "" && !verifyCLIPIN(settings) {
+		fmt.Println("Incorrect PIN. The setting was not changed.")
+		logger.Warn("Setting %s via CLI refused: incorrect PIN", key)
+		return 1
+	}
+
+	stored, err := config.SetSetting(key, value)
+	if err != nil {
+		fmt.Println(config.RemoveControlChars(describeSettingsError(err)))
+		return 1
+	}
+	fmt.Printf("%s = %s\n", key, config.RemoveControlChars(stored))
+	logger.Info("Setting %s changed via CLI", key)
+	return 0
+}
+
+// runProfile handles "profile", "profile save <name>", "profile use <name>"
+// and "profile delete <name>", and returns the process exit code
+func runProfile(args []string) int {
+	if len(args) == 0 {
+		settings, err := config.Load()
+		if err != nil {
+			fmt.Println("Error loading settings:", err)
+			return 1
+		}
+		names := settings.ProfileNames()
+		if len(names) == 0 {
+			fmt.Println("No profiles saved. Save the current setup with: home-sentry profile save <name>")
+			return 0
+		}
+		for _, name := range names {
+			marker := " "
+			if name == settings.ActiveProfile {
+				marker = "*"
+			}
+			p := settings.Profiles[name]
+			home := p.HomeSSID
+			if p.HomeDetection == config.HomeDetectionGateway {
+				home = "gateway " + p.HomeGatewayMAC
+			}
+			device := p.PhoneMAC
+			switch p.DetectionType {
+			case config.DetectionTypeIP:
+				device = p.PhoneIP
+			case config.DetectionTypeMDNS:
+				device = p.PhoneHostname
+			}
+			fmt.Printf("%s %s: home %s, device %s, action %s\n", marker, config.SanitizeDisplayString(name),
+				config.SanitizeDisplayString(home), config.SanitizeDisplayString(device), p.ShutdownAction)
+		}
+		return 0
+	}
+
+	if len(args) != 2 {
+		fmt.Println("Usage: home-sentry profile [save|use|delete <name>]")
+		return 2
+	}
+	name := args[1]
+	safeName := config.SanitizeDisplayString(name)
+	var err error
+	switch args[0] {
+	case "save":
+		if err = config.SaveProfile(name); err == nil {
+			fmt.Printf("Saved the current home network, device and action as profile %s (active)\n", safeName)
+		}
+	case "use":
+		if err = config.UseProfile(name); err == nil {
+			fmt.Printf("Switched to profile %s\n", safeName)
+		}
+	case "delete":
+		if err = config.DeleteProfile(name); err == nil {
+			fmt.Printf("Deleted profile %s\n", safeName)
+		}
+	default:
+		fmt.Println("Usage: home-sentry profile [save|use|delete <name>]")
+		return 2
+	}
+	if err != nil {
+		fmt.Println(describeSettingsError(err))
+		return 1
+	}
+	logger.Info("Profile %s via CLI: %s", args[0], name)
+	return 0
+}
+
+// runPause handles "pause [--duration <d>]"
+func runPause(args []string) {
+	var duration time.Duration
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "--duration":
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			fmt.Printf("Invalid duration %q (examples: 90m, 2h, 1h30m)\n", args[1])
+			return
+		}
+		// Only a bare "pause" is open-ended
+		if d < time.Minute {
+			fmt.Printf("Invalid duration %q: the minimum is 1m\n", args[1])
+			return
+		}
+		duration = d
+	default:
+		fmt.Println("Usage: home-sentry pause [--duration <d>]")
+		fmt.Println("Example: home-sentry pause --duration 2h")
+		return
+	}
+
+	settings, err := config.Load()
+	if err != nil {
+		fmt.Println("Error loading settings:", err)
+		return
+	}
+	if settings.PINRequiredToPause() && !verifyCLIPIN(settings) {
+		fmt.Println("Incorrect PIN. Protection was not paused.")
+		logger.Warn("Pause via CLI refused: incorrect PIN")
+		return
+	}
+
+	if duration == 0 {
+		runSetPaused(true)
+		return
+	}
+
+	resumeAt, err := config.SetPausedFor(duration)
+	if err != nil {
+		fmt.Println("Error saving settings:", err)
+		return
+	}
+	fmt.Printf("Protection PAUSED until %s.\n", resumeAt.Local().Format("2006-01-02 15:04"))
+	logger.Audit("Protection paused via CLI until %s", resumeAt.Format(time.RFC3339))
 }
 
 func runSetPaused(paused bool) {
@@ -625,10 +1322,10 @@ func runSetPaused(paused bool) {
 	}
 	if paused {
 		fmt.Println("Protection PAUSED.")
-		logger.Info("Protection paused via CLI")
+		logger.Audit("Protection paused via CLI")
 	} else {
 		fmt.Println("Protection RESUMED.")
-		logger.Info("Protection resumed via CLI")
+		logger.Audit("Protection resumed via CLI")
 	}
 }
 
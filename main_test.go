@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantRest    []string
+		wantVerbose bool
+	}{
+		{"flag before command", []string{"-v", "scan"}, []string{"scan"}, true},
+		{"flag after command goes to the command", []string{"scan", "-v"}, []string{"scan", "-v"}, false},
+		{"long flag alone", []string{"--verbose"}, nil, true},
+		{"no args", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, verbose := parseGlobalFlags(tt.args)
+			if !reflect.DeepEqual(rest, tt.wantRest) || verbose != tt.wantVerbose {
+				t.Errorf("parseGlobalFlags(%q) = %q, %v, want %q, %v", tt.args, rest, verbose, tt.wantRest, tt.wantVerbose)
+			}
+		})
+	}
+}
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"fmt"
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/logger"
+	"home-sentry/pkg/network"
+	"home-sentry/pkg/sentry"
+	"home-sentry/pkg/startup"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/getlantern/systray"
+)
+
+// menuState is a snapshot of everything menu titles depend on. It is taken
+// once per refresh so individual entries don't reload settings.
+type menuState struct {
+	Settings        config.Settings
+	SSID            string
+	AtHome          bool // The monitor's home detection, SSID or gateway
+	Status          string
+	SentryStatus    sentry.SentryStatus
+	ShutdownPending bool
+	AutoStart       bool
+	Stats           sentry.Stats
+	Snapshot        sentry.Snapshot
+	ShowAllDevices  bool
+	Now             time.Time
+}
+
+// menuEntry describes one menu item. The native tray menu and the Fyne
+// popup are both rendered from the same entries, so a feature added here
+// shows up in both.
+type menuEntry struct {
+	Title     func(menuState) string
+	Tooltip   string
+	OnClick   func()               // nil renders a disabled info item
+	Visible   func(menuState) bool // nil means always visible
+	Separator bool
+	Submenu   []*menuEntry
+
+	// ShowsStatus marks the status line, which the popup draws with a
+	// colored indicator
+	ShowsStatus bool
+
+	// HostsDevices marks the entry that scanned devices are listed under
+	HostsDevices bool
+}
+
+var (
+	menuModel []*menuEntry
+
+	menuMu     sync.Mutex
+	statusText = "Status: Starting..."
+	lastStatus sentry.SentryStatus
+
+	// showAllDevices bypasses the device list filter until the app exits
+	showAllDevices bool
+
+	// scanAwaited is set when a scan click found another scan running, so
+	// that scan reports its result in the status line when it finishes
+	scanAwaited bool
+)
+
+// shutdownDelays are the choices offered under the shutdown timer entry
+var shutdownDelays = []struct {
+	Seconds int
+	Label   string
+}{
+	{10, "10 Seconds"},
+	{30, "30 Seconds"},
+	{60, "1 Minute"},
+	{300, "5 Minutes"},
+}
+
+func fixedTitle(title string) func(menuState) string {
+	return func(menuState) string { return title }
+}
+
+func separator() *menuEntry {
+	return &menuEntry{Separator: true}
+}
+
+// shutdownActions are the choices offered under the action entry
+var shutdownActions = []struct {
+	Action string
+	Label  string
+}{
+	{config.ShutdownActionShutdown, "Shut Down"},
+	{config.ShutdownActionHibernate, "Hibernate"},
+	{config.ShutdownActionSleep, "Sleep"},
+	{config.ShutdownActionLock, "Lock"},
+	{config.ShutdownActionLogoff, "Log Off (closes all apps)"},
+}
+
+// pauseLimits are the choices offered under the pause limit entry
+var pauseLimits = []struct {
+	Minutes int
+	Label   string
+}{
+	{0, "Unlimited"},
+	{60, "1 Hour"},
+	{4 * 60, "4 Hours"},
+	{12 * 60, "12 Hours"},
+	{24 * 60, "24 Hours"},
+}
+
+// menuThemes are the choices offered under the theme entry
+var menuThemes = []struct {
+	Name  string
+	Label string
+}{
+	{config.ThemeSystem, "System"},
+	{config.ThemeDark, "Dark"},
+	{config.ThemeLight, "Light"},
+}
+
+// detectionTypes are the choices offered under the detection entry
+var detectionTypes = []struct {
+	Type  config.DetectionType
+	Label string
+}{
+	{config.DetectionTypeMAC, "MAC Address"},
+	{config.DetectionTypeIP, "IP Address"},
+	{config.DetectionTypeMDNS, "mDNS Name"},
+}
+
+// checkedTitle prefixes label with a check mark when checked
+func checkedTitle(label string, checked bool) string {
+	if checked {
+		return "✓ " + label
+	}
+	return label
+}
+
+// buildMenuModel defines the tray menu
+func buildMenuModel() []*menuEntry {
+	timerChoices := make([]*menuEntry, 0, len(shutdownDelays))
+	for _, d := range shutdownDelays {
+		seconds, label := d.Seconds, d.Label
+		timerChoices = append(timerChoices, &menuEntry{
+			Title: func(s menuState) string {
+				return checkedTitle(label, s.Settings.ShutdownDelay == seconds)
+			},
+			Tooltip: fmt.Sprintf("Wait %s before shutdown", label),
+			OnClick: func() { setShutdownDelay(seconds) },
+		})
+	}
+
+	actionChoices := make([]*menuEntry, 0, len(shutdownActions))
+	for _, a := range shutdownActions {
+		action, label := a.Action, a.Label
+		tooltip := fmt.Sprintf("%s when the phone is gone", label)
+		if action == config.ShutdownActionLogoff {
+			tooltip = "Sign out and force-close all apps when the phone is gone. Unsaved work is lost."
+		}
+		actionChoices = append(actionChoices, &menuEntry{
+			Title: func(s menuState) string {
+				return checkedTitle(label, s.Settings.ShutdownAction == action)
+			},
+			Tooltip: tooltip,
+			OnClick: func() { setShutdownAction(action) },
+		})
+	}
+
+	limitChoices := make([]*menuEntry, 0, len(pauseLimits))
+	for _, l := range pauseLimits {
+		minutes, label := l.Minutes, l.Label
+		limitChoices = append(limitChoices, &menuEntry{
+			Title: func(s menuState) string {
+				return checkedTitle(label, s.Settings.MaxPauseMinutes == minutes)
+			},
+			Tooltip: "Resume protection automatically after this long",
+			OnClick: func() { setMaxPause(minutes) },
+		})
+	}
+
+	themeChoices := make([]*menuEntry, 0, len(menuThemes))
+	for _, t := range menuThemes {
+		name, label := t.Name, t.Label
+		themeChoices = append(themeChoices, &menuEntry{
+			Title: func(s menuState) string {
+				return checkedTitle(label, s.Settings.Theme == name)
+			},
+			Tooltip: fmt.Sprintf("Use the %s menu theme", strings.ToLower(label)),
+			OnClick: func() { setMenuTheme(name) },
+		})
+	}
+
+	detectionChoices := make([]*menuEntry, 0, len(detectionTypes)+3)
+	for _, d := range detectionTypes {
+		detectionType, label := d.Type, d.Label
+		detectionChoices = append(detectionChoices, &menuEntry{
+			Title: func(s menuState) string {
+				return checkedTitle(label, s.Settings.DetectionType == detectionType)
+			},
+			Tooltip: fmt.Sprintf("Detect the phone by its %s", label),
+			OnClick: func() { setDetectionType(detectionType) },
+		})
+	}
+	detectionChoices = append(detectionChoices,
+		separator(),
+		&menuEntry{
+			Title:   fixedTitle("🔄 Re-scan Network..."),
+			Tooltip: "Refresh the device list under Select Monitored Device",
+			OnClick: func() { scanAndPopulateDevices(true) },
+		},
+		&menuEntry{
+			Title:   fixedTitle("⌨️ Enter Device MAC Manually..."),
+			Tooltip: "Set the monitored device without scanning",
+			OnClick: showManualMACDialog,
+		},
+	)
+
+	return []*menuEntry{
+		{
+			Title:       func(s menuState) string { return s.Status },
+			Tooltip:     "Current status",
+			ShowsStatus: true,
+		},
+		{
+			Title: func(s menuState) string {
+				if s.AtHome {
+					return "🏠 At Home"
+				}
+				return "📍 Roaming"
+			},
+			Tooltip: "Current location",
+		},
+		{
+			Title: func(s menuState) string {
+				return fmt.Sprintf("📶 WiFi: %s", config.SanitizeDisplayString(s.SSID))
+			},
+			Tooltip: "Current WiFi network",
+		},
+		{
+			Title: func(s menuState) string {
+				if !s.Settings.HasDeviceConfigured() {
+					return fmt.Sprintf("📱 Phone: Not Set (%s)", strings.ToUpper(string(s.Settings.DetectionType)))
+				}
+				return fmt.Sprintf("📱 Phone: %s", config.SanitizeDisplayString(s.Settings.GetDeviceIdentifier()))
+			},
+			Tooltip: "Monitored device",
+		},
+		{
+			Title:   fixedTitle(fmt.Sprintf("ℹ️ Version: %s", Version)),
+			Tooltip: "Application version",
+		},
+		separator(),
+		{
+			Title:   fixedTitle("🏠 Set Current WiFi as Home"),
+			Tooltip: "Use current network as home",
+			OnClick: setHomeToCurrentSSID,
+		},
+		{
+			Title:        fixedTitle("📱 Select Monitored Device"),
+			Tooltip:      "Choose device from network",
+			HostsDevices: true,
+			Submenu: []*menuEntry{
+				{
+					Title:   fixedTitle("🔄 Scan Network..."),
+					Tooltip: "Refresh network device list",
+					OnClick: func() { scanAndPopulateDevices(true) },
+				},
+				{
+					Title:   fixedTitle("⌨️ Enter Device MAC Manually..."),
+					Tooltip: "Set the monitored device without scanning",
+					OnClick: showManualMACDialog,
+				},
+				{
+					Title: func(s menuState) string {
+						return checkedTitle("Show All Devices", s.ShowAllDevices)
+					},
+					Tooltip: "Also list the router, multicast addresses and ignored devices",
+					OnClick: toggleShowAllDevices,
+				},
+			},
+		},
+		{
+			Title: func(s menuState) string {
+				return fmt.Sprintf("🔍 Detection (%s)", strings.ToUpper(string(s.Settings.DetectionType)))
+			},
+			Tooltip: "How the monitored device is recognized",
+			Submenu: detectionChoices,
+		},
+		separator(),
+		{
+			Title: func(s menuState) string {
+				if !s.Settings.IsPaused {
+					return "⏸️ Pause Protection"
+				}
+				if ends := s.Settings.PauseEnds(); !ends.IsZero() {
+					return fmt.Sprintf("▶️ Resume Protection (auto in %s)", formatDuration(ends.Sub(s.Now)))
+				}
+				return "▶️ Resume Protection"
+			},
+			Tooltip: "Temporarily disable protection",
+			OnClick: togglePause,
+		},
+		{
+			Title: func(s menuState) string {
+				if s.Settings.MaxPauseMinutes == 0 {
+					return "⏳ Pause Limit (none)"
+				}
+				return fmt.Sprintf("⏳ Pause Limit (%s)", formatDuration(s.Settings.MaxPause()))
+			},
+			Tooltip: "Longest a pause may last before protection resumes",
+			Submenu: limitChoices,
+		},
+		{
+			Title: func(s menuState) string {
+				if s.AutoStart {
+					return "✅ Auto-Start Enabled"
+				}
+				return "🚀 Enable Auto-Start"
+			},
+			Tooltip: "Start Home Sentry when Windows starts",
+			OnClick: toggleAutoStart,
+		},
+		{
+			Title: func(s menuState) string {
+				return fmt.Sprintf("⏱ Shutdown Timer (%ds)", s.Settings.ShutdownDelay)
+			},
+			Tooltip: "Set delay before shutdown",
+			Submenu: timerChoices,
+		},
+		{
+			Title: func(s menuState) string {
+				return fmt.Sprintf("⚡ Action (%s)", s.Settings.ShutdownAction)
+			},
+			Tooltip: "What happens when the phone is gone",
+			Submenu: actionChoices,
+		},
+		{
+			Title:   fixedTitle("📊 Statistics"),
+			Tooltip: "How protection has behaved over time",
+			Submenu: statisticsEntries(),
+		},
+		{
+			Title:   fixedTitle("🎨 Menu Theme"),
+			Tooltip: "Choose the popup menu colors",
+			Submenu: themeChoices,
+		},
+		{
+			Title:   fixedTitle("⚠️ Cancel Shutdown"),
+			Tooltip: "Cancel pending shutdown",
+			OnClick: cancelPendingShutdown,
+			Visible: func(s menuState) bool { return s.ShutdownPending },
+		},
+		separator(),
+		{
+			Title:   fixedTitle("❌ Quit"),
+			Tooltip: "Exit Home Sentry",
+			OnClick: quitApp,
+		},
+	}
+}
+
+// statisticsEntries are the info lines under the statistics entry
+func statisticsEntries() []*menuEntry {
+	return []*menuEntry{
+		{
+			Title: func(s menuState) string {
+				if s.Stats.StartedAt.IsZero() {
+					return "Uptime: -"
+				}
+				return "Uptime: " + formatDuration(s.Now.Sub(s.Stats.StartedAt))
+			},
+		},
+		{
+			Title: func(s menuState) string {
+				if s.Stats.MonitoringSince.IsZero() {
+					return "Protected for: -"
+				}
+				return "Protected for: " + formatDuration(s.Now.Sub(s.Stats.MonitoringSince))
+			},
+			Tooltip: "Time since protection was last paused",
+		},
+		{
+			Title: func(s menuState) string {
+				if s.Stats.HomeSince.IsZero() {
+					return "At home for: -"
+				}
+				return "At home for: " + formatDuration(s.Now.Sub(s.Stats.HomeSince))
+			},
+		},
+		{
+			Title: func(s menuState) string {
+				return "Phone last seen: " + formatLastSeen(s.Stats.LastPhoneSeen, s.Now)
+			},
+		},
+		{
+			Title: func(s menuState) string {
+				if s.Snapshot.LastScan.IsZero() {
+					return "Devices home: -"
+				}
+				phone := "phone away"
+				if s.Snapshot.PhonePresent {
+					phone = "phone present"
+				}
+				return fmt.Sprintf("Devices home: %d, %s", s.Snapshot.DevicesHome, phone)
+			},
+			Tooltip: "Devices found by the last scan of the home network",
+		},
+		{
+			Title: func(s menuState) string { return fmt.Sprintf("Shutdowns: %d", s.Stats.Shutdowns) },
+		},
+		{
+			Title: func(s menuState) string { return fmt.Sprintf("Cancelled shutdowns: %d", s.Stats.Cancels) },
+		},
+	}
+}
+
+// deviceEntries lists scanned devices as selectable menu entries. hidden is
+// the number of devices the filter left out.
+func deviceEntries(devices []network.NetworkDevice, hidden int) []*menuEntry {
+	if len(devices) == 0 {
+		tooltip := "Try again or check WiFi connection"
+		if hidden > 0 {
+			tooltip = fmt.Sprintf("%d devices hidden, use Show All Devices to list them", hidden)
+		}
+		return []*menuEntry{{
+			Title:   fixedTitle("❌ No devices found"),
+			Tooltip: tooltip,
+		}}
+	}
+
+	header := fmt.Sprintf("── Found %d devices ──", len(devices))
+	if hidden > 0 {
+		header = fmt.Sprintf("── Found %d devices (%d hidden) ──", len(devices), hidden)
+	}
+	entries := []*menuEntry{{
+		Title: fixedTitle(header),
+	}}
+	for _, device := range devices {
+		// Sanitize all device fields before display
+		safeIP := config.SanitizeDisplayString(device.IP)
+		safeMAC := config.SanitizeDisplayString(device.MAC)
+		safeVendor := config.SanitizeDisplayString(device.Vendor)
+		safeHostname := config.SanitizeDisplayString(device.Hostname)
+
+		// Format: "IP / MAC / Vendor" (include Hostname if known)
+		var label string
+		if device.Hostname != "Unknown" && device.Hostname != "" {
+			label = fmt.Sprintf("📱 %s (%s) / %s / %s", safeHostname, safeIP, safeMAC, safeVendor)
+		} else {
+			label = fmt.Sprintf("📱 %s / %s / %s", safeIP, safeMAC, safeVendor)
+		}
+
+		ip, mac := device.IP, device.MAC
+		name := device.Hostname
+		if name == "Unknown" || name == "" {
+			name = device.IP
+		}
+
+		entries = append(entries, &menuEntry{
+			Title: fixedTitle(label),
+			Tooltip: fmt.Sprintf("Click to monitor • IP: %s\nMAC: %s\nVendor: %s\nHostname: %s",
+				safeIP, safeMAC, safeVendor, safeHostname),
+			OnClick: func() { selectDevice(ip, mac, name) },
+		})
+	}
+	return entries
+}
+
+// currentMenuState gathers the values menu titles are rendered from
+func currentMenuState() menuState {
+	settings, _ := config.Load()
+	ssid := network.GetCurrentSSID()
+
+	menuMu.Lock()
+	state := menuState{
+		Settings:     settings,
+		SSID:         ssid,
+		Status:       statusText,
+		SentryStatus: lastStatus,
+		// The imminent status is reported just before the countdown is armed
+		ShutdownPending: lastStatus == sentry.StatusShutdownImminent,
+		ShowAllDevices:  showAllDevices,
+	}
+	menuMu.Unlock()
+
+	if sentryManager != nil {
+		state.ShutdownPending = state.ShutdownPending || sentryManager.IsShutdownPending()
+		state.AtHome = sentryManager.AtHome()
+		state.Stats = sentryManager.Stats()
+		state.Snapshot = sentryManager.Snapshot()
+	}
+	state.Now = time.Now()
+	state.AutoStart = startup.IsEnabled()
+	return state
+}
+
+// setStatusText replaces the status line in both menus
+func setStatusText(text string) {
+	menuMu.Lock()
+	statusText = text
+	menuMu.Unlock()
+	refreshMenus()
+}
+
+// refreshMenus re-evaluates every entry's title and visibility
+func refreshMenus() {
+	state := currentMenuState()
+	refreshTrayMenu(state)
+
+	if fyneApp == nil {
+		return
+	}
+	fyne.Do(func() {
+		refreshFyneMenu(state)
+	})
+}
+
+func setHomeToCurrentSSID() {
+	ssid := network.GetCurrentSSID()
+	if err := config.Update(ssid, ""); err != nil {
+		reportSettingsError("Failed to set home SSID", err)
+		return
+	}
+	sanitizedSSID, _ := config.SanitizeSSID(ssid)
+	logger.Info("Home SSID set to: %s", sanitizedSSID)
+}
+
+// selectDevice monitors a scanned device. Both its IP and MAC are stored so
+// switching the detection type later keeps working.
+func selectDevice(ip, mac, name string) {
+	if err := config.UpdateDevice(ip, mac, ""); err != nil {
+		reportSettingsError("Failed to set device", err)
+		return
+	}
+	sanitizedMAC, _ := config.SanitizeMAC(mac)
+	sanitizedName, _ := config.SanitizeSSID(name)
+	logger.Info("Device set to: %s / %s (%s)", config.SanitizeDisplayString(ip), sanitizedMAC, sanitizedName)
+
+	settings, _ := config.Load()
+	if settings.DetectionType == config.DetectionTypeMAC && network.IsRandomizedMAC(sanitizedMAC) {
+		logger.Warn("Device MAC %s is randomized and may change", sanitizedMAC)
+		setStatusText("⚠️ Private MAC may change - consider IP or mDNS detection")
+		return
+	}
+	setStatusText(fmt.Sprintf("✅ Monitoring: %s", config.SanitizeDisplayString(name)))
+}
+
+func setDetectionType(detectionType config.DetectionType) {
+	if err := config.SetDetectionType(detectionType); err != nil {
+		reportSettingsError("Failed to set detection type", err)
+		return
+	}
+	logger.Info("Detection type set to %s", detectionType)
+
+	settings, _ := config.Load()
+	switch {
+	case settings.HasDeviceConfigured():
+	case detectionType == config.DetectionTypeMDNS:
+		// Scanned devices don't carry their mDNS name
+		setStatusText("⚠️ No mDNS name set - run: home-sentry set phone_hostname <name>")
+	default:
+		setStatusText(fmt.Sprintf("⚠️ No device %s set - select a device", strings.ToUpper(string(detectionType))))
+	}
+}
+
+// toggleShowAllDevices switches the device list filter off or back on and
+// relists the last scan
+func toggleShowAllDevices() {
+	menuMu.Lock()
+	showAllDevices = !showAllDevices
+	menuMu.Unlock()
+
+	// A running scan lists its devices with the new setting
+	if !scanMutex.TryLock() {
+		return
+	}
+	defer scanMutex.Unlock()
+	if devices, ok := scannedDevices.Last(); ok {
+		populateDeviceMenus(devices)
+	}
+}
+
+func togglePause() {
+	settings, _ := config.Load()
+	if !settings.IsPaused && settings.PINRequiredToPause() {
+		showPINDialog("Pause Protection", func() {
+			setPaused(true)
+			refreshMenus()
+		})
+		return
+	}
+	setPaused(!settings.IsPaused)
+}
+
+func setPaused(paused bool) {
+	if err := config.SetPaused(paused); err != nil {
+		reportSettingsError("Failed to change pause state", err)
+		return
+	}
+	if paused {
+		logger.Audit("Protection paused")
+	} else {
+		logger.Audit("Protection resumed")
+	}
+}
+
+func toggleAutoStart() {
+	enabled, err := startup.Toggle()
+	if err != nil {
+		logger.Error("Failed to toggle auto-start: %v", err)
+		return
+	}
+	if enabled {
+		logger.Info("Auto-start enabled")
+	} else {
+		logger.Info("Auto-start disabled")
+	}
+	if err := config.SetAutoStartWanted(enabled); err != nil {
+		reportSettingsError("Failed to save the auto-start choice", err)
+	}
+}
+
+func setMaxPause(minutes int) {
+	if err := config.SetMaxPauseMinutes(minutes); err != nil {
+		reportSettingsError("Failed to set pause limit", err)
+		return
+	}
+	logger.Info("Pause limit set to %d minutes", minutes)
+}
+
+func setShutdownDelay(seconds int) {
+	if err := config.SetShutdownDelay(seconds); err != nil {
+		reportSettingsError("Failed to set shutdown timer", err)
+		return
+	}
+	logger.Info("Shutdown timer set to %ds", seconds)
+}
+
+func setShutdownAction(action string) {
+	if err := config.SetShutdownAction(action); err != nil {
+		reportSettingsError("Failed to set shutdown action", err)
+		return
+	}
+	logger.Info("Shutdown action set to %s", action)
+}
+
+func setMenuTheme(name string) {
+	if err := config.SetTheme(name); err != nil {
+		reportSettingsError("Failed to set menu theme", err)
+		return
+	}
+	logger.Info("Menu theme set to %s", name)
+	applyCustomMenuTheme(name)
+}
+
+func cancelPendingShutdown() {
+	if sentryManager == nil || !sentryManager.CancelShutdown() {
+		return
+	}
+	menuMu.Lock()
+	lastStatus = ""
+	menuMu.Unlock()
+	logger.Info("Shutdown cancelled by user")
+	setStatusText("Status: Shutdown Cancelled")
+}
+
+func quitApp() {
+	logger.Info("User requested quit")
+	if fyneApp != nil {
+		fyne.Do(func() {
+			popupMenu.Hide()
+			fyneApp.Quit()
+		})
+	}
+	// Ends the tray loop, which runs the shared shutdown via onExit
+	systray.Quit()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"home-sentry/pkg/config"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// PIN guessing is slowed down by waiting pinFailureDelay after a wrong PIN,
+// doubling with every further one up to maxPINFailureDelay
+const (
+	pinFailureDelay    = 2 * time.Second
+	maxPINFailureDelay = 5 * time.Minute
+)
+
+// pinGuard counts wrong PINs in a row and refuses attempts until the delay
+// earned by the last one has passed
+type pinGuard struct {
+	mu       sync.Mutex
+	failures int
+	until    time.Time
+}
+
+// pinAttempts guards every PIN prompt of the running process
+var pinAttempts pinGuard
+
+// Wait returns how long before an attempt made at now will be checked
+func (g *pinGuard) Wait(now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Before(g.until) {
+		return g.until.Sub(now)
+	}
+	return 0
+}
+
+// Fail records a wrong PIN entered at now and returns the failures in a row
+// and the delay before the next attempt
+func (g *pinGuard) Fail(now time.Time) (int, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures++
+	delay := maxPINFailureDelay
+	if shift := g.failures - 1; shift < 8 && pinFailureDelay<<shift < maxPINFailureDelay {
+		delay = pinFailureDelay << shift
+	}
+	g.until = now.Add(delay)
+	return g.failures, delay
+}
+
+// Succeed resets the count after a correct PIN
+func (g *pinGuard) Succeed() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = 0
+	g.until = time.Time{}
+}
+
+// verifyCLIPIN prompts for the PIN without echoing it. A wrong PIN is logged
+// by the caller and costs the failure delay before the command gives up.
+func verifyCLIPIN(settings config.Settings) bool {
+	if settings.VerifyPIN(readPIN("PIN: ")) {
+		pinAttempts.Succeed()
+		return true
+	}
+	_, delay := pinAttempts.Fail(time.Now())
+	time.Sleep(delay)
+	return false
+}
+
+// readPIN prints prompt and reads a line without echoing it, falling back to
+// readLine when stdin is not a terminal
+func readPIN(prompt string) string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readLine(prompt)
+	}
+	fmt.Print(prompt)
+	pin, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(pin))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPINGuard(t *testing.T) {
+	var guard pinGuard
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if wait := guard.Wait(now); wait != 0 {
+		t.Fatalf("Wait() before any failure = %v, want 0", wait)
+	}
+
+	// Each wrong PIN doubles the delay until it reaches the cap
+	tests := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{7, 128 * time.Second},
+		{8, 256 * time.Second},
+		{9, maxPINFailureDelay},
+		{20, maxPINFailureDelay},
+		{100, maxPINFailureDelay},
+	}
+	for _, tt := range tests {
+		var failures int
+		var delay time.Duration
+		for failures < tt.failures {
+			failures, delay = guard.Fail(now)
+		}
+		if delay != tt.delay {
+			t.Errorf("Fail() #%d delay = %v, want %v", failures, delay, tt.delay)
+		}
+		if wait := guard.Wait(now.Add(time.Second)); wait != tt.delay-time.Second {
+			t.Errorf("Wait() 1s after failure #%d = %v, want %v", failures, wait, tt.delay-time.Second)
+		}
+		if wait := guard.Wait(now.Add(tt.delay)); wait != 0 {
+			t.Errorf("Wait() once the delay of failure #%d is over = %v, want 0", failures, wait)
+		}
+	}
+
+	// A correct PIN starts over at the first delay
+	guard.Succeed()
+	if wait := guard.Wait(now); wait != 0 {
+		t.Errorf("Wait() after a correct PIN = %v, want 0", wait)
+	}
+	if failures, delay := guard.Fail(now); failures != 1 || delay != pinFailureDelay {
+		t.Errorf("Fail() after a correct PIN = %d, %v, want 1, %v", failures, delay, pinFailureDelay)
+	}
+}
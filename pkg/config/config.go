@@ -3,67 +3,206 @@ package config
 import (
 	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"home-sentry/pkg/paths"
+	"io"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 // settingsMu protects concurrent access to the settings file.
 // All Load/Save operations must hold this lock to prevent read-modify-write races.
+// Anything that reloads settings in the background (e.g. a file watcher) must
+// go through Load, and changes must load, modify and save under one hold of
+// the lock like the Set* functions do; saving a copy loaded earlier with Save
+// overwrites changes made in between.
 var settingsMu sync.Mutex
 
 // DetectionType specifies how to detect the phone
 type DetectionType string
 
 const (
-	DetectionTypeIP  DetectionType = "ip"
-	DetectionTypeMAC DetectionType = "mac"
+	DetectionTypeIP   DetectionType = "ip"
+	DetectionTypeMAC  DetectionType = "mac"
+	DetectionTypeMDNS DetectionType = "mdns" // Bonjour name, survives MAC rotation
+)
+
+// DetectionMode specifies how aggressively presence is verified.
+// Active mode clears the ARP entry and pings the device on every check.
+// Passive mode only reads the existing ARP table - it is much lighter on the
+// network but can report a device that has just left as still present.
+type DetectionMode string
+
+const (
+	DetectionModeActive  DetectionMode = "active"
+	DetectionModePassive DetectionMode = "passive"
+)
+
+// ARPRefresh specifies how active detection avoids trusting a stale ARP
+// entry. Deleting the entry before pinging needs administrator rights; the
+// probe strategy instead sends several pings and TCP connection attempts and
+// counts the phone as absent when none of them is answered.
+type ARPRefresh string
+
+const (
+	ARPRefreshAuto   ARPRefresh = "auto" // Delete when elevated, otherwise probe
+	ARPRefreshDelete ARPRefresh = "delete"
+	ARPRefreshProbe  ARPRefresh = "probe"
+)
+
+// HomeDetection specifies how the machine recognizes the home network.
+// The SSID is easy to spoof and changes when the router is renamed; the
+// default gateway's MAC address is a harder to fake fingerprint.
+type HomeDetection string
+
+const (
+	HomeDetectionSSID    HomeDetection = "ssid"
+	HomeDetectionGateway HomeDetection = "gateway"
 )
 
 type Settings struct {
-	HomeSSID       string        `json:"home_ssid"`
-	PhoneIP        string        `json:"phone_ip"`
-	PhoneMAC       string        `json:"phone_mac"`
-	DetectionType  DetectionType `json:"detection_type"`
-	IsPaused       bool          `json:"is_paused"`
-	GraceChecks    int           `json:"grace_checks"`
-	PollInterval   int           `json:"poll_interval_sec"`
-	PingTimeoutMs  int           `json:"ping_timeout_ms"`
-	ShutdownDelay  int           `json:"shutdown_delay_sec"`
-	ShutdownPIN    string        `json:"shutdown_pin"`
-	RequirePIN     bool          `json:"require_pin"`
-	ShutdownAction string        `json:"shutdown_action"`
+	SchemaVersion     int           `json:"schema_version"`
+	DeviceName        string        `json:"device_name"`
+	HomeSSID          string        `json:"home_ssid"`
+	HomeDetection     HomeDetection `json:"home_detection"`
+	HomeGatewayMAC    string        `json:"home_gateway_mac"`
+	PhoneIP           string        `json:"phone_ip"`
+	PhoneMAC          string        `json:"phone_mac"`
+	PhoneHostname     string        `json:"phone_hostname"`
+	DetectionType     DetectionType `json:"detection_type"`
+	DetectionMode     DetectionMode `json:"detection_mode"`
+	ARPRefresh        ARPRefresh    `json:"arp_refresh"`
+	IsPaused          bool          `json:"is_paused"`
+	PausedAt          time.Time     `json:"paused_at,omitempty"`
+	PausedUntil       time.Time     `json:"paused_until,omitempty"`
+	GraceChecks       int           `json:"grace_checks"`
+	GraceAlertCount   int           `json:"grace_alert_count"`
+	GraceAlertWindow  int           `json:"grace_alert_window_min"`
+	PollInterval      int           `json:"poll_interval_sec"`
+	PingTimeoutMs     int           `json:"ping_timeout_ms"`
+	PingCount         int           `json:"ping_count"`
+	PingPacketSize    int           `json:"ping_packet_size"`
+	ProbePorts        []int         `json:"probe_ports"`
+	ShutdownDelay     int           `json:"shutdown_delay_sec"`
+	ShutdownPIN       string        `json:"shutdown_pin"`
+	RequirePIN        bool          `json:"require_pin"`
+	RequirePINToPause bool          `json:"require_pin_to_pause"`
+	ShutdownAction    string        `json:"shutdown_action"`
+	ResolveHostnames  bool          `json:"resolve_hostnames"`
+	TreatNoWifiAsAway bool          `json:"treat_no_wifi_as_away"`
+	HoldLastHomeSec   int           `json:"hold_last_home_sec"`
+	MaxSeenAgeHours   int           `json:"max_seen_age_hours"`
+	AlertOnNewDevice  bool          `json:"alert_on_new_device"`
+	NotifyOnRecovery  bool          `json:"notify_on_recovery"`
+	NotifyOnGrace     bool          `json:"notify_on_grace"`
+	ScanTimeoutSec    int           `json:"scan_timeout_sec"`
+	ScanCIDRs         []string      `json:"scan_cidrs"`
+	ScanIgnoreList    []string      `json:"scan_ignore_list"`
+	FastScan          bool          `json:"fast_scan"`
+	ScanExcludeSelf   bool          `json:"scan_exclude_self"`
+	DebugDumpScans    bool          `json:"debug_dump_scans"`
+	AutoResumeOnHome  bool          `json:"auto_resume_on_home"`
+	MaxPauseMinutes   int           `json:"max_pause_minutes"`
+	CheckForUpdates   bool          `json:"check_for_updates"`
+	AutoStartWanted   bool          `json:"auto_start_wanted"`
+	LogRetentionDays  int           `json:"log_retention_days"`
+	UseEventLog       bool          `json:"use_event_log"`
+	Theme             string        `json:"theme"`
+	IconPackDir       string        `json:"icon_pack_dir"`
+	WarningSoundFile  string        `json:"warning_sound_file"`
+	SilentMode        bool          `json:"silent_mode"`
+	QuietHoursStart   int           `json:"quiet_hours_start"`
+	QuietHoursEnd     int           `json:"quiet_hours_end"`
+
+	// Independent methods that must agree the phone is gone before a
+	// check counts towards the grace period
+	ConfirmAbsenceMethods int `json:"confirm_absence_methods"`
+	// In IP detection, follow the phone to a new DHCP lease by looking its
+	// IP up by MAC when the configured one stops answering
+	AutoUpdateDeviceIP bool `json:"auto_update_device_ip"`
+
+	// Minutes between background network scans (0 disables them), and
+	// whether networks other than home are scanned too
+	BackgroundScanInterval int  `json:"background_scan_interval_min"`
+	BackgroundScanAway     bool `json:"background_scan_away"`
+
+	TrayTitleTemplate   string `json:"tray_title_template"`
+	TrayTooltipTemplate string `json:"tray_tooltip_template"`
+
+	// How long the shutdown action gets to take effect before the fallback
+	// action runs and a failure alert goes out (0 disables the check)
+	ShutdownVerifySec      int    `json:"shutdown_verify_sec"`
+	ShutdownFallbackAction string `json:"shutdown_fallback_action"`
+	// Seconds after a shutdown attempt or cancellation during which another
+	// countdown is held back (0 disables the cooldown)
+	ShutdownCooldownSec int `json:"shutdown_cooldown_sec"`
+	// Seconds between the end of the grace period and the countdown during
+	// which a dialog offers to cancel or confirm the shutdown (0 disables)
+	ConfirmationDelaySec int `json:"confirmation_delay_sec"`
+	// Missed checks after which the session is locked while the grace
+	// period runs on towards the shutdown action (0 disables; must be lower
+	// than GraceChecks)
+	LockGraceChecks int `json:"lock_grace_checks"`
+
+	PreShutdownEnabled    bool   `json:"pre_shutdown_enabled"`
+	PreShutdownCommand    string `json:"pre_shutdown_command"`
+	PreShutdownTimeoutSec int    `json:"pre_shutdown_timeout_sec"`
+
+	CriticalWebhookURL      string `json:"critical_webhook_url"`
+	CriticalWebhookTemplate string `json:"critical_webhook_template"`
+
+	// Named home network, device and action sets; the fields above hold the
+	// active one. AutoSwitchProfile activates the profile whose home SSID is
+	// the current WiFi.
+	Profiles          map[string]Profile `json:"profiles,omitempty"`
+	ActiveProfile     string             `json:"active_profile"`
+	AutoSwitchProfile bool               `json:"auto_switch_profile"`
+
+	// Monitor names or hardware IDs (e.g. "DEL4231", "VID_17EF&PID_3066");
+	// while any attached peripheral matches one, the machine counts as safe
+	DisarmWhenDockedTo []string `json:"disarm_when_docked_to"`
 }
 
 // DefaultSettings returns settings with sensible defaults
 func DefaultSettings() Settings {
 	return Settings{
-		HomeSSID:       "",
-		PhoneIP:        "",
-		PhoneMAC:       "",
-		DetectionType:  DefaultDetectionType,
-		IsPaused:       false,
-		GraceChecks:    DefaultGraceChecks,
-		PollInterval:   DefaultPollInterval,
-		PingTimeoutMs:  DefaultPingTimeoutMs,
-		ShutdownDelay:  DefaultShutdownDelay,
-		ShutdownPIN:    "",
-		RequirePIN:     false,
-		ShutdownAction: DefaultShutdownAction,
-	}
-}
-
-// getSettingsPath returns the path to the settings file in %APPDATA%\HomeSentry
-func getSettingsPath() (string, error) {
-	appData := os.Getenv("APPDATA")
-	if appData == "" {
-		return "settings.json", nil
+		SchemaVersion:    CurrentSchemaVersion,
+		HomeSSID:         "",
+		HomeDetection:    DefaultHomeDetection,
+		PhoneIP:          "",
+		PhoneMAC:         "",
+		PhoneHostname:    "",
+		DetectionType:    DefaultDetectionType,
+		IsPaused:         false,
+		GraceChecks:      DefaultGraceChecks,
+		GraceAlertWindow: DefaultGraceAlertWindow,
+		PollInterval:     DefaultPollInterval,
+		PingTimeoutMs:    DefaultPingTimeoutMs,
+		ShutdownDelay:    DefaultShutdownDelay,
+		ShutdownPIN:      "",
+		RequirePIN:       false,
+		ShutdownAction:   DefaultShutdownAction,
+		ResolveHostnames: DefaultResolveHostnames,
+		ScanTimeoutSec:   DefaultScanTimeoutSec,
+		ScanExcludeSelf:  DefaultScanExcludeSelf,
+		LogRetentionDays: DefaultLogRetentionDays,
+		Theme:            DefaultTheme,
+
+		ShutdownVerifySec:      DefaultShutdownVerify,
+		ShutdownFallbackAction: DefaultShutdownFallback,
+		AutoUpdateDeviceIP:     DefaultAutoUpdateIP,
 	}
+}
 
-	dir := filepath.Join(appData, "HomeSentry")
+// getSettingsPath returns the path to the settings file in the app directory
+func getSettingsPath() (string, error) {
+	dir := paths.AppDir()
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return "", fmt.Errorf("failed to create config directory: %w", err)
 	}
@@ -120,13 +259,38 @@ func ValidatePIN(pin string) bool {
 // ValidateShutdownAction checks if the action is valid
 func ValidateShutdownAction(action string) bool {
 	switch action {
-	case ShutdownActionShutdown, ShutdownActionHibernate, ShutdownActionLock, ShutdownActionSleep:
+	case ShutdownActionShutdown, ShutdownActionHibernate, ShutdownActionLock, ShutdownActionSleep, ShutdownActionLogoff:
 		return true
 	default:
 		return false
 	}
 }
 
+// ValidateTheme checks if the menu theme is valid
+func ValidateTheme(theme string) bool {
+	switch theme {
+	case ThemeDark, ThemeLight, ThemeSystem:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateWebhookURL checks that the URL is an absolute http(s) URL
+func ValidateWebhookURL(raw string) bool {
+	if raw == "" {
+		return true
+	}
+	if len(raw) > MaxWebhookURLLength {
+		return false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 // ValidateSettings validates and sanitizes all settings fields loaded from disk.
 // Invalid fields are reset to safe defaults rather than rejecting the entire file.
 func ValidateSettings(s *Settings) []string {
@@ -165,6 +329,36 @@ func ValidateSettings(s *Settings) []string {
 		}
 	}
 
+	// Validate and sanitize PhoneHostname
+	if s.PhoneHostname != "" {
+		sanitized, err := SanitizeMDNSName(s.PhoneHostname)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("PhoneHostname invalid, reset to empty: %v", err))
+			s.PhoneHostname = ""
+		} else {
+			s.PhoneHostname = sanitized
+		}
+	}
+
+	// Validate HomeGatewayMAC
+	if s.HomeGatewayMAC != "" {
+		sanitized, err := SanitizeMAC(s.HomeGatewayMAC)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("HomeGatewayMAC invalid, reset to empty: %v", err))
+			s.HomeGatewayMAC = ""
+		} else {
+			s.HomeGatewayMAC = sanitized
+		}
+	}
+
+	// Validate HomeDetection (empty means not set)
+	if s.HomeDetection == "" {
+		s.HomeDetection = DefaultHomeDetection
+	} else if s.HomeDetection != HomeDetectionSSID && s.HomeDetection != HomeDetectionGateway {
+		warnings = append(warnings, fmt.Sprintf("HomeDetection invalid (%s), reset to default", s.HomeDetection))
+		s.HomeDetection = DefaultHomeDetection
+	}
+
 	// Validate ShutdownPIN
 	if s.ShutdownPIN != "" {
 		sanitized, err := SanitizePIN(s.ShutdownPIN)
@@ -178,22 +372,123 @@ func ValidateSettings(s *Settings) []string {
 	}
 
 	// Validate DetectionType
-	if s.DetectionType != DetectionTypeIP && s.DetectionType != DetectionTypeMAC {
+	if s.DetectionType != DetectionTypeIP && s.DetectionType != DetectionTypeMAC && s.DetectionType != DetectionTypeMDNS {
 		warnings = append(warnings, fmt.Sprintf("DetectionType invalid (%s), reset to default", s.DetectionType))
 		s.DetectionType = DefaultDetectionType
 	}
 
+	// Validate DetectionMode (empty means not set)
+	if s.DetectionMode == "" {
+		s.DetectionMode = DefaultDetectionMode
+	} else if s.DetectionMode != DetectionModeActive && s.DetectionMode != DetectionModePassive {
+		warnings = append(warnings, fmt.Sprintf("DetectionMode invalid (%s), reset to default", s.DetectionMode))
+		s.DetectionMode = DefaultDetectionMode
+	}
+
+	// Validate ARPRefresh (empty means not set)
+	switch s.ARPRefresh {
+	case "":
+		s.ARPRefresh = DefaultARPRefresh
+	case ARPRefreshAuto, ARPRefreshDelete, ARPRefreshProbe:
+	default:
+		warnings = append(warnings, fmt.Sprintf("ARPRefresh invalid (%s), reset to default", s.ARPRefresh))
+		s.ARPRefresh = DefaultARPRefresh
+	}
+
 	// Validate ShutdownAction
 	if !ValidateShutdownAction(s.ShutdownAction) {
 		warnings = append(warnings, fmt.Sprintf("ShutdownAction invalid (%s), reset to default", s.ShutdownAction))
 		s.ShutdownAction = DefaultShutdownAction
 	}
+	if s.BackgroundScanInterval < 0 || s.BackgroundScanInterval > MaxBackgroundScanMin {
+		warnings = append(warnings, fmt.Sprintf("BackgroundScanInterval out of range (%d), reset to disabled", s.BackgroundScanInterval))
+		s.BackgroundScanInterval = 0
+	}
+	if s.HoldLastHomeSec < 0 || s.HoldLastHomeSec > MaxHoldLastHomeSec {
+		warnings = append(warnings, fmt.Sprintf("HoldLastHomeSec out of range (%d), reset to disabled", s.HoldLastHomeSec))
+		s.HoldLastHomeSec = 0
+	}
+	if s.ShutdownCooldownSec < 0 || s.ShutdownCooldownSec > MaxShutdownCooldown {
+		warnings = append(warnings, fmt.Sprintf("ShutdownCooldownSec out of range (%d), reset to disabled", s.ShutdownCooldownSec))
+		s.ShutdownCooldownSec = 0
+	}
+	if s.ConfirmationDelaySec < 0 || s.ConfirmationDelaySec > MaxConfirmationDelay {
+		warnings = append(warnings, fmt.Sprintf("ConfirmationDelaySec out of range (%d), reset to disabled", s.ConfirmationDelaySec))
+		s.ConfirmationDelaySec = 0
+	}
+	if s.ShutdownVerifySec < 0 || s.ShutdownVerifySec > MaxShutdownVerifySec {
+		warnings = append(warnings, fmt.Sprintf("ShutdownVerifySec out of range (%d), reset to default", s.ShutdownVerifySec))
+		s.ShutdownVerifySec = DefaultShutdownVerify
+	}
+	// Empty means no fallback
+	if s.ShutdownFallbackAction != "" && !ValidateShutdownAction(s.ShutdownFallbackAction) {
+		warnings = append(warnings, fmt.Sprintf("ShutdownFallbackAction invalid (%s), reset to default", s.ShutdownFallbackAction))
+		s.ShutdownFallbackAction = DefaultShutdownFallback
+	}
+
+	// Validate DeviceName (empty means the OS hostname)
+	s.DeviceName = strings.TrimSpace(RemoveControlChars(s.DeviceName))
+	if len(s.DeviceName) > MaxDeviceNameLength {
+		warnings = append(warnings, fmt.Sprintf("DeviceName too long (%d bytes), using the hostname", len(s.DeviceName)))
+		s.DeviceName = ""
+	}
+
+	// Validate Theme (empty means not set)
+	if s.Theme == "" {
+		s.Theme = DefaultTheme
+	} else if !ValidateTheme(s.Theme) {
+		warnings = append(warnings, fmt.Sprintf("Theme invalid (%s), reset to default", s.Theme))
+		s.Theme = DefaultTheme
+	}
+
+	// The icon files themselves are checked when the tray loads them
+	if s.IconPackDir != "" && (len(s.IconPackDir) > MaxCommandPathLength || !filepath.IsAbs(s.IconPackDir)) {
+		warnings = append(warnings, "IconPackDir must be an absolute path, using built-in icons")
+		s.IconPackDir = ""
+	}
+
+	if len(s.TrayTitleTemplate) > MaxTrayTemplateLength {
+		warnings = append(warnings, "TrayTitleTemplate too long, using the default title")
+		s.TrayTitleTemplate = ""
+	}
+	if len(s.TrayTooltipTemplate) > MaxTrayTemplateLength {
+		warnings = append(warnings, "TrayTooltipTemplate too long, using the default tooltip")
+		s.TrayTooltipTemplate = ""
+	}
+
+	if err := ValidateWarningSoundFile(s.WarningSoundFile); err != nil {
+		warnings = append(warnings, fmt.Sprintf("WarningSoundFile invalid (%v), using the beep", err))
+		s.WarningSoundFile = ""
+	}
+
+	if s.QuietHoursStart < 0 || s.QuietHoursStart > 23 || s.QuietHoursEnd < 0 || s.QuietHoursEnd > 23 {
+		warnings = append(warnings, fmt.Sprintf("Quiet hours out of range (%d-%d), disabled", s.QuietHoursStart, s.QuietHoursEnd))
+		s.QuietHoursStart = 0
+		s.QuietHoursEnd = 0
+	}
 
 	// Validate numeric ranges
 	if s.GraceChecks < MinGraceChecks || s.GraceChecks > MaxGraceChecks {
 		warnings = append(warnings, fmt.Sprintf("GraceChecks out of range (%d), reset to default", s.GraceChecks))
 		s.GraceChecks = DefaultGraceChecks
 	}
+	if s.LockGraceChecks < 0 || s.LockGraceChecks > MaxGraceChecks {
+		warnings = append(warnings, fmt.Sprintf("LockGraceChecks out of range (%d), reset to disabled", s.LockGraceChecks))
+		s.LockGraceChecks = 0
+	} else if s.LockGraceChecks >= s.GraceChecks && s.LockGraceChecks > 0 {
+		warnings = append(warnings, fmt.Sprintf("LockGraceChecks (%d) must be lower than GraceChecks (%d), reset to disabled", s.LockGraceChecks, s.GraceChecks))
+		s.LockGraceChecks = 0
+	}
+	if s.GraceAlertCount < 0 || s.GraceAlertCount > MaxGraceAlertCount {
+		warnings = append(warnings, fmt.Sprintf("GraceAlertCount out of range (%d), reset to disabled", s.GraceAlertCount))
+		s.GraceAlertCount = 0
+	}
+	if s.GraceAlertWindow == 0 {
+		s.GraceAlertWindow = DefaultGraceAlertWindow // Not set
+	} else if s.GraceAlertWindow < 1 || s.GraceAlertWindow > MaxGraceAlertWindow {
+		warnings = append(warnings, fmt.Sprintf("GraceAlertWindow out of range (%d), reset to default", s.GraceAlertWindow))
+		s.GraceAlertWindow = DefaultGraceAlertWindow
+	}
 	if s.PollInterval < MinPollInterval || s.PollInterval > MaxPollInterval {
 		warnings = append(warnings, fmt.Sprintf("PollInterval out of range (%d), reset to default", s.PollInterval))
 		s.PollInterval = DefaultPollInterval
@@ -202,10 +497,274 @@ func ValidateSettings(s *Settings) []string {
 		warnings = append(warnings, fmt.Sprintf("ShutdownDelay out of range (%d), reset to default", s.ShutdownDelay))
 		s.ShutdownDelay = DefaultShutdownDelay
 	}
+	if s.PingCount == 0 {
+		s.PingCount = DefaultPingCount // Not set
+	} else if s.PingCount < MinPingCount || s.PingCount > MaxPingCount {
+		warnings = append(warnings, fmt.Sprintf("PingCount out of range (%d), reset to default", s.PingCount))
+		s.PingCount = DefaultPingCount
+	}
+	if s.ConfirmAbsenceMethods == 0 {
+		s.ConfirmAbsenceMethods = DefaultConfirmAbsence // Not set
+	} else if s.ConfirmAbsenceMethods < 1 || s.ConfirmAbsenceMethods > MaxConfirmAbsenceMethods {
+		warnings = append(warnings, fmt.Sprintf("ConfirmAbsenceMethods out of range (%d), reset to default", s.ConfirmAbsenceMethods))
+		s.ConfirmAbsenceMethods = DefaultConfirmAbsence
+	}
+	if s.PingPacketSize < 0 || s.PingPacketSize > MaxPingPacketSize {
+		warnings = append(warnings, fmt.Sprintf("PingPacketSize out of range (%d), reset to OS default", s.PingPacketSize))
+		s.PingPacketSize = 0
+	}
+
+	if len(s.ProbePorts) > 0 {
+		valid := make([]int, 0, len(s.ProbePorts))
+		for _, port := range s.ProbePorts {
+			if port < 1 || port > 65535 {
+				warnings = append(warnings, fmt.Sprintf("ProbePorts entry ignored: %d is not a TCP port", port))
+				continue
+			}
+			valid = append(valid, port)
+		}
+		if len(valid) > MaxProbePorts {
+			warnings = append(warnings, fmt.Sprintf("ProbePorts has more than %d ports, extra ports ignored", MaxProbePorts))
+			valid = valid[:MaxProbePorts]
+		}
+		s.ProbePorts = valid
+	}
+
+	if s.ScanTimeoutSec == 0 {
+		s.ScanTimeoutSec = DefaultScanTimeoutSec // Not set
+	} else if s.ScanTimeoutSec < 0 || s.ScanTimeoutSec > MaxScanTimeoutSec {
+		warnings = append(warnings, fmt.Sprintf("ScanTimeoutSec out of range (%d), reset to default", s.ScanTimeoutSec))
+		s.ScanTimeoutSec = DefaultScanTimeoutSec
+	}
+
+	if len(s.ScanCIDRs) > 0 {
+		valid := make([]string, 0, len(s.ScanCIDRs))
+		for _, cidr := range s.ScanCIDRs {
+			if err := ValidateScanCIDR(cidr); err != nil {
+				warnings = append(warnings, fmt.Sprintf("ScanCIDRs entry ignored: %v", err))
+				continue
+			}
+			valid = append(valid, strings.TrimSpace(cidr))
+		}
+		if len(valid) > MaxScanCIDRs {
+			warnings = append(warnings, fmt.Sprintf("ScanCIDRs has more than %d ranges, extra ranges ignored", MaxScanCIDRs))
+			valid = valid[:MaxScanCIDRs]
+		}
+		s.ScanCIDRs = valid
+	}
+
+	if len(s.DisarmWhenDockedTo) > 0 {
+		valid := make([]string, 0, len(s.DisarmWhenDockedTo))
+		for _, entry := range s.DisarmWhenDockedTo {
+			sanitized, err := SanitizeDockEntry(entry)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("DisarmWhenDockedTo entry ignored: %v", err))
+				continue
+			}
+			valid = append(valid, sanitized)
+		}
+		if len(valid) > MaxDockEntries {
+			warnings = append(warnings, fmt.Sprintf("DisarmWhenDockedTo has more than %d entries, extra entries ignored", MaxDockEntries))
+			valid = valid[:MaxDockEntries]
+		}
+		s.DisarmWhenDockedTo = valid
+	}
+
+	if len(s.ScanIgnoreList) > 0 {
+		valid := make([]string, 0, len(s.ScanIgnoreList))
+		for _, entry := range s.ScanIgnoreList {
+			sanitized, err := SanitizeScanIgnoreEntry(entry)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("ScanIgnoreList entry ignored: %v", err))
+				continue
+			}
+			valid = append(valid, sanitized)
+		}
+		if len(valid) > MaxScanIgnoreEntries {
+			warnings = append(warnings, fmt.Sprintf("ScanIgnoreList has more than %d entries, extra entries ignored", MaxScanIgnoreEntries))
+			valid = valid[:MaxScanIgnoreEntries]
+		}
+		s.ScanIgnoreList = valid
+	}
+
+	if s.LogRetentionDays < 0 || s.LogRetentionDays > MaxLogRetentionDays {
+		warnings = append(warnings, fmt.Sprintf("LogRetentionDays out of range (%d), reset to default", s.LogRetentionDays))
+		s.LogRetentionDays = DefaultLogRetentionDays
+	}
+
+	if s.MaxPauseMinutes < 0 || s.MaxPauseMinutes > MaxPauseLimitMinutes {
+		warnings = append(warnings, fmt.Sprintf("MaxPauseMinutes out of range (%d), reset to unlimited", s.MaxPauseMinutes))
+		s.MaxPauseMinutes = 0
+	}
+
+	if s.MaxSeenAgeHours < 0 || s.MaxSeenAgeHours > MaxSeenAgeLimitHours {
+		warnings = append(warnings, fmt.Sprintf("MaxSeenAgeHours out of range (%d), reset to disabled", s.MaxSeenAgeHours))
+		s.MaxSeenAgeHours = 0
+	}
+
+	// Validate pre-shutdown command
+	if err := ValidatePreShutdownCommand(s.PreShutdownCommand); err != nil {
+		warnings = append(warnings, fmt.Sprintf("PreShutdownCommand invalid (%v), disabled", err))
+		s.PreShutdownCommand = ""
+		s.PreShutdownEnabled = false
+	}
+	if s.PreShutdownTimeoutSec == 0 {
+		s.PreShutdownTimeoutSec = DefaultPreShutdownSec // Not set
+	} else if s.PreShutdownTimeoutSec < 0 || s.PreShutdownTimeoutSec > MaxPreShutdownSec {
+		warnings = append(warnings, fmt.Sprintf("PreShutdownTimeoutSec out of range (%d), reset to default", s.PreShutdownTimeoutSec))
+		s.PreShutdownTimeoutSec = DefaultPreShutdownSec
+	}
+
+	// Validate critical webhook
+	if !ValidateWebhookURL(s.CriticalWebhookURL) {
+		warnings = append(warnings, "CriticalWebhookURL invalid (must be an http or https URL), reset to empty")
+		s.CriticalWebhookURL = ""
+	}
+	if len(s.CriticalWebhookTemplate) > MaxWebhookTemplateLength {
+		warnings = append(warnings, fmt.Sprintf("CriticalWebhookTemplate too long (%d bytes), reset to default", len(s.CriticalWebhookTemplate)))
+		s.CriticalWebhookTemplate = ""
+	}
+
+	// Validate profiles; past the limit, names sorting last are dropped
+	for i, name := range s.ProfileNames() {
+		if err := ValidateProfileName(name); err != nil || i >= MaxProfiles {
+			if err == nil {
+				err = fmt.Errorf("more than %d profiles", MaxProfiles)
+			}
+			warnings = append(warnings, fmt.Sprintf("Profile %q removed: %v", name, err))
+			delete(s.Profiles, name)
+			continue
+		}
+		profile := s.Profiles[name]
+		for _, warning := range validateProfile(&profile) {
+			warnings = append(warnings, fmt.Sprintf("Profile %q: %s", name, warning))
+		}
+		s.Profiles[name] = profile
+	}
+	if _, ok := s.Profiles[s.ActiveProfile]; s.ActiveProfile != "" && !ok {
+		warnings = append(warnings, fmt.Sprintf("ActiveProfile %q does not exist, cleared", s.ActiveProfile))
+		s.ActiveProfile = ""
+	}
 
 	return warnings
 }
 
+// MaxSeenAge returns how recently the phone must have been seen for protection
+// to arm. Zero means a single sighting arms protection forever.
+func (s Settings) MaxSeenAge() time.Duration {
+	return time.Duration(s.MaxSeenAgeHours) * time.Hour
+}
+
+// ShutdownCooldown returns how long another countdown is held back after a
+// shutdown attempt or cancellation
+func (s Settings) ShutdownCooldown() time.Duration {
+	return time.Duration(s.ShutdownCooldownSec) * time.Second
+}
+
+// ConfirmationDelay returns how long the confirmation dialog waits before
+// the countdown starts
+func (s Settings) ConfirmationDelay() time.Duration {
+	return time.Duration(s.ConfirmationDelaySec) * time.Second
+}
+
+// HomeConfigured reports whether a home network has been set up for the
+// selected home detection
+func (s Settings) HomeConfigured() bool {
+	if s.HomeDetection == HomeDetectionGateway {
+		return s.HomeGatewayMAC != ""
+	}
+	return s.HomeSSID != ""
+}
+
+// WarningSoundPath returns the local path of the warning sound, or "" to use
+// the beep
+func (s Settings) WarningSoundPath() string {
+	return soundFileLocalPath(s.WarningSoundFile)
+}
+
+// InQuietHours reports whether t falls within the quiet hours, when warning
+// sounds are muted. The range may wrap past midnight (22 to 7); equal start
+// and end hours mean there are no quiet hours.
+func (s Settings) InQuietHours(t time.Time) bool {
+	if s.QuietHoursStart == s.QuietHoursEnd {
+		return false
+	}
+	hour := t.Hour()
+	if s.QuietHoursStart < s.QuietHoursEnd {
+		return hour >= s.QuietHoursStart && hour < s.QuietHoursEnd
+	}
+	return hour >= s.QuietHoursStart || hour < s.QuietHoursEnd
+}
+
+// GraceAlertPeriod returns the window in which GraceAlertCount grace periods
+// raise a flapping alert
+func (s Settings) GraceAlertPeriod() time.Duration {
+	return time.Duration(s.GraceAlertWindow) * time.Minute
+}
+
+// MaxPause returns how long a pause may last before protection resumes on
+// its own. Zero means pauses are unlimited.
+func (s Settings) MaxPause() time.Duration {
+	return time.Duration(s.MaxPauseMinutes) * time.Minute
+}
+
+// PauseEnds returns when the current pause will be lifted automatically:
+// the earlier of a timed pause's end and the MaxPause cap. It is the zero
+// time if protection isn't paused or the pause is open-ended.
+func (s Settings) PauseEnds() time.Time {
+	if !s.IsPaused {
+		return time.Time{}
+	}
+	ends := s.PausedUntil
+	if !s.PausedAt.IsZero() && s.MaxPause() > 0 {
+		if capped := s.PausedAt.Add(s.MaxPause()); ends.IsZero() || capped.Before(ends) {
+			ends = capped
+		}
+	}
+	return ends
+}
+
+// LogRetention returns how long log files are kept. Zero means forever.
+func (s Settings) LogRetention() time.Duration {
+	return time.Duration(s.LogRetentionDays) * 24 * time.Hour
+}
+
+// MachineName returns the name alerts use for this machine: DeviceName, or
+// the OS hostname when none is set
+func (s Settings) MachineName() string {
+	if s.DeviceName != "" {
+		return s.DeviceName
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "Unknown"
+	}
+	return hostname
+}
+
+// CriticalWebhookPayloadTemplate returns the configured template or the default one
+func (s Settings) CriticalWebhookPayloadTemplate() string {
+	if s.CriticalWebhookTemplate == "" {
+		return DefaultCriticalWebhookTemplate
+	}
+	return s.CriticalWebhookTemplate
+}
+
+// ShutdownFailedPayloadTemplate returns the configured template, which tells
+// the alerts apart through {status}, or the default shutdown-failed one
+func (s Settings) ShutdownFailedPayloadTemplate() string {
+	if s.CriticalWebhookTemplate == "" {
+		return ShutdownFailedWebhookTemplate
+	}
+	return s.CriticalWebhookTemplate
+}
+
+// PINRequiredToPause reports whether pausing protection must be confirmed
+// with the PIN. It has no effect until a PIN is configured.
+func (s Settings) PINRequiredToPause() bool {
+	return s.RequirePINToPause && s.RequirePIN && s.ShutdownPIN != ""
+}
+
 // VerifyPIN checks if the provided PIN matches the stored PIN using constant-time comparison
 func (s Settings) VerifyPIN(pin string) bool {
 	if !s.RequirePIN || s.ShutdownPIN == "" {
@@ -220,43 +779,91 @@ func Load() (Settings, error) {
 	return loadLocked()
 }
 
+// LoadWithWarnings is Load that also returns the ValidateSettings warnings,
+// one per value that was invalid and replaced in the returned settings
+func LoadWithWarnings() (Settings, []string, error) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	return loadLockedWithWarnings()
+}
+
 // loadLocked performs the actual load. Caller must hold settingsMu.
 func loadLocked() (Settings, error) {
+	settings, _, err := loadLockedWithWarnings()
+	return settings, err
+}
+
+func loadLockedWithWarnings() (Settings, []string, error) {
+	decrypted, _, err := readLocked()
+	if err != nil {
+		return DefaultSettings(), nil, err
+	}
+
+	// Validate and sanitize all fields loaded from disk
+	warnings := ValidateSettings(decrypted)
+
+	// Ensure minimum values for fields not covered by ValidateSettings range checks
+	if decrypted.PingTimeoutMs < 100 {
+		decrypted.PingTimeoutMs = DefaultPingTimeoutMs
+	}
+
+	return *decrypted, warnings, nil
+}
+
+// readLocked reads, migrates and decrypts the settings file without
+// validating it. A missing file yields the defaults. plain reports that the
+// sensitive fields could not be decrypted and were kept as stored, which is
+// expected for unencrypted legacy files.
+func readLocked() (settings *Settings, plain bool, err error) {
 	path, err := getSettingsPath()
 	if err != nil {
-		return DefaultSettings(), err
+		return nil, false, newError(ErrIO, "failed to locate settings", err)
 	}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return DefaultSettings(), nil
+			defaults := DefaultSettings()
+			return &defaults, false, nil
 		}
-		return DefaultSettings(), err
+		return nil, false, newError(ErrIO, "failed to read settings", err)
 	}
 
-	settings := DefaultSettings()
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return DefaultSettings(), err
+	migrated, err := migrate(data)
+	if err != nil {
+		return nil, false, newError(ErrIO, "failed to parse settings", err)
 	}
 
 	// Decrypt sensitive fields
-	decrypted, err := DecryptSettings(&settings)
+	decrypted, err := DecryptSettings(&migrated)
 	if err != nil {
 		// If decryption fails, might be unencrypted legacy settings
-		// Continue with potentially unencrypted data but log the warning
-		decrypted = &settings
+		return &migrated, true, nil
 	}
+	return decrypted, false, nil
+}
 
-	// Validate and sanitize all fields loaded from disk
-	ValidateSettings(decrypted)
+// Validate checks the settings file the way Load does and returns every
+// warning ValidateSettings raises, i.e. each value Load would replace. Nothing
+// is written back and no encryption key is created. A missing file is valid.
+func Validate() ([]string, error) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
 
-	// Ensure minimum values for fields not covered by ValidateSettings range checks
-	if decrypted.PingTimeoutMs < 100 {
-		decrypted.PingTimeoutMs = DefaultPingTimeoutMs
+	_, keyErr := readKey()
+	settings, plain, err := readLocked()
+	if err != nil {
+		return nil, err
 	}
 
-	return *decrypted, nil
+	var warnings []string
+	switch {
+	case plain && errors.Is(keyErr, os.ErrNotExist):
+		warnings = append(warnings, fmt.Sprintf("No encryption key at %s, encrypted fields are read as plain text", getKeyPath()))
+	case plain:
+		warnings = append(warnings, "Encrypted fields could not be decrypted, they are read as plain text")
+	}
+	return append(warnings, ValidateSettings(settings)...), nil
 }
 
 func Save(settings Settings) error {
@@ -265,52 +872,71 @@ func Save(settings Settings) error {
 	return saveLocked(settings)
 }
 
+// writeTemp writes the encoded settings to the temp file; tests replace it to
+// simulate a crash partway through the write
+var writeTemp = func(w io.Writer, data []byte) (int, error) {
+	return w.Write(data)
+}
+
+// renameAttempts bounds the retries of the final rename, which fails on
+// Windows while another process has settings.json open
+const renameAttempts = 5
+
 // saveLocked performs the actual save with atomic write. Caller must hold settingsMu.
 func saveLocked(settings Settings) error {
 	path, err := getSettingsPath()
 	if err != nil {
-		return err
+		return newError(ErrIO, "failed to locate settings", err)
 	}
 
+	settings.SchemaVersion = CurrentSchemaVersion
+
 	// Encrypt sensitive fields before saving
 	encrypted, err := EncryptSettings(&settings)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt settings: %w", err)
+		return newError(ErrEncryption, "failed to encrypt settings", err)
 	}
 
 	data, err := json.MarshalIndent(encrypted, "", "  ")
 	if err != nil {
-		return err
+		return newError(ErrIO, "failed to encode settings", err)
 	}
 
 	// Atomic write: write to temp file, then rename to avoid corruption on crash
 	dir := filepath.Dir(path)
 	tmpFile, err := os.CreateTemp(dir, "settings-*.tmp")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return newError(ErrIO, "failed to create temp file", err)
 	}
 	tmpPath := tmpFile.Name()
 
-	if _, err := tmpFile.Write(data); err != nil {
+	if _, err := writeTemp(tmpFile, data); err != nil {
 		tmpFile.Close()
 		os.Remove(tmpPath)
-		return fmt.Errorf("failed to write temp file: %w", err)
+		return newError(ErrIO, "failed to write temp file", err)
 	}
 	if err := tmpFile.Close(); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("failed to close temp file: %w", err)
+		return newError(ErrIO, "failed to close temp file", err)
 	}
 
 	// Set permissions before rename
 	if err := os.Chmod(tmpPath, 0600); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("failed to set file permissions: %w", err)
+		return newError(ErrIO, "failed to set file permissions", err)
 	}
 
 	// Atomic rename (on Windows, os.Rename replaces existing files)
-	if err := os.Rename(tmpPath, path); err != nil {
+	for attempt := 1; ; attempt++ {
+		err = os.Rename(tmpPath, path)
+		if err == nil || attempt == renameAttempts {
+			break
+		}
+		time.Sleep(time.Duration(attempt) * 10 * time.Millisecond)
+	}
+	if err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("failed to rename temp file: %w", err)
+		return newError(ErrIO, "failed to rename temp file", err)
 	}
 
 	return nil
@@ -375,7 +1001,29 @@ func UpdateDevice(ip, mac string, detectionType DetectionType) error {
 	return saveLocked(settings)
 }
 
-// SetDetectionType sets the detection type (ip or mac)
+// SetHomeGateway recognizes home by the given default gateway MAC from now on
+func SetHomeGateway(mac string) error {
+	sanitizedMAC, err := SanitizeMAC(mac)
+	if err != nil {
+		return err
+	}
+	if sanitizedMAC == "" {
+		return NewValidationError("HomeGatewayMAC", "gateway MAC address is required")
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	settings.HomeGatewayMAC = sanitizedMAC
+	settings.HomeDetection = HomeDetectionGateway
+	return saveLocked(settings)
+}
+
+// SetDetectionType sets the detection type (ip, mac or mdns)
 func SetDetectionType(detectionType DetectionType) error {
 	settingsMu.Lock()
 	defer settingsMu.Unlock()
@@ -396,16 +1044,66 @@ func SetPaused(paused bool) error {
 	if err != nil {
 		return fmt.Errorf("failed to load settings: %w", err)
 	}
+	// Keep the original start when pausing again, so the cap can't be extended
+	if paused && !settings.IsPaused {
+		settings.PausedAt = time.Now()
+	} else if !paused {
+		settings.PausedAt = time.Time{}
+	}
 	settings.IsPaused = paused
+	settings.PausedUntil = time.Time{}
+	return saveLocked(settings)
+}
+
+// SetPausedFor pauses protection for d and returns when it will resume,
+// which is earlier than d if MaxPauseMinutes caps the pause
+func SetPausedFor(d time.Duration) (time.Time, error) {
+	if d < time.Minute || d > MaxPauseLimitMinutes*time.Minute {
+		return time.Time{}, NewValidationError("PauseDuration", fmt.Sprintf("pause duration must be between 1 minute and %d days", MaxPauseLimitMinutes/(24*60)))
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load settings: %w", err)
+	}
+	now := time.Now()
+	if !settings.IsPaused {
+		settings.PausedAt = now
+	}
+	settings.IsPaused = true
+	settings.PausedUntil = now.Add(d)
+	if err := saveLocked(settings); err != nil {
+		return time.Time{}, err
+	}
+	return settings.PauseEnds(), nil
+}
+
+// SetMaxPauseMinutes sets how long a pause may last (0 for unlimited)
+func SetMaxPauseMinutes(minutes int) error {
+	if minutes < 0 || minutes > MaxPauseLimitMinutes {
+		return NewValidationError("MaxPauseMinutes", fmt.Sprintf("pause limit must be between 0 and %d minutes", MaxPauseLimitMinutes))
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	settings.MaxPauseMinutes = minutes
 	return saveLocked(settings)
 }
 
 func SetShutdownDelay(seconds int) error {
 	if seconds < ShutdownMinDelay {
-		return fmt.Errorf("shutdown delay must be at least %d seconds", ShutdownMinDelay)
+		return NewValidationError("ShutdownDelay", fmt.Sprintf("shutdown delay must be at least %d seconds", ShutdownMinDelay))
 	}
 	if seconds > ShutdownMaxDelay {
-		return fmt.Errorf("shutdown delay must be at most %d seconds", ShutdownMaxDelay)
+		return NewValidationError("ShutdownDelay", fmt.Sprintf("shutdown delay must be at most %d seconds", ShutdownMaxDelay))
 	}
 
 	settingsMu.Lock()
@@ -419,10 +1117,27 @@ func SetShutdownDelay(seconds int) error {
 	return saveLocked(settings)
 }
 
+// SetTheme sets the menu theme (dark, light or system)
+func SetTheme(theme string) error {
+	if !ValidateTheme(theme) {
+		return NewValidationError("Theme", fmt.Sprintf("theme must be %q, %q or %q", ThemeDark, ThemeLight, ThemeSystem))
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	settings.Theme = theme
+	return saveLocked(settings)
+}
+
 // SetShutdownPIN sets the PIN required for shutdown confirmation
 func SetShutdownPIN(pin string) error {
 	if !ValidatePIN(pin) {
-		return fmt.Errorf("PIN must be %d-%d digits", MinPINLength, MaxPINLength)
+		return NewValidationError("ShutdownPIN", fmt.Sprintf("PIN must be %d-%d digits", MinPINLength, MaxPINLength))
 	}
 
 	settingsMu.Lock()
@@ -450,10 +1165,24 @@ func SetRequirePIN(require bool) error {
 	return saveLocked(settings)
 }
 
+// SetAutoStartWanted records whether the user wants Home Sentry to start
+// with Windows, independently of the registry entry itself
+func SetAutoStartWanted(wanted bool) error {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	settings.AutoStartWanted = wanted
+	return saveLocked(settings)
+}
+
 // SetShutdownAction sets the action to take when protection triggers
 func SetShutdownAction(action string) error {
 	if !ValidateShutdownAction(action) {
-		return fmt.Errorf("invalid shutdown action: %s (valid: shutdown, hibernate, lock, sleep)", action)
+		return NewValidationError("ShutdownAction", fmt.Sprintf("invalid shutdown action: %s (valid: shutdown, hibernate, lock, sleep, logoff)", action))
 	}
 
 	settingsMu.Lock()
@@ -478,6 +1207,8 @@ func (s Settings) HasDeviceConfigured() bool {
 	switch s.DetectionType {
 	case DetectionTypeMAC:
 		return s.PhoneMAC != ""
+	case DetectionTypeMDNS:
+		return s.PhoneHostname != ""
 	default:
 		return s.PhoneIP != "" && s.PhoneIP != "0.0.0.0"
 	}
@@ -488,6 +1219,8 @@ func (s Settings) GetDeviceIdentifier() string {
 	switch s.DetectionType {
 	case DetectionTypeMAC:
 		return s.PhoneMAC
+	case DetectionTypeMDNS:
+		return s.PhoneHostname
 	default:
 		return s.PhoneIP
 	}
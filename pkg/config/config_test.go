@@ -1,9 +1,17 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestValidateIP(t *testing.T) {
@@ -259,6 +267,47 @@ func TestSanitizeHostname(t *testing.T) {
 	}
 }
 
+func TestSanitizeMDNSName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantErr  bool
+		expected string
+	}{
+		{"full name", "Johns-iPhone.local", false, "Johns-iPhone.local"},
+		{"bare name gets .local", " Johns-iPhone ", false, "Johns-iPhone.local"},
+		{"trailing dot", "phone.local.", false, "phone.local"},
+		{"empty string", "", false, ""},
+		{"space in label", "John's iPhone", true, ""},
+		{"empty label", "phone..local", true, ""},
+		{"leading hyphen", "-phone.local", true, ""},
+		{"label too long", strings.Repeat("a", 64) + ".local", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SanitizeMDNSName(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SanitizeMDNSName(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && result != tt.expected {
+				t.Errorf("SanitizeMDNSName(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+
+	s := DefaultSettings()
+	s.DetectionType = DetectionTypeMDNS
+	s.PhoneHostname = "Johns-iPhone"
+	if warnings := ValidateSettings(&s); len(warnings) != 0 {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+	if !s.HasDeviceConfigured() || s.GetDeviceIdentifier() != "Johns-iPhone.local" {
+		t.Errorf("mDNS device = %q, configured %v", s.GetDeviceIdentifier(), s.HasDeviceConfigured())
+	}
+}
+
 func TestSanitizeDisplayString(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -398,6 +447,88 @@ func TestValidateSettings(t *testing.T) {
 		}
 	})
 
+	t.Run("background scan interval", func(t *testing.T) {
+		s := DefaultSettings()
+		s.BackgroundScanInterval = 30
+		if warnings := ValidateSettings(&s); len(warnings) != 0 || s.BackgroundScanInterval != 30 {
+			t.Errorf("BackgroundScanInterval = %d with warnings %v, want 30 kept", s.BackgroundScanInterval, warnings)
+		}
+
+		s.BackgroundScanInterval = MaxBackgroundScanMin + 1
+		if warnings := ValidateSettings(&s); len(warnings) == 0 || s.BackgroundScanInterval != 0 {
+			t.Errorf("BackgroundScanInterval = %d with warnings %v, want disabled with a warning", s.BackgroundScanInterval, warnings)
+		}
+	})
+
+	t.Run("device name", func(t *testing.T) {
+		s := DefaultSettings()
+		s.DeviceName = "  Office\x07 Laptop "
+		if warnings := ValidateSettings(&s); len(warnings) != 0 || s.DeviceName != "Office Laptop" {
+			t.Errorf("DeviceName = %q with warnings %v, want %q", s.DeviceName, warnings, "Office Laptop")
+		}
+		if got := s.MachineName(); got != "Office Laptop" {
+			t.Errorf("MachineName() = %q, want the device name", got)
+		}
+
+		s.DeviceName = strings.Repeat("x", MaxDeviceNameLength+1)
+		if warnings := ValidateSettings(&s); len(warnings) == 0 || s.DeviceName != "" {
+			t.Errorf("Long DeviceName = %q with warnings %v, want reset with a warning", s.DeviceName, warnings)
+		}
+		if hostname, err := os.Hostname(); err == nil && s.MachineName() != hostname {
+			t.Errorf("MachineName() = %q, want the hostname %q", s.MachineName(), hostname)
+		}
+	})
+
+	t.Run("shutdown verification", func(t *testing.T) {
+		s := DefaultSettings()
+		s.ShutdownVerifySec = MaxShutdownVerifySec + 1
+		s.ShutdownFallbackAction = "format_c_drive"
+		if warnings := ValidateSettings(&s); len(warnings) != 2 {
+			t.Errorf("Expected 2 warnings, got %v", warnings)
+		}
+		if s.ShutdownVerifySec != DefaultShutdownVerify || s.ShutdownFallbackAction != DefaultShutdownFallback {
+			t.Errorf("Got verify %d, fallback %q, want the defaults", s.ShutdownVerifySec, s.ShutdownFallbackAction)
+		}
+
+		s.ShutdownVerifySec = 0
+		s.ShutdownFallbackAction = ""
+		if warnings := ValidateSettings(&s); len(warnings) != 0 || s.ShutdownVerifySec != 0 || s.ShutdownFallbackAction != "" {
+			t.Errorf("Disabled verification and fallback should be kept, got %d %q %v", s.ShutdownVerifySec, s.ShutdownFallbackAction, warnings)
+		}
+	})
+
+	t.Run("shutdown cooldown", func(t *testing.T) {
+		s := DefaultSettings()
+		s.ShutdownCooldownSec = MaxShutdownCooldown + 1
+		if warnings := ValidateSettings(&s); len(warnings) == 0 || s.ShutdownCooldownSec != 0 {
+			t.Errorf("ShutdownCooldownSec = %d with warnings %v, want disabled with a warning", s.ShutdownCooldownSec, warnings)
+		}
+	})
+
+	t.Run("confirm absence methods", func(t *testing.T) {
+		s := DefaultSettings()
+		if warnings := ValidateSettings(&s); len(warnings) != 0 || s.ConfirmAbsenceMethods != DefaultConfirmAbsence {
+			t.Errorf("Unset ConfirmAbsenceMethods = %d with warnings %v, want %d silently", s.ConfirmAbsenceMethods, warnings, DefaultConfirmAbsence)
+		}
+
+		s.ConfirmAbsenceMethods = MaxConfirmAbsenceMethods + 1
+		if warnings := ValidateSettings(&s); len(warnings) == 0 || s.ConfirmAbsenceMethods != DefaultConfirmAbsence {
+			t.Errorf("ConfirmAbsenceMethods = %d with warnings %v, want reset with a warning", s.ConfirmAbsenceMethods, warnings)
+		}
+	})
+
+	t.Run("ARP refresh", func(t *testing.T) {
+		s := DefaultSettings()
+		if warnings := ValidateSettings(&s); len(warnings) != 0 || s.ARPRefresh != DefaultARPRefresh {
+			t.Errorf("Unset ARPRefresh = %q with warnings %v, want %q silently", s.ARPRefresh, warnings, DefaultARPRefresh)
+		}
+
+		s.ARPRefresh = "flush"
+		if warnings := ValidateSettings(&s); len(warnings) == 0 || s.ARPRefresh != DefaultARPRefresh {
+			t.Errorf("Invalid ARPRefresh = %q with warnings %v, want reset with a warning", s.ARPRefresh, warnings)
+		}
+	})
+
 	t.Run("invalid shutdown action", func(t *testing.T) {
 		s := Settings{
 			DetectionType:  DetectionTypeIP,
@@ -436,6 +567,105 @@ func TestValidateSettings(t *testing.T) {
 			t.Error("RequirePIN should be reset to false when PIN is invalid")
 		}
 	})
+
+	t.Run("theme", func(t *testing.T) {
+		s := DefaultSettings()
+		s.Theme = ""
+		if warnings := ValidateSettings(&s); len(warnings) != 0 {
+			t.Errorf("Unset theme should default silently, got %v", warnings)
+		}
+		if s.Theme != DefaultTheme {
+			t.Errorf("Unset theme = %q, want %q", s.Theme, DefaultTheme)
+		}
+
+		s.Theme = "neon"
+		if warnings := ValidateSettings(&s); len(warnings) == 0 {
+			t.Error("Expected warnings for invalid theme")
+		}
+		if s.Theme != DefaultTheme {
+			t.Errorf("Invalid theme should be reset to default, got %q", s.Theme)
+		}
+
+		s.Theme = ThemeLight
+		ValidateSettings(&s)
+		if s.Theme != ThemeLight {
+			t.Errorf("Valid theme changed to %q", s.Theme)
+		}
+	})
+
+	t.Run("icon pack dir", func(t *testing.T) {
+		s := DefaultSettings()
+		s.IconPackDir = "icons"
+		if warnings := ValidateSettings(&s); len(warnings) == 0 {
+			t.Error("Expected warnings for relative icon pack dir")
+		}
+		if s.IconPackDir != "" {
+			t.Errorf("Relative icon pack dir should be cleared, got %q", s.IconPackDir)
+		}
+
+		dir := t.TempDir()
+		s.IconPackDir = dir
+		ValidateSettings(&s)
+		if s.IconPackDir != dir {
+			t.Errorf("Absolute icon pack dir changed to %q", s.IconPackDir)
+		}
+	})
+
+	t.Run("grace alert", func(t *testing.T) {
+		s := DefaultSettings()
+		s.GraceAlertCount = -1
+		s.GraceAlertWindow = MaxGraceAlertWindow + 1
+		if warnings := ValidateSettings(&s); len(warnings) != 2 {
+			t.Errorf("Expected 2 warnings for grace alert settings, got %v", warnings)
+		}
+		if s.GraceAlertCount != 0 || s.GraceAlertWindow != DefaultGraceAlertWindow {
+			t.Errorf("Grace alert settings = %d in %d min, want disabled in default window", s.GraceAlertCount, s.GraceAlertWindow)
+		}
+
+		s.GraceAlertCount = 3
+		s.GraceAlertWindow = 0
+		if warnings := ValidateSettings(&s); len(warnings) != 0 {
+			t.Errorf("Unset window should default silently, got %v", warnings)
+		}
+		if s.GraceAlertPeriod() != DefaultGraceAlertWindow*time.Minute {
+			t.Errorf("GraceAlertPeriod() = %v, want %d minutes", s.GraceAlertPeriod(), DefaultGraceAlertWindow)
+		}
+	})
+}
+
+func TestValidatePingSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		count        int
+		packetSize   int
+		wantWarnings bool
+		wantCount    int
+		wantSize     int
+	}{
+		{"unset count uses default", 0, 0, false, DefaultPingCount, 0},
+		{"valid values", 3, 64, false, 3, 64},
+		{"count too high", 9, 0, true, DefaultPingCount, 0},
+		{"negative count", -1, 0, true, DefaultPingCount, 0},
+		{"packet size too large", 2, 70000, true, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := DefaultSettings()
+			s.PingCount = tt.count
+			s.PingPacketSize = tt.packetSize
+			warnings := ValidateSettings(&s)
+			if (len(warnings) > 0) != tt.wantWarnings {
+				t.Errorf("warnings = %v, wantWarnings %v", warnings, tt.wantWarnings)
+			}
+			if s.PingCount != tt.wantCount {
+				t.Errorf("PingCount = %d, want %d", s.PingCount, tt.wantCount)
+			}
+			if s.PingPacketSize != tt.wantSize {
+				t.Errorf("PingPacketSize = %d, want %d", s.PingPacketSize, tt.wantSize)
+			}
+		})
+	}
 }
 
 func TestLoadWithMaliciousSettings(t *testing.T) {
@@ -492,3 +722,760 @@ func TestLoadWithMaliciousSettings(t *testing.T) {
 		t.Errorf("Malicious PIN should be reset, got %q", loaded.ShutdownPIN)
 	}
 }
+
+func TestValidatePreShutdownCommand(t *testing.T) {
+	profile := t.TempDir()
+	t.Setenv("USERPROFILE", profile)
+
+	script := filepath.Join(profile, "lock-vault.bat")
+	if err := os.WriteFile(script, []byte("@echo off\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "elsewhere.bat")
+	if err := os.WriteFile(outside, []byte("@echo off\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	wrongExt := filepath.Join(profile, "notes.txt")
+	if err := os.WriteFile(wrongExt, []byte("hi"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"empty is allowed", "", false},
+		{"script in profile", script, false},
+		{"relative path", "lock-vault.bat", true},
+		{"outside profile", outside, true},
+		{"traversal out of profile", filepath.Join(profile, "..", filepath.Base(filepath.Dir(outside)), "elsewhere.bat"), true},
+		{"missing file", filepath.Join(profile, "missing.exe"), true},
+		{"disallowed extension", wrongExt, true},
+		{"shell string", script + " && del /q C:\\", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePreShutdownCommand(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePreShutdownCommand(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		err := SetShutdownDelay(1)
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("SetShutdownDelay(1) error = %v, want ErrValidation", err)
+		}
+		if _, err := SanitizeMAC("not-a-mac"); !errors.Is(err, ErrValidation) {
+			t.Errorf("SanitizeMAC error = %v, want ErrValidation", err)
+		}
+	})
+
+	t.Run("io", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		t.Setenv("APPDATA", tmpDir)
+		// A directory where the settings file should be makes the read fail
+		if err := os.MkdirAll(filepath.Join(tmpDir, "HomeSentry", "settings.json"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load()
+		if !errors.Is(err, ErrIO) {
+			t.Errorf("Load() error = %v, want ErrIO", err)
+		}
+		if errors.Is(err, ErrValidation) {
+			t.Error("I/O error should not match ErrValidation")
+		}
+	})
+}
+
+func TestPINRequiredToPause(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		want     bool
+	}{
+		{"disabled", Settings{ShutdownPIN: "1234", RequirePIN: true}, false},
+		{"enabled with PIN", Settings{RequirePINToPause: true, ShutdownPIN: "1234", RequirePIN: true}, true},
+		{"enabled without PIN", Settings{RequirePINToPause: true}, false},
+		{"PIN not required", Settings{RequirePINToPause: true, ShutdownPIN: "1234"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.PINRequiredToPause(); got != tt.want {
+				t.Errorf("PINRequiredToPause() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShutdownFailedPayloadTemplate(t *testing.T) {
+	if got := (Settings{}).ShutdownFailedPayloadTemplate(); got != ShutdownFailedWebhookTemplate {
+		t.Errorf("Default template = %q, want the shutdown-failed one", got)
+	}
+	custom := Settings{CriticalWebhookTemplate: `{"text":"{status}: {device}"}`}
+	if got := custom.ShutdownFailedPayloadTemplate(); got != custom.CriticalWebhookTemplate {
+		t.Errorf("Custom template = %q, want the user's %q", got, custom.CriticalWebhookTemplate)
+	}
+}
+
+func TestSetPausedRecordsStart(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+
+	if err := SetPaused(true); err != nil {
+		t.Fatalf("SetPaused(true) error = %v", err)
+	}
+	first, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.PausedAt.IsZero() {
+		t.Fatal("PausedAt should be recorded when pausing")
+	}
+
+	// Pausing again must not push the start (and the cap) back
+	if err := SetPaused(true); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := Load()
+	if !again.PausedAt.Equal(first.PausedAt) {
+		t.Errorf("PausedAt moved from %v to %v on a repeated pause", first.PausedAt, again.PausedAt)
+	}
+
+	if err := SetMaxPauseMinutes(60); err != nil {
+		t.Fatal(err)
+	}
+	capped, _ := Load()
+	if want := first.PausedAt.Add(time.Hour); !capped.PauseEnds().Equal(want) {
+		t.Errorf("PauseEnds() = %v, want %v", capped.PauseEnds(), want)
+	}
+
+	if err := SetPaused(false); err != nil {
+		t.Fatal(err)
+	}
+	resumed, _ := Load()
+	if !resumed.PausedAt.IsZero() || !resumed.PauseEnds().IsZero() {
+		t.Errorf("Resuming should clear the pause start, got %v", resumed.PausedAt)
+	}
+
+	if err := SetMaxPauseMinutes(-1); !errors.Is(err, ErrValidation) {
+		t.Errorf("SetMaxPauseMinutes(-1) error = %v, want validation error", err)
+	}
+}
+
+func TestSetPausedFor(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+
+	before := time.Now()
+	ends, err := SetPausedFor(2 * time.Hour)
+	if err != nil {
+		t.Fatalf("SetPausedFor() error = %v", err)
+	}
+	if ends.Before(before.Add(2*time.Hour)) || ends.After(time.Now().Add(2*time.Hour)) {
+		t.Errorf("SetPausedFor(2h) resumes at %v, want about 2h from now", ends)
+	}
+
+	// A shorter pause limit wins
+	if err := SetMaxPauseMinutes(30); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ := Load()
+	if got := loaded.PauseEnds(); !got.Equal(loaded.PausedAt.Add(30 * time.Minute)) {
+		t.Errorf("PauseEnds() = %v, want the 30 minute cap", got)
+	}
+
+	// A plain pause is open-ended again
+	if err := SetMaxPauseMinutes(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPaused(true); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ = Load()
+	if !loaded.PauseEnds().IsZero() {
+		t.Errorf("PauseEnds() after a plain pause = %v, want zero", loaded.PauseEnds())
+	}
+
+	for _, d := range []time.Duration{0, time.Second, -time.Hour, (MaxPauseLimitMinutes + 1) * time.Minute} {
+		if _, err := SetPausedFor(d); !errors.Is(err, ErrValidation) {
+			t.Errorf("SetPausedFor(%v) error = %v, want validation error", d, err)
+		}
+	}
+}
+
+func TestValidateScanCIDRs(t *testing.T) {
+	for _, cidr := range []string{"192.168.2.0/24", "10.0.0.0/20", "10.0.5.7/32"} {
+		if err := ValidateScanCIDR(cidr); err != nil {
+			t.Errorf("ValidateScanCIDR(%q) error = %v", cidr, err)
+		}
+	}
+	for _, cidr := range []string{"", "192.168.2.0", "10.0.0.0/8", "fd00::/64", "192.168.2.0/33"} {
+		if err := ValidateScanCIDR(cidr); !errors.Is(err, ErrValidation) {
+			t.Errorf("ValidateScanCIDR(%q) error = %v, want validation error", cidr, err)
+		}
+	}
+
+	s := DefaultSettings()
+	s.ScanCIDRs = []string{" 192.168.2.0/24 ", "10.0.0.0/8"}
+	if warnings := ValidateSettings(&s); len(warnings) != 1 {
+		t.Errorf("Expected one warning for the oversized range, got %v", warnings)
+	}
+	if len(s.ScanCIDRs) != 1 || s.ScanCIDRs[0] != "192.168.2.0/24" {
+		t.Errorf("ScanCIDRs = %v, want [192.168.2.0/24]", s.ScanCIDRs)
+	}
+}
+
+func TestValidateProbePorts(t *testing.T) {
+	s := DefaultSettings()
+	s.ProbePorts = []int{62078, 0, 70000, 443}
+	if warnings := ValidateSettings(&s); len(warnings) != 2 {
+		t.Errorf("Expected warnings for the two invalid ports, got %v", warnings)
+	}
+	if !reflect.DeepEqual(s.ProbePorts, []int{62078, 443}) {
+		t.Errorf("ProbePorts = %v, want [62078 443]", s.ProbePorts)
+	}
+}
+
+func TestValidateLockGraceChecks(t *testing.T) {
+	tests := []struct {
+		lock, grace int
+		want        int
+		warns       bool
+	}{
+		{0, 5, 0, false},
+		{3, 5, 3, false},
+		{5, 5, 0, true},
+		{-1, 5, 0, true},
+		{MaxGraceChecks + 1, MaxGraceChecks, 0, true},
+	}
+	for _, tt := range tests {
+		s := DefaultSettings()
+		s.LockGraceChecks = tt.lock
+		s.GraceChecks = tt.grace
+		warnings := ValidateSettings(&s)
+		if s.LockGraceChecks != tt.want || (len(warnings) > 0) != tt.warns {
+			t.Errorf("LockGraceChecks %d with GraceChecks %d: got %d and warnings %v, want %d", tt.lock, tt.grace, s.LockGraceChecks, warnings, tt.want)
+		}
+	}
+}
+
+func TestValidateDisarmWhenDockedTo(t *testing.T) {
+	s := DefaultSettings()
+	s.DisarmWhenDockedTo = []string{
+		` USB\VID_17EF&PID_3066\5&2A1B2C3D&0&1 `, "", strings.Repeat("x", MaxDockEntryLength+1),
+		"DISPLAY\\DEL4231\\5&1A2B3C4D&0&UID4353\x00",
+		"DEL4231", "VID_17EF&PID_3066", `USB\VID_17EF&PID_3066`, "Monitor", `USB\VID_17EF&PID_3066\`,
+	}
+	if warnings := ValidateSettings(&s); len(warnings) != 7 {
+		t.Errorf("Expected warnings for the empty, long and model-only entries, got %v", warnings)
+	}
+	want := []string{`USB\VID_17EF&PID_3066\5&2A1B2C3D&0&1`, `DISPLAY\DEL4231\5&1A2B3C4D&0&UID4353`}
+	if !reflect.DeepEqual(s.DisarmWhenDockedTo, want) {
+		t.Errorf("DisarmWhenDockedTo = %v, want %v", s.DisarmWhenDockedTo, want)
+	}
+}
+
+func TestValidateScanIgnoreList(t *testing.T) {
+	s := DefaultSettings()
+	s.ScanIgnoreList = []string{"B8:27:EB", " Espressif ", "  ", "ab-cd", strings.Repeat("x", MaxScanIgnoreEntryLength+1)}
+	if warnings := ValidateSettings(&s); len(warnings) != 2 {
+		t.Errorf("Expected warnings for the empty and the long entry, got %v", warnings)
+	}
+	want := []string{"b8-27-eb", "Espressif", "ab-cd"}
+	if !reflect.DeepEqual(s.ScanIgnoreList, want) {
+		t.Errorf("ScanIgnoreList = %v, want %v", s.ScanIgnoreList, want)
+	}
+}
+
+func TestValidateWarningSoundFile(t *testing.T) {
+	dir := t.TempDir()
+	wav := filepath.Join(dir, "alarm.wav")
+	if err := os.WriteFile(wav, []byte("RIFF\x24\x00\x00\x00WAVEfmt "), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fake := filepath.Join(dir, "fake.wav")
+	if err := os.WriteFile(fake, []byte("ID3 not a wave file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mp3 := filepath.Join(dir, "alarm.mp3")
+	if err := os.WriteFile(mp3, []byte("RIFF\x24\x00\x00\x00WAVEfmt "), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"absolute path", wav, false},
+		{"file URL", "file://" + filepath.ToSlash(wav), false},
+		{"relative path", "alarm.wav", true},
+		{"remote file URL", "file://server/share/alarm.wav", true},
+		{"missing file", filepath.Join(dir, "missing.wav"), true},
+		{"not a wav", fake, true},
+		{"wrong extension", mp3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWarningSoundFile(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateWarningSoundFile(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+
+	s := Settings{WarningSoundFile: "file://" + filepath.ToSlash(wav)}
+	if got := s.WarningSoundPath(); got != wav {
+		t.Errorf("WarningSoundPath() = %q, want %q", got, wav)
+	}
+}
+
+func TestHomeDetection(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		s := DefaultSettings()
+		s.HomeDetection = "bssid"
+		s.HomeGatewayMAC = "not-a-mac"
+		if warnings := ValidateSettings(&s); len(warnings) != 2 {
+			t.Errorf("Expected 2 warnings for home detection settings, got %v", warnings)
+		}
+		if s.HomeDetection != DefaultHomeDetection || s.HomeGatewayMAC != "" {
+			t.Errorf("Home detection = %q/%q, want default with no gateway", s.HomeDetection, s.HomeGatewayMAC)
+		}
+		if s.HomeConfigured() {
+			t.Error("HomeConfigured() = true without a home SSID")
+		}
+	})
+
+	t.Run("set gateway", func(t *testing.T) {
+		t.Setenv("APPDATA", t.TempDir())
+		if err := SetHomeGateway(""); err == nil {
+			t.Error("SetHomeGateway(\"\") should fail")
+		}
+		if err := SetHomeGateway("AA:BB:CC:DD:EE:01"); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.HomeDetection != HomeDetectionGateway || loaded.HomeGatewayMAC != "aa-bb-cc-dd-ee-01" {
+			t.Errorf("Loaded home detection = %q/%q, want gateway aa-bb-cc-dd-ee-01", loaded.HomeDetection, loaded.HomeGatewayMAC)
+		}
+		if !loaded.HomeConfigured() {
+			t.Error("HomeConfigured() = false with a gateway MAC")
+		}
+	})
+}
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2026, 3, 1, hour, 30, 0, 0, time.Local) }
+	tests := []struct {
+		name       string
+		start, end int
+		hour       int
+		want       bool
+	}{
+		{"disabled", 0, 0, 3, false},
+		{"same day inside", 13, 15, 14, true},
+		{"same day end is exclusive", 13, 15, 15, false},
+		{"wrap before midnight", 22, 7, 23, true},
+		{"wrap after midnight", 22, 7, 6, true},
+		{"wrap outside", 22, 7, 12, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Settings{QuietHoursStart: tt.start, QuietHoursEnd: tt.end}
+			if got := s.InQuietHours(at(tt.hour)); got != tt.want {
+				t.Errorf("InQuietHours(%d:30) with %d-%d = %v, want %v", tt.hour, tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+
+	s := DefaultSettings()
+	s.QuietHoursStart = 24
+	s.QuietHoursEnd = 7
+	if warnings := ValidateSettings(&s); len(warnings) == 0 {
+		t.Error("Expected warnings for quiet hours out of range")
+	}
+	if s.QuietHoursStart != 0 || s.QuietHoursEnd != 0 {
+		t.Errorf("Invalid quiet hours should be disabled, got %d-%d", s.QuietHoursStart, s.QuietHoursEnd)
+	}
+}
+
+func TestValidateTrayTemplates(t *testing.T) {
+	s := DefaultSettings()
+	s.TrayTitleTemplate = "{status}"
+	s.TrayTooltipTemplate = strings.Repeat("x", MaxTrayTemplateLength+1)
+	if warnings := ValidateSettings(&s); len(warnings) != 1 {
+		t.Errorf("Expected 1 warning for the long tooltip template, got %v", warnings)
+	}
+	if s.TrayTitleTemplate != "{status}" {
+		t.Errorf("Valid title template changed to %q", s.TrayTitleTemplate)
+	}
+	if s.TrayTooltipTemplate != "" {
+		t.Error("Too long tooltip template should be cleared")
+	}
+}
+
+func TestValidateShutdownAction(t *testing.T) {
+	for _, action := range []string{ShutdownActionShutdown, ShutdownActionHibernate, ShutdownActionSleep, ShutdownActionLock, ShutdownActionLogoff} {
+		if !ValidateShutdownAction(action) {
+			t.Errorf("ValidateShutdownAction(%q) = false, want true", action)
+		}
+	}
+	for _, action := range []string{"", "reboot", "LOGOFF"} {
+		if ValidateShutdownAction(action) {
+			t.Errorf("ValidateShutdownAction(%q) = true, want false", action)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	if warnings, err := Validate(); err != nil || len(warnings) != 0 {
+		t.Fatalf("Validate() without a file = %v, %v, want no warnings", warnings, err)
+	}
+
+	content := []byte(`{"grace_checks": 0, "poll_interval_sec": 10, "shutdown_delay_sec": 10, "theme": "neon"}`)
+	if err := os.WriteFile(GetSettingsPath(), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	warnings, err := Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("Validate() warnings = %v, want GraceChecks and Theme", warnings)
+	}
+	if data, _ := os.ReadFile(GetSettingsPath()); string(data) != string(content) {
+		t.Errorf("Validate() rewrote the settings file: %s", data)
+	}
+
+	if err := os.WriteFile(GetSettingsPath(), []byte(`{"grace_checks": `), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Validate(); err == nil {
+		t.Error("Validate() of a truncated file should fail")
+	}
+
+	// Encrypted fields without a key are reported, and no key is created
+	content = []byte(`{"home_ssid": "c2VhbGVk", "grace_checks": 2, "poll_interval_sec": 10, "shutdown_delay_sec": 10}`)
+	if err := os.WriteFile(GetSettingsPath(), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	warnings, err = Validate()
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "No encryption key") {
+		t.Errorf("Validate() warnings = %v, want the missing key", warnings)
+	}
+	if _, err := os.Stat(getKeyPath()); !os.IsNotExist(err) {
+		t.Errorf("Validate() created the key file (stat error %v)", err)
+	}
+}
+
+func TestLoadWithWarnings(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+
+	content := []byte(`{"grace_checks": 5, "poll_interval_sec": 10, "shutdown_delay_sec": 10, "theme": "neon"}`)
+	if err := os.WriteFile(GetSettingsPath(), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, warnings, err := LoadWithWarnings()
+	if err != nil {
+		t.Fatalf("LoadWithWarnings() error = %v", err)
+	}
+	if len(warnings) != 1 || settings.Theme != DefaultTheme {
+		t.Errorf("LoadWithWarnings() = theme %q, warnings %v, want the Theme reset reported", settings.Theme, warnings)
+	}
+}
+
+func TestSetSetting(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+
+	if got, err := SetSetting("grace_checks", "7"); err != nil || got != "7" {
+		t.Fatalf("SetSetting(grace_checks) = %q, %v", got, err)
+	}
+	if got, err := SetSetting("scan_ignore_list", "B8:27:EB, Espressif"); err != nil || got != `["b8-27-eb","Espressif"]` {
+		t.Errorf("SetSetting(scan_ignore_list) = %q, %v", got, err)
+	}
+	if got, err := SetSetting("probe_ports", "62078, 443"); err != nil || got != "[62078,443]" {
+		t.Errorf("SetSetting(probe_ports) = %q, %v", got, err)
+	}
+	if _, err := SetSetting("silent_mode", "true"); err != nil {
+		t.Errorf("SetSetting(silent_mode) error = %v", err)
+	}
+
+	settings, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.GraceChecks != 7 || !settings.SilentMode {
+		t.Errorf("Saved GraceChecks = %d, SilentMode = %v", settings.GraceChecks, settings.SilentMode)
+	}
+	if got, err := settings.Get("grace_checks"); err != nil || got != "7" {
+		t.Errorf("Get(grace_checks) = %q, %v", got, err)
+	}
+
+	for _, tt := range []struct{ key, value string }{
+		{"no_such_key", "1"},
+		{"grace_checks", "many"},
+		{"shutdown_delay_sec", "1"},
+		{"theme", "neon"},
+		{"probe_ports", "62078, x"},
+		{"probe_ports", "0"},
+		{"schema_version", "1"},
+	} {
+		if _, err := SetSetting(tt.key, tt.value); !errors.Is(err, ErrValidation) {
+			t.Errorf("SetSetting(%s, %s) error = %v, want validation error", tt.key, tt.value, err)
+		}
+	}
+	if settings, _ := Load(); settings.Theme != DefaultSettings().Theme {
+		t.Errorf("Rejected theme was saved: %q", settings.Theme)
+	}
+
+	if _, err := settings.Get("shutdown_pin"); err == nil {
+		t.Error("Get(shutdown_pin) should be refused")
+	}
+	if keys := SettingKeys(); len(keys) == 0 || keys[0] != "schema_version" {
+		t.Errorf("SettingKeys() = %v", keys)
+	}
+}
+
+func TestConcurrentSettingsAccess(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+
+	// Each writer owns one field; a setter that saved a stale copy would
+	// lose the other writers' values
+	const rounds = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*rounds)
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			errs <- SetShutdownDelay(ShutdownMinDelay + i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			_, err := SetSetting("grace_checks", strconv.Itoa(1+i))
+			errs <- err
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			errs <- SetPaused(i%2 == 0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			if _, err := Load(); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent settings access failed: %v", err)
+		}
+	}
+
+	// Every writer's last value survived
+	settings, warnings, err := LoadWithWarnings()
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("Settings after concurrent writes: %v, warnings %v", err, warnings)
+	}
+	if settings.ShutdownDelay != ShutdownMinDelay+rounds-1 {
+		t.Errorf("ShutdownDelay = %d, want %d", settings.ShutdownDelay, ShutdownMinDelay+rounds-1)
+	}
+	if settings.GraceChecks != rounds {
+		t.Errorf("GraceChecks = %d, want %d", settings.GraceChecks, rounds)
+	}
+	if settings.IsPaused {
+		t.Error("IsPaused = true, want the last write (false)")
+	}
+
+	// No temp files are left behind by the atomic writes
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(GetSettingsPath()), "settings-*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("Temp files left behind: %v", leftovers)
+	}
+}
+
+func TestSaveFailureKeepsSettings(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	if err := SetShutdownDelay(30); err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile(GetSettingsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The process dies halfway through writing the new file
+	crashed := errors.New("disk full")
+	write := writeTemp
+	writeTemp = func(w io.Writer, data []byte) (int, error) {
+		n, _ := w.Write(data[:len(data)/2])
+		return n, crashed
+	}
+	t.Cleanup(func() { writeTemp = write })
+
+	if err := SetShutdownDelay(45); !errors.Is(err, crashed) {
+		t.Fatalf("SetShutdownDelay() error = %v, want %v", err, crashed)
+	}
+	if data, _ := os.ReadFile(GetSettingsPath()); string(data) != string(original) {
+		t.Error("settings.json changed by a failed save")
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(GetSettingsPath()), "settings-*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("Temp files left behind: %v", leftovers)
+	}
+}
+
+func TestSaveIsAtomicForReaders(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	if err := SetShutdownDelay(ShutdownMinDelay); err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers going straight to the file, without the settings lock, must
+	// only ever see a complete old or new file
+	const rounds = 50
+	done := make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(done)
+		for i := 0; i < rounds; i++ {
+			if err := SetShutdownDelay(ShutdownMinDelay + i%2); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		data, err := os.ReadFile(GetSettingsPath())
+		if err != nil {
+			t.Fatalf("Reading settings during a save: %v", err)
+		}
+		var settings Settings
+		if err := json.Unmarshal(data, &settings); err != nil {
+			t.Fatalf("Partial settings file seen during a save: %v", err)
+		}
+		if d := settings.ShutdownDelay; d != ShutdownMinDelay && d != ShutdownMinDelay+1 {
+			t.Fatalf("ShutdownDelay = %d during a save, want one of the written values", d)
+		}
+		// Windows can't replace the file while it is open; leave gaps for
+		// the rename as a real reader would
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-errs:
+		t.Fatal(err)
+	default:
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+
+	if err := Update("HomeNet", "aa-bb-cc-dd-ee-01"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveProfile("home"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update("OfficeNet", "aa-bb-cc-dd-ee-02"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetShutdownAction(ShutdownActionLock); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveProfile("office"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Switching brings back the home values; the office profile keeps its own
+	if err := UseProfile("home"); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.ActiveProfile != "home" || settings.HomeSSID != "HomeNet" || settings.PhoneMAC != "aa-bb-cc-dd-ee-01" || settings.ShutdownAction != DefaultShutdownAction {
+		t.Errorf("Active home profile = %q %q %q %q", settings.ActiveProfile, settings.HomeSSID, settings.PhoneMAC, settings.ShutdownAction)
+	}
+	if got := settings.ProfileForSSID("OfficeNet"); got != "office" {
+		t.Errorf("ProfileForSSID(OfficeNet) = %q, want office", got)
+	}
+
+	// Changes to the active profile are kept when switching away
+	if err := Update("", "aa-bb-cc-dd-ee-03"); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseProfile("office"); err != nil {
+		t.Fatal(err)
+	}
+	settings, _ = Load()
+	if settings.ShutdownAction != ShutdownActionLock || settings.Profiles["home"].PhoneMAC != "aa-bb-cc-dd-ee-03" {
+		t.Errorf("Office action = %q, saved home MAC = %q", settings.ShutdownAction, settings.Profiles["home"].PhoneMAC)
+	}
+
+	// Profile SSIDs and MACs are encrypted like the top-level ones
+	data, err := os.ReadFile(GetSettingsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "HomeNet") || strings.Contains(string(data), "aa-bb-cc-dd-ee-03") {
+		t.Errorf("Profile fields stored in plain text: %s", data)
+	}
+
+	if err := UseProfile("cabin"); !errors.Is(err, ErrValidation) {
+		t.Errorf("UseProfile(unknown) error = %v, want validation error", err)
+	}
+	if err := SaveProfile(" spaced "); !errors.Is(err, ErrValidation) {
+		t.Errorf("SaveProfile(spaced name) error = %v, want validation error", err)
+	}
+	if err := DeleteProfile("office"); err != nil {
+		t.Fatal(err)
+	}
+	if settings, _ := Load(); settings.ActiveProfile != "" || settings.HomeSSID != "OfficeNet" {
+		t.Errorf("After deleting the active profile: %q %q", settings.ActiveProfile, settings.HomeSSID)
+	}
+
+	s := DefaultSettings()
+	s.Profiles = map[string]Profile{
+		"ok":       {HomeSSID: "Net", PhoneMAC: "AA:BB:CC:DD:EE:FF"},
+		"bad mac":  {PhoneMAC: "not-a-mac"},
+		"\x1bevil": {},
+	}
+	s.ActiveProfile = "gone"
+	if warnings := ValidateSettings(&s); len(warnings) != 3 {
+		t.Errorf("Expected warnings for the MAC, the name and the active profile, got %v", warnings)
+	}
+	if len(s.Profiles) != 2 || s.Profiles["ok"].PhoneMAC != "aa-bb-cc-dd-ee-ff" || s.Profiles["bad mac"].PhoneMAC != "" || s.ActiveProfile != "" {
+		t.Errorf("Validated profiles = %+v, active %q", s.Profiles, s.ActiveProfile)
+	}
+}
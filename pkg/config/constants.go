@@ -2,17 +2,49 @@ package config
 
 // Default configuration constants
 const (
-	DefaultGraceChecks    = 5
-	DefaultPollInterval   = 10
-	DefaultPingTimeoutMs  = 500
-	DefaultShutdownDelay  = 10
-	DefaultShutdownAction = ShutdownActionShutdown
-	DefaultDetectionType  = DetectionTypeMAC
-	DefaultRetryAttempts  = 3
-	ShutdownMaxDelay      = 300 // 5 minutes
-	ShutdownMinDelay      = 5   // 5 seconds
-	MinPollInterval       = 1
-	MaxPollInterval       = 300
+	DefaultGraceChecks      = 5
+	DefaultGraceAlertWindow = 60 // minutes
+	DefaultPollInterval     = 10
+	DefaultPingTimeoutMs    = 500
+	DefaultPingCount        = 2
+	DefaultShutdownDelay    = 10
+	DefaultShutdownAction   = ShutdownActionShutdown
+	DefaultDetectionType    = DetectionTypeMAC
+	DefaultDetectionMode    = DetectionModeActive
+	DefaultARPRefresh       = ARPRefreshAuto
+	DefaultConfirmAbsence   = 1
+	DefaultHomeDetection    = HomeDetectionSSID
+	DefaultRetryAttempts    = 3
+	DefaultResolveHostnames = true
+	DefaultAutoUpdateIP     = true
+	DefaultScanTimeoutSec   = 20
+	DefaultScanExcludeSelf  = true
+	DefaultPreShutdownSec   = 30
+	DefaultShutdownVerify   = 15 // seconds
+	DefaultShutdownFallback = ShutdownActionLock
+	DefaultLogRetentionDays = 7
+	DefaultTheme            = ThemeSystem
+	ShutdownMaxDelay        = 300 // 5 minutes
+	ShutdownMinDelay        = 5   // 5 seconds
+	MinPollInterval         = 1
+	MaxPollInterval         = 300
+)
+
+// DefaultCriticalWebhookTemplate is the payload sent to the critical webhook when
+// no custom template is configured. Supported placeholders: {delay}, {ssid},
+// {device}, {hostname}, {absent}, {action}, {status}.
+const DefaultCriticalWebhookTemplate = `{"title":"Home Sentry Alert","message":"Phone {device} not detected on {ssid}. {hostname} will shut down in {delay} seconds."}`
+
+// ShutdownFailedWebhookTemplate is sent to the critical webhook when the
+// shutdown action did not take effect and no custom template is configured.
+// It supports the same placeholders.
+const ShutdownFailedWebhookTemplate = `{"title":"Home Sentry: SHUTDOWN FAILED","message":"{action} did not take effect on {hostname} after phone {device} left {ssid}. The machine is still running."}`
+
+// Values of the {status} webhook placeholder, so a custom template can tell
+// the shutdown alert from the one raised when the shutdown failed
+const (
+	WebhookStatusShuttingDown   = "shutting_down"
+	WebhookStatusShutdownFailed = "shutdown_failed"
 )
 
 // Shutdown actions
@@ -21,6 +53,16 @@ const (
 	ShutdownActionHibernate = "hibernate"
 	ShutdownActionLock      = "lock"
 	ShutdownActionSleep     = "sleep"
+	// ShutdownActionLogoff force-closes every app and signs the user out.
+	// Unsaved work is lost.
+	ShutdownActionLogoff = "logoff"
+)
+
+// Menu themes
+const (
+	ThemeDark   = "dark"
+	ThemeLight  = "light"
+	ThemeSystem = "system" // Follow the Windows apps theme
 )
 
 // Validation limits
@@ -31,4 +73,43 @@ const (
 	MACLength      = 17
 	MinPINLength   = 4
 	MaxPINLength   = 8
+
+	MinPingCount         = 1
+	MaxPingCount         = 5
+	MaxPingPacketSize    = 65500
+	MaxProbePorts        = 8
+	MaxSeenAgeLimitHours = 8760 // 1 year
+	MaxScanTimeoutSec    = 300
+	MaxPreShutdownSec    = 300
+	MaxShutdownVerifySec = 300
+	MaxShutdownCooldown  = 3600
+	MaxConfirmationDelay = 600
+	MaxHoldLastHomeSec   = 600
+	MaxBackgroundScanMin = 24 * 60
+	MaxLogRetentionDays  = 3650
+	MaxPauseLimitMinutes = 30 * 24 * 60 // 30 days
+	MaxGraceAlertCount   = 100
+	MaxKnownDevices      = 1000        // Remembered for new-device alerts, oldest forgotten first
+	MaxGraceAlertWindow  = 7 * 24 * 60 // 1 week, in minutes
+	MaxScanCIDRs         = 8
+	MaxScanIgnoreEntries = 100
+	MinScanCIDRPrefix    = 20 // At most 4094 hosts per range
+	MaxCommandPathLength = 1024
+	MaxWarningSoundSize  = 10 << 20 // 10 MiB
+
+	MaxTrayTemplateLength = 256
+	MaxDeviceNameLength   = 64
+
+	MaxScanIgnoreEntryLength = 64
+
+	MaxProfiles          = 16
+	MaxProfileNameLength = 32
+
+	MaxDockEntries     = 16
+	MaxDockEntryLength = 256
+
+	MaxConfirmAbsenceMethods = 3 // ARP, ping and TCP
+
+	MaxWebhookURLLength      = 2048
+	MaxWebhookTemplateLength = 4096
 )
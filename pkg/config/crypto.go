@@ -3,9 +3,12 @@ package config
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"home-sentry/pkg/paths"
 	"io"
 	"os"
 	"path/filepath"
@@ -80,39 +83,51 @@ func getOrCreateKey() ([]byte, error) {
 	return ks.GetOrCreateKey()
 }
 
-// getKeyPath returns the path to the encryption key
-func getKeyPath() string {
-	var configDir string
+// hasSensitiveFields reports whether settings has any of the fields
+// EncryptSettings encrypts
+func hasSensitiveFields(settings *Settings) bool {
+	return settings.HomeSSID != "" || settings.PhoneMAC != "" || settings.PhoneIP != "" ||
+		settings.PhoneHostname != "" || settings.HomeGatewayMAC != "" || settings.ShutdownPIN != "" ||
+		len(settings.Profiles) > 0
+}
 
-	switch runtime.GOOS {
-	case "windows":
-		configDir = os.Getenv("APPDATA")
-		if configDir == "" {
-			configDir = os.Getenv("LOCALAPPDATA")
-		}
-	case "darwin":
-		home, _ := os.UserHomeDir()
-		configDir = filepath.Join(home, "Library", "Application Support")
-	default: // Linux and others
-		home, _ := os.UserHomeDir()
-		configDir = filepath.Join(home, ".config")
-	}
+// readKey returns the stored encryption key without creating one
+func readKey() ([]byte, error) {
+	return NewKeyStorage().ReadKey()
+}
 
-	if configDir == "" {
-		configDir = "."
+// StateKey returns the key the sentry state file is signed with. It is
+// derived from the settings encryption key rather than reusing it directly.
+func StateKey() ([]byte, error) {
+	key, err := getOrCreateKey()
+	if err != nil {
+		return nil, newError(ErrEncryption, "failed to load encryption key", err)
 	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("home-sentry state file"))
+	return mac.Sum(nil), nil
+}
 
-	appDir := filepath.Join(configDir, "HomeSentry")
-	os.MkdirAll(appDir, 0700)
-
-	return filepath.Join(appDir, ".key")
+// getKeyPath returns the path to the encryption key: in the app directory
+// on Windows, in the user's config directory elsewhere. Saving the key
+// creates the directory, so reading it never does.
+func getKeyPath() string {
+	home, err := os.UserHomeDir()
+	switch {
+	case runtime.GOOS == "windows" || err != nil:
+		return filepath.Join(paths.AppDir(), ".key")
+	case runtime.GOOS == "darwin":
+		return filepath.Join(home, "Library", "Application Support", paths.AppName, ".key")
+	default: // Linux and others
+		return filepath.Join(home, ".config", paths.AppName, ".key")
+	}
 }
 
 // EncryptSettings encrypts sensitive fields in Settings
 func EncryptSettings(settings *Settings) (*Settings, error) {
 	key, err := getOrCreateKey()
 	if err != nil {
-		return nil, err
+		return nil, newError(ErrEncryption, "failed to load encryption key", err)
 	}
 
 	encrypted := *settings
@@ -121,7 +136,7 @@ func EncryptSettings(settings *Settings) (*Settings, error) {
 	if settings.HomeSSID != "" {
 		enc, err := encryptString(settings.HomeSSID, key)
 		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt HomeSSID: %w", err)
+			return nil, newError(ErrEncryption, "failed to encrypt HomeSSID", err)
 		}
 		encrypted.HomeSSID = enc
 	}
@@ -130,7 +145,7 @@ func EncryptSettings(settings *Settings) (*Settings, error) {
 	if settings.PhoneMAC != "" {
 		enc, err := encryptString(settings.PhoneMAC, key)
 		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt PhoneMAC: %w", err)
+			return nil, newError(ErrEncryption, "failed to encrypt PhoneMAC", err)
 		}
 		encrypted.PhoneMAC = enc
 	}
@@ -139,28 +154,59 @@ func EncryptSettings(settings *Settings) (*Settings, error) {
 	if settings.PhoneIP != "" {
 		enc, err := encryptString(settings.PhoneIP, key)
 		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt PhoneIP: %w", err)
+			return nil, newError(ErrEncryption, "failed to encrypt PhoneIP", err)
 		}
 		encrypted.PhoneIP = enc
 	}
 
+	// Encrypt PhoneHostname
+	if settings.PhoneHostname != "" {
+		enc, err := encryptString(settings.PhoneHostname, key)
+		if err != nil {
+			return nil, newError(ErrEncryption, "failed to encrypt PhoneHostname", err)
+		}
+		encrypted.PhoneHostname = enc
+	}
+
+	// Encrypt HomeGatewayMAC
+	if settings.HomeGatewayMAC != "" {
+		enc, err := encryptString(settings.HomeGatewayMAC, key)
+		if err != nil {
+			return nil, newError(ErrEncryption, "failed to encrypt HomeGatewayMAC", err)
+		}
+		encrypted.HomeGatewayMAC = enc
+	}
+
 	// Encrypt ShutdownPIN
 	if settings.ShutdownPIN != "" {
 		enc, err := encryptString(settings.ShutdownPIN, key)
 		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt ShutdownPIN: %w", err)
+			return nil, newError(ErrEncryption, "failed to encrypt ShutdownPIN", err)
 		}
 		encrypted.ShutdownPIN = enc
 	}
 
+	// Encrypt the same fields of every profile
+	profiles, err := cryptProfiles(settings.Profiles, key, encryptString)
+	if err != nil {
+		return nil, newError(ErrEncryption, "failed to encrypt profiles", err)
+	}
+	encrypted.Profiles = profiles
+
 	return &encrypted, nil
 }
 
-// DecryptSettings decrypts sensitive fields in Settings
+// DecryptSettings decrypts sensitive fields in Settings. It only reads the
+// stored key: a key created now could not decrypt anything.
 func DecryptSettings(settings *Settings) (*Settings, error) {
-	key, err := getOrCreateKey()
+	if !hasSensitiveFields(settings) {
+		decrypted := *settings
+		return &decrypted, nil
+	}
+
+	key, err := readKey()
 	if err != nil {
-		return nil, err
+		return nil, newError(ErrDecryption, "failed to load encryption key", err)
 	}
 
 	decrypted := *settings
@@ -169,7 +215,7 @@ func DecryptSettings(settings *Settings) (*Settings, error) {
 	if settings.HomeSSID != "" {
 		dec, err := decryptString(settings.HomeSSID, key)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt HomeSSID: %w", err)
+			return nil, newError(ErrDecryption, "failed to decrypt HomeSSID", err)
 		}
 		decrypted.HomeSSID = dec
 	}
@@ -178,7 +224,7 @@ func DecryptSettings(settings *Settings) (*Settings, error) {
 	if settings.PhoneMAC != "" {
 		dec, err := decryptString(settings.PhoneMAC, key)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt PhoneMAC: %w", err)
+			return nil, newError(ErrDecryption, "failed to decrypt PhoneMAC", err)
 		}
 		decrypted.PhoneMAC = dec
 	}
@@ -187,19 +233,64 @@ func DecryptSettings(settings *Settings) (*Settings, error) {
 	if settings.PhoneIP != "" {
 		dec, err := decryptString(settings.PhoneIP, key)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt PhoneIP: %w", err)
+			return nil, newError(ErrDecryption, "failed to decrypt PhoneIP", err)
 		}
 		decrypted.PhoneIP = dec
 	}
 
+	// Decrypt PhoneHostname
+	if settings.PhoneHostname != "" {
+		dec, err := decryptString(settings.PhoneHostname, key)
+		if err != nil {
+			return nil, newError(ErrDecryption, "failed to decrypt PhoneHostname", err)
+		}
+		decrypted.PhoneHostname = dec
+	}
+
+	// Decrypt HomeGatewayMAC
+	if settings.HomeGatewayMAC != "" {
+		dec, err := decryptString(settings.HomeGatewayMAC, key)
+		if err != nil {
+			return nil, newError(ErrDecryption, "failed to decrypt HomeGatewayMAC", err)
+		}
+		decrypted.HomeGatewayMAC = dec
+	}
+
 	// Decrypt ShutdownPIN
 	if settings.ShutdownPIN != "" {
 		dec, err := decryptString(settings.ShutdownPIN, key)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt ShutdownPIN: %w", err)
+			return nil, newError(ErrDecryption, "failed to decrypt ShutdownPIN", err)
 		}
 		decrypted.ShutdownPIN = dec
 	}
 
+	// Decrypt the same fields of every profile
+	profiles, err := cryptProfiles(settings.Profiles, key, decryptString)
+	if err != nil {
+		return nil, newError(ErrDecryption, "failed to decrypt profiles", err)
+	}
+	decrypted.Profiles = profiles
+
 	return &decrypted, nil
 }
+
+// cryptProfiles returns a copy of profiles with the sensitive fields passed
+// through crypt (encryptString or decryptString)
+func cryptProfiles(profiles map[string]Profile, key []byte, crypt func(string, []byte) (string, error)) (map[string]Profile, error) {
+	if profiles == nil {
+		return nil, nil
+	}
+	out := make(map[string]Profile, len(profiles))
+	for name, p := range profiles {
+		for _, field := range []*string{&p.HomeSSID, &p.HomeGatewayMAC, &p.PhoneIP, &p.PhoneMAC, &p.PhoneHostname} {
+			value, err := crypt(*field, key)
+			if err != nil {
+				return nil, fmt.Errorf("profile %q: %w", name, err)
+			}
+			*field = value
+		}
+		out[name] = p
+	}
+	return out, nil
+}
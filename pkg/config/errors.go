@@ -0,0 +1,40 @@
+package config
+
+import "errors"
+
+// Sentinel errors for classifying failures from Load, Save and the setters.
+// Check them with errors.Is; the original cause stays in the chain.
+var (
+	// ErrValidation means a value was rejected before anything was written
+	ErrValidation = errors.New("invalid settings value")
+	// ErrIO means the settings file could not be read, parsed or written
+	ErrIO = errors.New("settings file I/O failed")
+	// ErrEncryption means sensitive fields could not be encrypted for saving
+	ErrEncryption = errors.New("settings encryption failed")
+	// ErrDecryption means sensitive fields could not be decrypted
+	ErrDecryption = errors.New("settings decryption failed")
+)
+
+// Error wraps a settings failure with its kind (one of the sentinel errors)
+// while keeping the original message.
+type Error struct {
+	Kind error
+	Op   string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Op
+	}
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// newError builds an Error of the given kind
+func newError(kind error, op string, err error) error {
+	return &Error{Kind: kind, Op: op, Err: err}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// readOnlyKeys are managed by Home Sentry itself and can't be set by key
+var readOnlyKeys = map[string]bool{
+	"schema_version": true,
+	"paused_at":      true,
+}
+
+// writeOnlyKeys are never printed by key
+var writeOnlyKeys = map[string]bool{
+	"shutdown_pin": true,
+}
+
+// SettingKeys lists every setting by its settings.json key, in file order
+func SettingKeys() []string {
+	t := reflect.TypeOf(Settings{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := jsonKey(t.Field(i)); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// jsonKey returns the settings.json key of a field, or "" if it has none
+func jsonKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// settingField finds the field stored under key
+func settingField(s *Settings, key string) (reflect.Value, error) {
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if jsonKey(v.Type().Field(i)) == key {
+			return v.Field(i), nil
+		}
+	}
+	return reflect.Value{}, NewValidationError(key, fmt.Sprintf("unknown setting %q, run 'home-sentry get' to list them", key))
+}
+
+// formatSetting renders a field the way `get` prints it: strings as they
+// are, everything else as JSON
+func formatSetting(field reflect.Value) (string, error) {
+	if field.Kind() == reflect.String {
+		return field.String(), nil
+	}
+	data, err := json.Marshal(field.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Get returns the value stored under a settings.json key
+func (s Settings) Get(key string) (string, error) {
+	field, err := settingField(&s, key)
+	if err != nil {
+		return "", err
+	}
+	if writeOnlyKeys[key] {
+		return "", NewValidationError(key, fmt.Sprintf("%s can't be read", key))
+	}
+	return formatSetting(field)
+}
+
+// SetSetting parses value for the setting stored under key and saves it.
+// Strings are taken as they are, lists also as comma-separated values, and
+// everything else as JSON (numbers, true/false, times in quotes). A value
+// that ValidateSettings would reset is rejected with its warnings. It
+// returns the value as stored, after normalization.
+func SetSetting(key, value string) (string, error) {
+	if readOnlyKeys[key] {
+		return "", NewValidationError(key, fmt.Sprintf("%s is managed by Home Sentry and can't be set", key))
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return "", fmt.Errorf("failed to load settings: %w", err)
+	}
+	field, err := settingField(&settings, key)
+	if err != nil {
+		return "", err
+	}
+	if err := parseSetting(field, value); err != nil {
+		return "", NewValidationError(key, fmt.Sprintf("%s: %v", key, err))
+	}
+	if warnings := ValidateSettings(&settings); len(warnings) > 0 {
+		return "", NewValidationError(key, fmt.Sprintf("%s rejected: %s", key, strings.Join(warnings, "; ")))
+	}
+	if err := saveLocked(settings); err != nil {
+		return "", err
+	}
+	if writeOnlyKeys[key] {
+		return "", nil
+	}
+	return formatSetting(field)
+}
+
+// parseSetting stores value in field, converting it to the field's type
+func parseSetting(field reflect.Value, value string) error {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+		return nil
+	case field.Kind() == reflect.Slice && !strings.HasPrefix(strings.TrimSpace(value), "["):
+		list := reflect.MakeSlice(field.Type(), 0, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := parseSetting(elem, item); err != nil {
+				return err
+			}
+			list = reflect.Append(list, elem)
+		}
+		field.Set(list)
+		return nil
+	}
+
+	parsed := reflect.New(field.Type())
+	if err := json.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return fmt.Errorf("%q is not a valid %s", value, typeName(field.Type()))
+	}
+	field.Set(parsed.Elem())
+	return nil
+}
+
+// typeName describes a setting's type in error messages
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean (true or false)"
+	case reflect.Int, reflect.Int64:
+		return "whole number"
+	case reflect.Slice:
+		return "list"
+	}
+	if t.String() == "time.Time" {
+		return `time (quoted RFC 3339, e.g. "2025-01-02T15:04:05Z")`
+	}
+	return t.String()
+}
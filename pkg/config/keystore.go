@@ -44,6 +44,19 @@ func (ks *KeyStorage) GetOrCreateKey() ([]byte, error) {
 	return key, nil
 }
 
+// ReadKey retrieves the stored encryption key without generating one. The
+// error wraps os.ErrNotExist when no key has been saved yet.
+func (ks *KeyStorage) ReadKey() ([]byte, error) {
+	keyData, err := ks.readKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(keyData) != 32 {
+		return nil, fmt.Errorf("stored key has %d bytes, expected 32", len(keyData))
+	}
+	return keyData, nil
+}
+
 // readKey reads the key from secure storage
 func (ks *KeyStorage) readKey() ([]byte, error) {
 	if runtime.GOOS == "windows" {
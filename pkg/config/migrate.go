@@ -0,0 +1,61 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentSchemaVersion is the settings layout written by this build. Bump it
+// and register a migration whenever a field is renamed, split or removed.
+const CurrentSchemaVersion = 1
+
+// migration upgrades raw settings from one schema version to the next
+type migration func(raw map[string]json.RawMessage) error
+
+// migrations maps a schema version to the step that upgrades it to the next
+// version. Steps run in order until CurrentSchemaVersion is reached.
+var migrations = map[int]migration{
+	// Files written before schema versioning already use the v1 layout
+	0: func(raw map[string]json.RawMessage) error { return nil },
+}
+
+// migrate decodes a settings file, applying any schema migrations first.
+// Fields the file does not mention keep their DefaultSettings values.
+func migrate(data []byte) (Settings, error) {
+	settings := DefaultSettings()
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return settings, err
+	}
+
+	version := 0
+	if v, ok := raw["schema_version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return settings, fmt.Errorf("invalid schema_version: %w", err)
+		}
+	}
+
+	// A newer file is decoded as-is; unknown fields are simply ignored
+	for ; version < CurrentSchemaVersion; version++ {
+		step, ok := migrations[version]
+		if !ok {
+			return settings, fmt.Errorf("no settings migration from schema version %d", version)
+		}
+		if err := step(raw); err != nil {
+			return settings, fmt.Errorf("settings migration from schema version %d failed: %w", version, err)
+		}
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(migrated, &settings); err != nil {
+		return DefaultSettings(), err
+	}
+	if settings.SchemaVersion < CurrentSchemaVersion {
+		settings.SchemaVersion = CurrentSchemaVersion
+	}
+	return settings, nil
+}
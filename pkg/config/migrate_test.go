@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateUnversionedFile(t *testing.T) {
+	// A settings file written before schema versioning existed
+	legacy := `{
+		"home_ssid": "HomeNet",
+		"phone_mac": "aa-bb-cc-dd-ee-ff",
+		"detection_type": "mac",
+		"grace_checks": 7,
+		"poll_interval_sec": 15
+	}`
+
+	settings, err := migrate([]byte(legacy))
+	if err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+	if settings.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", settings.SchemaVersion, CurrentSchemaVersion)
+	}
+	if settings.HomeSSID != "HomeNet" || settings.PhoneMAC != "aa-bb-cc-dd-ee-ff" {
+		t.Errorf("Fields not preserved: %+v", settings)
+	}
+	if settings.GraceChecks != 7 || settings.PollInterval != 15 {
+		t.Errorf("Numeric fields not preserved: grace=%d poll=%d", settings.GraceChecks, settings.PollInterval)
+	}
+	// Fields missing from the file keep their defaults
+	if settings.ShutdownDelay != DefaultShutdownDelay {
+		t.Errorf("ShutdownDelay = %d, want default %d", settings.ShutdownDelay, DefaultShutdownDelay)
+	}
+}
+
+func TestMigrateAppliesStepsInOrder(t *testing.T) {
+	orig := migrations
+	defer func() { migrations = orig }()
+
+	var order []int
+	migrations = map[int]migration{
+		0: func(raw map[string]json.RawMessage) error {
+			order = append(order, 0)
+			// Rename a field the way a real schema change would
+			raw["home_ssid"] = raw["old_ssid"]
+			delete(raw, "old_ssid")
+			return nil
+		},
+	}
+
+	settings, err := migrate([]byte(`{"old_ssid": "Renamed"}`))
+	if err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+	if len(order) != 1 || order[0] != 0 {
+		t.Errorf("Migration order = %v, want [0]", order)
+	}
+	if settings.HomeSSID != "Renamed" {
+		t.Errorf("HomeSSID = %q, want migrated value", settings.HomeSSID)
+	}
+
+	migrations = map[int]migration{}
+	if _, err := migrate([]byte(`{}`)); err == nil {
+		t.Error("Expected error when a migration step is missing")
+	}
+}
+
+func TestMigrateLogRetention(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want int
+	}{
+		{"missing field gets default", `{"schema_version": 1}`, DefaultLogRetentionDays},
+		{"keep forever preserved", `{"schema_version": 1, "log_retention_days": 0}`, 0},
+		{"explicit value preserved", `{"schema_version": 1, "log_retention_days": 30}`, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := migrate([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("migrate() error = %v", err)
+			}
+			if settings.LogRetentionDays != tt.want {
+				t.Errorf("LogRetentionDays = %d, want %d", settings.LogRetentionDays, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveStampsSchemaVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+	origAppData := os.Getenv("APPDATA")
+	os.Setenv("APPDATA", tmpDir)
+	defer os.Setenv("APPDATA", origAppData)
+
+	if err := Save(Settings{HomeSSID: "TestWiFi"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "HomeSentry", "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("Saved schema_version = %d, want %d", raw.SchemaVersion, CurrentSchemaVersion)
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile is a named set of the settings that differ between places: the
+// home network, the monitored device and the shutdown action. The top-level
+// Settings fields always hold the active profile's values, so code that
+// reads Settings needs no profile awareness.
+type Profile struct {
+	HomeSSID       string        `json:"home_ssid"`
+	HomeDetection  HomeDetection `json:"home_detection"`
+	HomeGatewayMAC string        `json:"home_gateway_mac"`
+	PhoneIP        string        `json:"phone_ip"`
+	PhoneMAC       string        `json:"phone_mac"`
+	PhoneHostname  string        `json:"phone_hostname"`
+	DetectionType  DetectionType `json:"detection_type"`
+	ShutdownAction string        `json:"shutdown_action"`
+}
+
+// currentProfile returns the profile fields of the top-level settings
+func (s Settings) currentProfile() Profile {
+	return Profile{
+		HomeSSID:       s.HomeSSID,
+		HomeDetection:  s.HomeDetection,
+		HomeGatewayMAC: s.HomeGatewayMAC,
+		PhoneIP:        s.PhoneIP,
+		PhoneMAC:       s.PhoneMAC,
+		PhoneHostname:  s.PhoneHostname,
+		DetectionType:  s.DetectionType,
+		ShutdownAction: s.ShutdownAction,
+	}
+}
+
+// applyProfile copies a profile into the top-level settings
+func (s *Settings) applyProfile(p Profile) {
+	s.HomeSSID = p.HomeSSID
+	s.HomeDetection = p.HomeDetection
+	s.HomeGatewayMAC = p.HomeGatewayMAC
+	s.PhoneIP = p.PhoneIP
+	s.PhoneMAC = p.PhoneMAC
+	s.PhoneHostname = p.PhoneHostname
+	s.DetectionType = p.DetectionType
+	s.ShutdownAction = p.ShutdownAction
+}
+
+// validateProfile sanitizes a profile with the same rules as the top-level
+// fields and returns the warnings for values that were reset. Fields left
+// empty in a hand-written profile get their defaults.
+func validateProfile(p *Profile) []string {
+	if p.DetectionType == "" {
+		p.DetectionType = DefaultDetectionType
+	}
+	if p.ShutdownAction == "" {
+		p.ShutdownAction = DefaultShutdownAction
+	}
+	settings := DefaultSettings()
+	settings.applyProfile(*p)
+	warnings := ValidateSettings(&settings)
+	*p = settings.currentProfile()
+	return warnings
+}
+
+// ValidateProfileName checks a profile name: 1-32 characters of printable text
+func ValidateProfileName(name string) error {
+	if strings.TrimSpace(name) != name || name == "" {
+		return NewValidationError("Profile", "profile name must not be empty or start or end with spaces")
+	}
+	if len(name) > MaxProfileNameLength {
+		return NewValidationError("Profile", fmt.Sprintf("profile name must be at most %d characters", MaxProfileNameLength))
+	}
+	if RemoveControlChars(name) != name {
+		return NewValidationError("Profile", "profile name must not contain control characters")
+	}
+	return nil
+}
+
+// ProfileNames returns the saved profiles' names, sorted
+func (s Settings) ProfileNames() []string {
+	names := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileForSSID returns the first profile, by name, whose home network is
+// recognized by the SSID ssid, or "" if there is none
+func (s Settings) ProfileForSSID(ssid string) string {
+	if ssid == "" {
+		return ""
+	}
+	for _, name := range s.ProfileNames() {
+		p := s.Profiles[name]
+		if p.HomeDetection != HomeDetectionGateway && p.HomeSSID == ssid {
+			return name
+		}
+	}
+	return ""
+}
+
+// SaveProfile stores the current home network, device and shutdown action as
+// the profile name, replacing a profile of that name, and makes it active
+func SaveProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	if _, exists := settings.Profiles[name]; !exists && len(settings.Profiles) >= MaxProfiles {
+		return NewValidationError("Profile", fmt.Sprintf("at most %d profiles can be saved", MaxProfiles))
+	}
+	if settings.Profiles == nil {
+		settings.Profiles = make(map[string]Profile)
+	}
+	settings.Profiles[name] = settings.currentProfile()
+	settings.ActiveProfile = name
+	return saveLocked(settings)
+}
+
+// UseProfile makes the saved profile name the active one. Changes made to
+// the previously active profile since it was activated are kept in it.
+func UseProfile(name string) error {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	profile, ok := settings.Profiles[name]
+	if !ok {
+		return NewValidationError("Profile", fmt.Sprintf("no profile named %q", name))
+	}
+	if _, ok := settings.Profiles[settings.ActiveProfile]; ok {
+		settings.Profiles[settings.ActiveProfile] = settings.currentProfile()
+	}
+	settings.applyProfile(profile)
+	settings.ActiveProfile = name
+	return saveLocked(settings)
+}
+
+// DeleteProfile removes a saved profile. Deleting the active profile keeps
+// its values in effect, just no longer under a name.
+func DeleteProfile(name string) error {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	settings, err := loadLocked()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	if _, ok := settings.Profiles[name]; !ok {
+		return NewValidationError("Profile", fmt.Sprintf("no profile named %q", name))
+	}
+	delete(settings.Profiles, name)
+	if settings.ActiveProfile == name {
+		settings.ActiveProfile = ""
+	}
+	return saveLocked(settings)
+}
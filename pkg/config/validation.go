@@ -1,6 +1,13 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"unicode"
@@ -13,6 +20,8 @@ var (
 	// MAC address formats: 00:11:22:33:44:55 or 00-11-22-33-44-55 or 001122334455
 	macRegex        = regexp.MustCompile(`^([0-9a-fA-F]{2}[:-]){5}[0-9a-fA-F]{2}$`)
 	macCompactRegex = regexp.MustCompile(`^[0-9a-fA-F]{12}$`)
+	// MAC prefix of two to six octets, e.g. an OUI like B8:27:EB
+	macPrefixRegex = regexp.MustCompile(`^[0-9a-fA-F]{2}([:-][0-9a-fA-F]{2}){1,5}$`)
 
 	// IP address validation
 	ipRegex = regexp.MustCompile(`^(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$`)
@@ -20,6 +29,13 @@ var (
 	// PIN validation - 4-8 digits only
 	pinRegex = regexp.MustCompile(`^\d{4,8}$`)
 
+	// One label of an mDNS name: letters, digits and inner hyphens
+	mdnsLabelRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+	// A device instance ID: enumerator, hardware ID and the instance, which
+	// carries the serial number or port, e.g. USB\VID_17EF&PID_3066\5&2A1B2C3D&0&1
+	dockInstanceIDRegex = regexp.MustCompile(`^[A-Za-z0-9_]+\\[^\\\s]+\\[^\\\s]+$`)
+
 	// General dangerous character pattern (for basic XSS prevention)
 	dangerousChars = regexp.MustCompile(`[<>"'&]|javascript:|data:|vbscript:`)
 )
@@ -104,6 +120,29 @@ func SanitizePIN(pin string) (string, error) {
 	return pin, nil
 }
 
+// SanitizeMDNSName validates an mDNS (Bonjour) host name such as
+// "Johns-iPhone.local". A name without a domain gets ".local" appended.
+func SanitizeMDNSName(name string) (string, error) {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	if name == "" {
+		return "", nil
+	}
+
+	if !strings.Contains(name, ".") {
+		name += ".local"
+	}
+	if len(name) > 253 {
+		return "", NewValidationError("Invalid mDNS name", "mDNS name must be at most 253 characters")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !mdnsLabelRegex.MatchString(label) {
+			return "", NewValidationError("Invalid mDNS name", "mDNS name must be dot-separated labels of letters, digits and hyphens, e.g. Johns-iPhone.local")
+		}
+	}
+
+	return name, nil
+}
+
 // RemoveControlChars removes control characters from a string
 func RemoveControlChars(s string) string {
 	return strings.Map(func(r rune) rune {
@@ -114,6 +153,46 @@ func RemoveControlChars(s string) string {
 	}, s)
 }
 
+// SanitizeMACPrefix normalizes a MAC prefix such as "B8:27:EB" to lowercase
+// with dashes. ok is false when s is not a MAC prefix.
+func SanitizeMACPrefix(s string) (prefix string, ok bool) {
+	s = strings.TrimSpace(s)
+	if !macPrefixRegex.MatchString(s) {
+		return "", false
+	}
+	return strings.ToLower(strings.ReplaceAll(s, ":", "-")), true
+}
+
+// SanitizeScanIgnoreEntry cleans an ignore-list entry: MAC prefixes are
+// normalized, anything else is kept as a vendor or hostname substring
+func SanitizeScanIgnoreEntry(entry string) (string, error) {
+	if prefix, ok := SanitizeMACPrefix(entry); ok {
+		return prefix, nil
+	}
+	entry = strings.TrimSpace(RemoveControlChars(entry))
+	if entry == "" {
+		return "", NewValidationError("ScanIgnoreList", "empty entry")
+	}
+	if len(entry) > MaxScanIgnoreEntryLength {
+		return "", NewValidationError("ScanIgnoreList", fmt.Sprintf("entry longer than %d bytes", MaxScanIgnoreEntryLength))
+	}
+	return entry, nil
+}
+
+// SanitizeDockEntry cleans a disarm_when_docked_to entry, which must be a
+// full device instance ID so only the home dock itself matches, not every
+// device of the same model
+func SanitizeDockEntry(entry string) (string, error) {
+	entry = strings.TrimSpace(RemoveControlChars(entry))
+	if entry == "" || len(entry) > MaxDockEntryLength {
+		return "", NewValidationError("DisarmWhenDockedTo", fmt.Sprintf("entry must be 1-%d characters", MaxDockEntryLength))
+	}
+	if !dockInstanceIDRegex.MatchString(entry) {
+		return "", NewValidationError("DisarmWhenDockedTo", fmt.Sprintf("%q is not a full device ID, copy one from home-sentry peripherals", entry))
+	}
+	return entry, nil
+}
+
 // SanitizeHostname validates and sanitizes a DNS hostname.
 // Hostnames come from external DNS lookups and must be sanitized before logging or display.
 func SanitizeHostname(hostname string) (string, error) {
@@ -167,6 +246,113 @@ func SanitizeDisplayString(s string) string {
 	return s
 }
 
+// preShutdownExtensions lists the file types allowed as a pre-shutdown command
+var preShutdownExtensions = map[string]bool{
+	".exe": true,
+	".bat": true,
+	".cmd": true,
+	".ps1": true,
+}
+
+// ValidatePreShutdownCommand checks that a pre-shutdown command is a single
+// existing executable or script inside the user's profile. Shell strings and
+// arguments are never accepted - only a path.
+func ValidatePreShutdownCommand(path string) error {
+	if path == "" {
+		return nil
+	}
+	if len(path) > MaxCommandPathLength {
+		return NewValidationError("PreShutdownCommand", "Pre-shutdown command path is too long")
+	}
+	if !filepath.IsAbs(path) {
+		return NewValidationError("PreShutdownCommand", "Pre-shutdown command must be an absolute path")
+	}
+	if !preShutdownExtensions[strings.ToLower(filepath.Ext(path))] {
+		return NewValidationError("PreShutdownCommand", "Pre-shutdown command must be an .exe, .bat, .cmd or .ps1 file")
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return NewValidationError("PreShutdownCommand", "Pre-shutdown command does not exist")
+	}
+	info, err := os.Stat(resolved)
+	if err != nil || !info.Mode().IsRegular() {
+		return NewValidationError("PreShutdownCommand", "Pre-shutdown command is not a regular file")
+	}
+
+	profile := os.Getenv("USERPROFILE")
+	if profile == "" {
+		profile, _ = os.UserHomeDir()
+	}
+	if profile == "" {
+		return NewValidationError("PreShutdownCommand", "User profile directory is unknown")
+	}
+	if p, err := filepath.EvalSymlinks(profile); err == nil {
+		profile = p
+	}
+	rel, err := filepath.Rel(profile, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return NewValidationError("PreShutdownCommand", "Pre-shutdown command must be inside your user profile")
+	}
+
+	return nil
+}
+
+// ValidateWarningSoundFile checks that the warning sound is an existing WAV
+// file, given as an absolute path or a file:// URL
+func ValidateWarningSoundFile(value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > MaxCommandPathLength {
+		return NewValidationError("WarningSoundFile", "Warning sound path is too long")
+	}
+	path := soundFileLocalPath(value)
+	if path == "" || !filepath.IsAbs(path) {
+		return NewValidationError("WarningSoundFile", "Warning sound must be an absolute path or a local file:// URL")
+	}
+	if strings.ToLower(filepath.Ext(path)) != ".wav" {
+		return NewValidationError("WarningSoundFile", "Warning sound must be a .wav file")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return NewValidationError("WarningSoundFile", "Warning sound file does not exist")
+	}
+	if info.Size() > MaxWarningSoundSize {
+		return NewValidationError("WarningSoundFile", fmt.Sprintf("Warning sound is larger than %d MiB", MaxWarningSoundSize>>20))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return NewValidationError("WarningSoundFile", "Warning sound file cannot be read")
+	}
+	defer f.Close()
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil || !bytes.Equal(header[0:4], []byte("RIFF")) || !bytes.Equal(header[8:12], []byte("WAVE")) {
+		return NewValidationError("WarningSoundFile", "Warning sound is not a WAV file")
+	}
+	return nil
+}
+
+// soundFileLocalPath turns a file:// URL into a local path. Plain paths are
+// returned unchanged and URLs pointing at another host yield "".
+func soundFileLocalPath(value string) string {
+	if !strings.HasPrefix(strings.ToLower(value), "file://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Host != "" && !strings.EqualFold(u.Host, "localhost")) {
+		return ""
+	}
+	path := u.Path
+	// file:///C:/alarm.wav has a slash before the drive letter
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
 // ValidationError represents a validation error with user-friendly message
 type ValidationError struct {
 	Field   string
@@ -177,6 +363,11 @@ func (e *ValidationError) Error() string {
 	return e.Message
 }
 
+// Is makes every ValidationError match ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
 // NewValidationError creates a new validation error
 func NewValidationError(field, message string) *ValidationError {
 	return &ValidationError{
@@ -184,3 +375,15 @@ func NewValidationError(field, message string) *ValidationError {
 		Message: message,
 	}
 }
+
+// ValidateScanCIDR checks that cidr is an IPv4 range small enough to sweep
+func ValidateScanCIDR(cidr string) error {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil || ipNet.IP.To4() == nil {
+		return NewValidationError("ScanCIDRs", fmt.Sprintf("%q is not an IPv4 CIDR like 192.168.2.0/24", cidr))
+	}
+	if ones, _ := ipNet.Mask.Size(); ones < MinScanCIDRPrefix {
+		return NewValidationError("ScanCIDRs", fmt.Sprintf("%q is too large to sweep (smallest prefix is /%d)", cidr, MinScanCIDRPrefix))
+	}
+	return nil
+}
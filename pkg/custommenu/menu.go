@@ -3,6 +3,7 @@ package custommenu
 import (
 	"image/color"
 	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -12,13 +13,13 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
-// Colors for the menu
+// Colors for the menu, set from a Palette by ApplyTheme
 var (
-	MenuBackground     = color.RGBA{R: 30, G: 30, B: 30, A: 255}
-	MenuHoverColor     = color.RGBA{R: 0, G: 120, B: 215, A: 255} // Windows blue
-	MenuTextColor      = color.White
-	MenuDisabledColor  = color.RGBA{R: 128, G: 128, B: 128, A: 255}
-	MenuSeparatorColor = color.RGBA{R: 60, G: 60, B: 60, A: 255}
+	MenuBackground     = DarkPalette.Background
+	MenuHoverColor     = DarkPalette.Hover
+	MenuTextColor      = DarkPalette.Text
+	MenuDisabledColor  = DarkPalette.Disabled
+	MenuSeparatorColor = DarkPalette.Separator
 )
 
 // MenuItem represents a single menu item
@@ -30,9 +31,13 @@ type MenuItem struct {
 	IsSeparator bool
 	OnTapped    func()
 
+	// Indicator, when set, is drawn before the text
+	Indicator *StatusIndicator
+
 	hovered    bool
 	background *canvas.Rectangle
 	label      *canvas.Text
+	menu       *PopupMenu
 	mu         sync.Mutex
 }
 
@@ -81,9 +86,14 @@ func (m *MenuItem) CreateRenderer() fyne.WidgetRenderer {
 		m.label.Color = MenuDisabledColor
 	}
 
+	var row fyne.CanvasObject = m.label
+	if m.Indicator != nil {
+		row = container.NewHBox(m.Indicator, m.label)
+	}
+
 	content := container.NewStack(
 		m.background,
-		container.NewPadded(m.label),
+		container.NewPadded(row),
 	)
 
 	return widget.NewSimpleRenderer(content)
@@ -155,15 +165,62 @@ func (m *MenuItem) SetText(text string) {
 	}
 }
 
+// SetDisabled grays the item out, or restores it. Disabled items ignore
+// hover and taps. Call from the Fyne thread.
+func (m *MenuItem) SetDisabled(disabled bool) {
+	m.mu.Lock()
+	m.Disabled = disabled
+	m.hovered = false
+	m.mu.Unlock()
+
+	if m.label != nil {
+		if disabled {
+			m.label.Color = MenuDisabledColor
+		} else {
+			m.label.Color = MenuTextColor
+		}
+		m.label.Refresh()
+	}
+	if m.background != nil {
+		m.background.FillColor = MenuBackground
+		m.background.Refresh()
+	}
+}
+
+// Show makes a hidden item visible again and resizes its menu to fit
+func (m *MenuItem) Show() {
+	m.BaseWidget.Show()
+	if m.menu != nil {
+		m.menu.relayout()
+	}
+}
+
+// Hide removes the item from its menu's layout until Show is called
+func (m *MenuItem) Hide() {
+	m.BaseWidget.Hide()
+	if m.menu != nil {
+		m.menu.relayout()
+	}
+}
+
+// focusGrace is how long after the menu opens (or auto-hides) focus changes
+// are attributed to the same tray click
+const focusGrace = 300 * time.Millisecond
+
 // PopupMenu is a custom styled popup menu
 type PopupMenu struct {
 	Window  fyne.Window
 	Items   []*MenuItem
 	app     fyne.App
+	box     *fyne.Container
 	visible bool
+
+	shownAt      time.Time
+	autoHiddenAt time.Time
 }
 
-// NewPopupMenu creates a new popup menu
+// NewPopupMenu creates a new popup menu. Like a native tray menu it hides
+// when the app loses focus; this takes over the app's exited-foreground hook.
 func NewPopupMenu(app fyne.App, title string) *PopupMenu {
 	w := app.NewWindow(title)
 	w.SetPadded(false)
@@ -173,10 +230,21 @@ func NewPopupMenu(app fyne.App, title string) *PopupMenu {
 		Items:  make([]*MenuItem, 0),
 		app:    app,
 	}
+	app.Lifecycle().SetOnExitedForeground(menu.onFocusLost)
 
 	return menu
 }
 
+// onFocusLost hides the menu when the user clicks outside the app
+func (p *PopupMenu) onFocusLost() {
+	// The click that opened the menu can still move focus around
+	if !p.visible || time.Since(p.shownAt) < focusGrace {
+		return
+	}
+	p.Hide()
+	p.autoHiddenAt = time.Now()
+}
+
 // AddItem adds a menu item
 func (p *PopupMenu) AddItem(text string, onTapped func()) *MenuItem {
 	item := NewMenuItem(text, func() {
@@ -185,6 +253,7 @@ func (p *PopupMenu) AddItem(text string, onTapped func()) *MenuItem {
 		}
 		p.Hide()
 	})
+	item.menu = p
 	p.Items = append(p.Items, item)
 	return item
 }
@@ -192,15 +261,28 @@ func (p *PopupMenu) AddItem(text string, onTapped func()) *MenuItem {
 // AddDisabledItem adds a disabled info item
 func (p *PopupMenu) AddDisabledItem(text string) *MenuItem {
 	item := NewDisabledMenuItem(text)
+	item.menu = p
 	p.Items = append(p.Items, item)
 	return item
 }
 
+// AddStatusItem adds a disabled info item led by a status indicator
+func (p *PopupMenu) AddStatusItem(text string) *MenuItem {
+	item := p.AddDisabledItem(text)
+	item.Indicator = NewStatusIndicator()
+	return item
+}
+
 // AddSeparator adds a separator
 func (p *PopupMenu) AddSeparator() {
 	p.Items = append(p.Items, NewSeparator())
 }
 
+// Clear removes all items so the menu can be rebuilt
+func (p *PopupMenu) Clear() {
+	p.Items = p.Items[:0]
+}
+
 // Build finalizes the menu layout
 func (p *PopupMenu) Build() {
 	bg := canvas.NewRectangle(MenuBackground)
@@ -209,18 +291,32 @@ func (p *PopupMenu) Build() {
 	for _, item := range p.Items {
 		vbox.Add(item)
 	}
+	p.box = vbox
 
 	content := container.NewStack(bg, container.NewPadded(vbox))
 	p.Window.SetContent(content)
 	p.Window.Resize(fyne.NewSize(300, float32(len(p.Items)*30+20)))
 }
 
+// relayout re-flows the items after one was shown or hidden
+func (p *PopupMenu) relayout() {
+	if p.box == nil {
+		return
+	}
+	p.box.Refresh()
+	if p.visible {
+		p.Window.Resize(p.Window.Content().MinSize())
+	}
+}
+
 // Show displays the menu at the given position
 func (p *PopupMenu) Show(x, y int) {
 	// Position window near cursor
 	p.Window.Resize(p.Window.Content().MinSize())
 	p.Window.Show()
+	p.Window.RequestFocus()
 	p.visible = true
+	p.shownAt = time.Now()
 }
 
 // Hide hides the menu
@@ -233,9 +329,14 @@ func (p *PopupMenu) Hide() {
 func (p *PopupMenu) Toggle() {
 	if p.visible {
 		p.Hide()
-	} else {
-		p.Show(0, 0)
+		return
+	}
+	// A tray click meant to close the menu first takes focus away from it,
+	// which already hid it; don't reopen for that same click
+	if time.Since(p.autoHiddenAt) < focusGrace {
+		return
 	}
+	p.Show(0, 0)
 }
 
 // IsVisible returns whether the menu is currently visible
@@ -243,10 +344,10 @@ func (p *PopupMenu) IsVisible() bool {
 	return p.visible
 }
 
-// CustomTheme is a dark theme for the menu
+// CustomTheme applies the menu palette chosen by ApplyTheme
 type CustomTheme struct{}
 
-func (t *CustomTheme) Color(n fyne.ThemeColorName, v fyne.ThemeVariant) color.Color {
+func (t *CustomTheme) Color(n fyne.ThemeColorName, _ fyne.ThemeVariant) color.Color {
 	switch n {
 	case theme.ColorNameBackground:
 		return MenuBackground
@@ -255,7 +356,7 @@ func (t *CustomTheme) Color(n fyne.ThemeColorName, v fyne.ThemeVariant) color.Co
 	case theme.ColorNameForeground:
 		return MenuTextColor
 	default:
-		return theme.DefaultTheme().Color(n, v)
+		return theme.DefaultTheme().Color(n, themeVariant)
 	}
 }
 
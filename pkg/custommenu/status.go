@@ -0,0 +1,91 @@
+package custommenu
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Status colors, matching the tray icons
+var (
+	StatusGreenColor  = color.RGBA{R: 46, G: 204, B: 64, A: 255}
+	StatusYellowColor = color.RGBA{R: 255, G: 193, B: 7, A: 255}
+	StatusRedColor    = color.RGBA{R: 231, G: 76, B: 60, A: 255}
+)
+
+// indicatorDiameter is the size of the status dot
+const indicatorDiameter = 10
+
+// StatusLevel is how urgent the shown status is, one per tray icon color
+type StatusLevel int
+
+const (
+	StatusSafe StatusLevel = iota
+	StatusWarning
+	StatusDanger
+)
+
+// StatusColor returns the color the tray icon uses for a status level
+func StatusColor(level StatusLevel) color.Color {
+	switch level {
+	case StatusDanger:
+		return StatusRedColor
+	case StatusWarning:
+		return StatusYellowColor
+	default:
+		return StatusGreenColor
+	}
+}
+
+// StatusIndicator is a colored dot reflecting the current sentry status
+type StatusIndicator struct {
+	widget.BaseWidget
+	dot *canvas.Circle
+}
+
+// NewStatusIndicator creates an indicator showing the default (green) color
+func NewStatusIndicator() *StatusIndicator {
+	s := &StatusIndicator{
+		dot: canvas.NewCircle(StatusGreenColor),
+	}
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+// SetStatus recolors the dot for the given level. Call from the Fyne thread.
+func (s *StatusIndicator) SetStatus(level StatusLevel) {
+	s.dot.FillColor = StatusColor(level)
+	s.dot.Refresh()
+}
+
+// CreateRenderer implements fyne.Widget
+func (s *StatusIndicator) CreateRenderer() fyne.WidgetRenderer {
+	return &statusIndicatorRenderer{dot: s.dot}
+}
+
+// statusIndicatorRenderer keeps the dot round and centered whatever height
+// the row gives it
+type statusIndicatorRenderer struct {
+	dot *canvas.Circle
+}
+
+func (r *statusIndicatorRenderer) Layout(size fyne.Size) {
+	r.dot.Resize(fyne.NewSize(indicatorDiameter, indicatorDiameter))
+	r.dot.Move(fyne.NewPos((size.Width-indicatorDiameter)/2, (size.Height-indicatorDiameter)/2))
+}
+
+func (r *statusIndicatorRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(indicatorDiameter, indicatorDiameter)
+}
+
+func (r *statusIndicatorRenderer) Refresh() {
+	r.dot.Refresh()
+}
+
+func (r *statusIndicatorRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.dot}
+}
+
+func (r *statusIndicatorRenderer) Destroy() {}
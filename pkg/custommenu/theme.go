@@ -0,0 +1,67 @@
+package custommenu
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2/theme"
+)
+
+// Palette holds the colors the menu is drawn with
+type Palette struct {
+	Background color.Color
+	Hover      color.Color
+	Text       color.Color
+	Disabled   color.Color
+	Separator  color.Color
+}
+
+// Built-in palettes
+var (
+	DarkPalette = Palette{
+		Background: color.RGBA{R: 30, G: 30, B: 30, A: 255},
+		Hover:      color.RGBA{R: 0, G: 120, B: 215, A: 255}, // Windows blue
+		Text:       color.White,
+		Disabled:   color.RGBA{R: 128, G: 128, B: 128, A: 255},
+		Separator:  color.RGBA{R: 60, G: 60, B: 60, A: 255},
+	}
+	LightPalette = Palette{
+		Background: color.RGBA{R: 243, G: 243, B: 243, A: 255},
+		Hover:      color.RGBA{R: 204, G: 228, B: 247, A: 255},
+		Text:       color.Black,
+		Disabled:   color.RGBA{R: 120, G: 120, B: 120, A: 255},
+		Separator:  color.RGBA{R: 215, G: 215, B: 215, A: 255},
+	}
+)
+
+// Theme selects the menu palette
+type Theme int
+
+const (
+	ThemeDark Theme = iota
+	ThemeLight
+	ThemeSystem // Follow the Windows apps theme
+)
+
+// themeVariant is passed to the Fyne default theme for colors the menu
+// doesn't override, so dialogs match the menu
+var themeVariant = theme.VariantDark
+
+// ApplyTheme switches the menu colors to the given theme. Items built
+// afterwards use the new colors. Call before the app runs or from the Fyne
+// thread.
+func ApplyTheme(t Theme) {
+	light := t == ThemeLight || (t == ThemeSystem && systemPrefersLight())
+
+	palette := DarkPalette
+	themeVariant = theme.VariantDark
+	if light {
+		palette = LightPalette
+		themeVariant = theme.VariantLight
+	}
+
+	MenuBackground = palette.Background
+	MenuHoverColor = palette.Hover
+	MenuTextColor = palette.Text
+	MenuDisabledColor = palette.Disabled
+	MenuSeparatorColor = palette.Separator
+}
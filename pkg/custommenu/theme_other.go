@@ -0,0 +1,9 @@
+//go:build !windows
+
+package custommenu
+
+// systemPrefersLight has no system setting to read outside Windows, so the
+// menu keeps its dark palette
+func systemPrefersLight() bool {
+	return false
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package custommenu
+
+import "golang.org/x/sys/windows/registry"
+
+const personalizeKey = `Software\Microsoft\Windows\CurrentVersion\Themes\Personalize`
+
+// systemPrefersLight reports whether Windows is set to the light apps theme
+func systemPrefersLight() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, personalizeKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+
+	value, _, err := key.GetIntegerValue("AppsUseLightTheme")
+	return err == nil && value == 1
+}
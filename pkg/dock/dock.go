@@ -0,0 +1,52 @@
+// Package dock lists the peripherals attached to the machine, so a known
+// monitor or USB device (e.g. a dock at the home desk) can vouch for it.
+package dock
+
+import "strings"
+
+// Match returns the first peripheral whose device instance ID is one of ids,
+// compared case-insensitively, or "" if none is. The instance ID includes the
+// serial number or port of the device, so another dock or monitor of the
+// same model doesn't match.
+func Match(peripherals, ids []string) string {
+	for _, peripheral := range peripherals {
+		peripheralID := ID(peripheral)
+		for _, id := range ids {
+			if id != "" && strings.EqualFold(peripheralID, id) {
+				return peripheral
+			}
+		}
+	}
+	return ""
+}
+
+// ID returns the device instance ID of a peripheral listed by Peripherals
+func ID(peripheral string) string {
+	if i := strings.LastIndex(peripheral, " ("); i >= 0 && strings.HasSuffix(peripheral, ")") {
+		return peripheral[i+2 : len(peripheral)-1]
+	}
+	return peripheral
+}
+
+// instanceID turns a device interface path like
+// \\?\DISPLAY#DEL4231#5&1a2b3c4d&0&UID4353#{e6f07b5f-...} into the device
+// instance ID DISPLAY\DEL4231\5&1a2b3c4d&0&UID4353, which tells two monitors
+// of the same model apart
+func instanceID(path string) string {
+	path = strings.TrimPrefix(path, `\\?\`)
+	if i := strings.LastIndex(path, "#{"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.ReplaceAll(path, "#", `\`)
+}
+
+// describe formats a peripheral as its name followed by its hardware ID
+func describe(name, id string) string {
+	switch {
+	case name == "":
+		return id
+	case id == "":
+		return name
+	}
+	return name + " (" + id + ")"
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package dock
+
+// Peripherals is only implemented on Windows; elsewhere nothing is attached
+func Peripherals() ([]string, error) {
+	return nil, nil
+}
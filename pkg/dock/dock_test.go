@@ -0,0 +1,45 @@
+package dock
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	peripherals := []string{
+		describe("Generic PnP Monitor", `DISPLAY\DEL4231\5&1A2B3C4D&0&UID4353`),
+		describe("USB Composite Device", `USB\VID_17EF&PID_3066\5&2A1B2C3D&0&1`),
+		describe("", `USB\ROOT_HUB30\4&1234`),
+	}
+
+	tests := []struct {
+		ids  []string
+		want string
+	}{
+		{[]string{`display\del4231\5&1a2b3c4d&0&uid4353`}, peripherals[0]},
+		{[]string{"", `USB\VID_17EF&PID_3066\5&2A1B2C3D&0&1`}, peripherals[1]},
+		{[]string{`USB\ROOT_HUB30\4&1234`}, `USB\ROOT_HUB30\4&1234`},
+		{[]string{"DEL4231", `USB\VID_17EF&PID_3066`}, ""},     // Same model elsewhere
+		{[]string{`USB\VID_17EF&PID_3066\5&9F8E7D6C&0&2`}, ""}, // Another dock of that model
+		{[]string{"Generic PnP Monitor"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Match(peripherals, tt.ids); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.ids, got, tt.want)
+		}
+	}
+}
+
+func TestInstanceID(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`\\?\DISPLAY#DEL4231#5&1a2b3c4d&0&UID4353#{e6f07b5f-ee97-4a90-b076-33f57bf4eaa7}`, `DISPLAY\DEL4231\5&1a2b3c4d&0&UID4353`},
+		{`MONITOR\DEL4231\{4d36e96e-e325-11ce-bfc1-08002be10318}\0001`, `MONITOR\DEL4231\{4d36e96e-e325-11ce-bfc1-08002be10318}\0001`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := instanceID(tt.path); got != tt.want {
+			t.Errorf("instanceID(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+//go:build windows
+
+package dock
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32                  = windows.NewLazySystemDLL("user32.dll")
+	procEnumDisplayDevicesW = user32.NewProc("EnumDisplayDevicesW")
+)
+
+const (
+	// displayDeviceActive marks a monitor that is attached to its adapter
+	displayDeviceActive = 0x1
+	// eddGetDeviceInterfaceName asks for the interface path, which carries
+	// the monitor's instance ID, instead of its model ID
+	eddGetDeviceInterfaceName = 0x1
+)
+
+// displayDevice mirrors DISPLAY_DEVICEW
+type displayDevice struct {
+	cb           uint32
+	deviceName   [32]uint16
+	deviceString [128]uint16
+	stateFlags   uint32
+	deviceID     [128]uint16
+	deviceKey    [128]uint16
+}
+
+// Peripherals lists the attached monitors, e.g. "DELL U2720Q
+// (DISPLAY\DEL4231\5&1A2B3C4D&0&UID4353)", followed by the present USB
+// devices, e.g. "USB Hub (USB\VID_17EF&PID_3066\5&2A1B2C3D&0&1)"
+func Peripherals() ([]string, error) {
+	peripherals := monitors()
+	usb, err := usbDevices()
+	return append(peripherals, usb...), err
+}
+
+// enumDisplayDevice returns the index-th display device of parent, or of the
+// whole system when parent is nil
+func enumDisplayDevice(parent *uint16, index, flags uint32) (displayDevice, bool) {
+	var device displayDevice
+	device.cb = uint32(unsafe.Sizeof(device))
+	ok, _, _ := procEnumDisplayDevicesW.Call(uintptr(unsafe.Pointer(parent)), uintptr(index), uintptr(unsafe.Pointer(&device)), uintptr(flags))
+	return device, ok != 0
+}
+
+// monitors lists the monitors attached to every display adapter
+func monitors() []string {
+	if procEnumDisplayDevicesW.Find() != nil {
+		return nil
+	}
+	var names []string
+	for i := uint32(0); ; i++ {
+		adapter, ok := enumDisplayDevice(nil, i, 0)
+		if !ok {
+			break
+		}
+		for j := uint32(0); ; j++ {
+			monitor, ok := enumDisplayDevice(&adapter.deviceName[0], j, eddGetDeviceInterfaceName)
+			if !ok {
+				break
+			}
+			if monitor.stateFlags&displayDeviceActive != 0 {
+				id := instanceID(windows.UTF16ToString(monitor.deviceID[:]))
+				names = append(names, describe(windows.UTF16ToString(monitor.deviceString[:]), id))
+			}
+		}
+	}
+	return names
+}
+
+// usbDevices lists the USB devices that are currently present
+func usbDevices() ([]string, error) {
+	devices, err := windows.SetupDiGetClassDevsEx(nil, "USB", 0, windows.DIGCF_PRESENT|windows.DIGCF_ALLCLASSES, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	defer devices.Close()
+
+	var names []string
+	for i := 0; ; i++ {
+		info, err := devices.EnumDeviceInfo(i)
+		if err == windows.ERROR_NO_MORE_ITEMS {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		id, err := devices.DeviceInstanceID(info)
+		if err != nil {
+			continue
+		}
+		name, _ := devices.DeviceRegistryProperty(info, windows.SPDRP_FRIENDLYNAME)
+		if s, _ := name.(string); s == "" {
+			name, _ = devices.DeviceRegistryProperty(info, windows.SPDRP_DEVICEDESC)
+		}
+		s, _ := name.(string)
+		names = append(names, describe(s, id))
+	}
+	return names, nil
+}
@@ -0,0 +1,43 @@
+package logger
+
+import "errors"
+
+// EventSource is the source Home Sentry's events are logged under in the
+// Windows Application log
+const EventSource = "HomeSentry"
+
+// errEventLogUnsupported is returned by EnableEventLog outside Windows
+var errEventLogUnsupported = errors.New("the event log is only available on Windows")
+
+// eventSink receives the lines mirrored to the system event log: warnings,
+// errors and audit events
+type eventSink interface {
+	Write(level LogLevel, message string) error
+	Close() error
+}
+
+// setEventSink replaces the logger's event sink, closing the previous one
+func (l *Logger) setEventSink(sink eventSink) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.events != nil {
+		l.events.Close()
+	}
+	l.events = sink
+}
+
+// EnableEventLog mirrors warnings, errors and audit events of the global
+// logger into the Windows Application log, registering the event source on
+// first use. An error means nothing is mirrored; a registration that failed
+// for lack of elevation is only logged, since events are still written.
+func EnableEventLog() error {
+	if defaultLogger == nil {
+		return errors.New("logger not initialized")
+	}
+	sink, err := openEventLog()
+	if err != nil {
+		return err
+	}
+	defaultLogger.setEventSink(sink)
+	return nil
+}
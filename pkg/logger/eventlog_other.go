@@ -0,0 +1,7 @@
+//go:build !windows
+
+package logger
+
+func openEventLog() (eventSink, error) {
+	return nil, errEventLogUnsupported
+}
@@ -0,0 +1,58 @@
+//go:build windows
+
+package logger
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// Event IDs, one per kind of line; EventCreate.exe's message file accepts
+// IDs 1 to 1000
+const (
+	eventIDAudit   = 1
+	eventIDWarning = 2
+	eventIDError   = 3
+)
+
+// windowsEventLog writes to the Application log
+type windowsEventLog struct {
+	log *eventlog.Log
+}
+
+func openEventLog() (eventSink, error) {
+	// Registering the source writes under HKLM and needs elevation. Without
+	// it Windows still logs the events, only with a note that their
+	// description is missing, so a failure is not fatal.
+	err := eventlog.InstallAsEventCreate(EventSource, eventlog.Error|eventlog.Warning|eventlog.Info)
+	registerErr := err
+	if err != nil && strings.HasSuffix(err.Error(), "registry key already exists") {
+		registerErr = nil
+	}
+
+	log, err := eventlog.Open(EventSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the event log: %w", err)
+	}
+	if registerErr != nil {
+		Warn("Failed to register event source %s, run Home Sentry elevated once to register it: %v", EventSource, registerErr)
+	}
+	return windowsEventLog{log: log}, nil
+}
+
+func (w windowsEventLog) Write(level LogLevel, message string) error {
+	switch level {
+	case ERROR:
+		return w.log.Error(eventIDError, message)
+	case WARN:
+		return w.log.Warning(eventIDWarning, message)
+	default:
+		return w.log.Info(eventIDAudit, message)
+	}
+}
+
+func (w windowsEventLog) Close() error {
+	return w.log.Close()
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"home-sentry/pkg/paths"
 	"io"
 	"log"
 	"os"
@@ -20,6 +21,9 @@ const (
 	INFO
 	WARN
 	ERROR
+	// AUDIT records security-relevant actions such as pausing protection or
+	// a shutdown. It is never filtered out by the log level.
+	AUDIT
 )
 
 var levelNames = map[LogLevel]string{
@@ -27,6 +31,7 @@ var levelNames = map[LogLevel]string{
 	INFO:  "INFO",
 	WARN:  "WARN",
 	ERROR: "ERROR",
+	AUDIT: "AUDIT",
 }
 
 type Logger struct {
@@ -37,13 +42,18 @@ type Logger struct {
 	currentDate string
 	writers     io.Writer
 	done        chan struct{}
+	closed      bool
+	console     bool // Also write to stdout
+	recent      *ringBuffer
+	events      eventSink // Mirrors WARN and above to the system event log
 }
 
 var defaultLogger *Logger
 
-// Init initializes the global logger
-func Init(logDir string, level LogLevel) error {
-	logger, err := NewLogger(logDir, level)
+// Init initializes the global logger. Log files older than retention are
+// deleted; zero keeps them forever.
+func Init(logDir string, level LogLevel, retention time.Duration) error {
+	logger, err := NewLogger(logDir, level, retention)
 	if err != nil {
 		return err
 	}
@@ -56,16 +66,19 @@ func Init(logDir string, level LogLevel) error {
 	return nil
 }
 
-// NewLogger creates a new logger instance
-func NewLogger(logDir string, level LogLevel) (*Logger, error) {
+// NewLogger creates a new logger instance. Log files older than retention are
+// deleted daily; zero keeps them forever.
+func NewLogger(logDir string, level LogLevel, retention time.Duration) (*Logger, error) {
 	if err := os.MkdirAll(logDir, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create log directory: %w", err)
 	}
 
 	l := &Logger{
-		level:  level,
-		logDir: logDir,
-		done:   make(chan struct{}),
+		level:   level,
+		logDir:  logDir,
+		done:    make(chan struct{}),
+		console: true,
+		recent:  newRingBuffer(RecentLogSize),
 	}
 
 	if err := l.rotateLogFile(); err != nil {
@@ -73,7 +86,9 @@ func NewLogger(logDir string, level LogLevel) (*Logger, error) {
 	}
 
 	// Cleanup old logs
-	go l.cleanupOldLogs(7 * 24 * time.Hour)
+	if retention > 0 {
+		go l.cleanupOldLogs(retention)
+	}
 
 	return l, nil
 }
@@ -82,6 +97,10 @@ func (l *Logger) rotateLogFile() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.closed {
+		return nil // Console only after Close
+	}
+
 	today := time.Now().Format("2006-01-02")
 	if l.currentDate == today && l.file != nil {
 		return nil
@@ -101,11 +120,35 @@ func (l *Logger) rotateLogFile() error {
 
 	l.file = file
 	l.currentDate = today
-	l.writers = io.MultiWriter(os.Stdout, file)
+	l.updateWriters()
 
 	return nil
 }
 
+// updateWriters points the output at the log file and, if enabled, stdout.
+// Callers must hold l.mu.
+func (l *Logger) updateWriters() {
+	switch {
+	case l.file != nil && l.console:
+		l.writers = io.MultiWriter(os.Stdout, l.file)
+	case l.file != nil:
+		l.writers = l.file
+	case l.console:
+		l.writers = os.Stdout
+	default:
+		l.writers = nil
+	}
+}
+
+// SetConsoleOutput turns copying log lines to stdout on or off. The windowless
+// tray has no console to write to, while CLI commands want to see the log.
+func (l *Logger) SetConsoleOutput(enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.console = enabled
+	l.updateWriters()
+}
+
 func (l *Logger) cleanupOldLogs(maxAge time.Duration) {
 	ticker := time.NewTicker(24 * time.Hour)
 	defer ticker.Stop()
@@ -192,6 +235,13 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	if l.writers != nil {
 		l.writers.Write([]byte(logLine))
 	}
+	l.recent.add(strings.TrimSuffix(logLine, "\n"))
+
+	if l.events != nil && level >= WARN {
+		if err := l.events.Write(level, message); err != nil && l.writers != nil {
+			fmt.Fprintf(l.writers, "[%s] [ERROR] [%s] Failed to write to the event log: %v\n", timestamp, caller, err)
+		}
+	}
 }
 
 // Write implements io.Writer for compatibility with standard log package
@@ -211,10 +261,37 @@ func (l *Logger) Close() error {
 		close(l.done)
 	}
 
+	l.recent.close()
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.closed = true
+	if l.events != nil {
+		l.events.Close()
+		l.events = nil
+	}
+	var err error
 	if l.file != nil {
-		return l.file.Close()
+		l.file.Sync()
+		err = l.file.Close()
+		l.file = nil
+	}
+	l.updateWriters()
+	return err
+}
+
+// SetConsoleOutput turns copying log lines to stdout on or off for the global logger
+func SetConsoleOutput(enabled bool) {
+	if defaultLogger != nil {
+		defaultLogger.SetConsoleOutput(enabled)
+	}
+}
+
+// Close flushes and closes the global logger. Later log calls only go to the
+// console, if console output is enabled.
+func Close() error {
+	if defaultLogger != nil {
+		return defaultLogger.Close()
 	}
 	return nil
 }
@@ -244,17 +321,43 @@ func Error(format string, args ...interface{}) {
 	}
 }
 
+// Audit logs a security-relevant action, whatever the log level
+func Audit(format string, args ...interface{}) {
+	if defaultLogger != nil {
+		defaultLogger.log(AUDIT, format, args...)
+	}
+}
+
 // GetLogDir returns the log directory path
 func GetLogDir() string {
-	appData := os.Getenv("APPDATA")
-	if appData == "" {
-		return "logs"
+	return filepath.Join(paths.AppDir(), "logs")
+}
+
+// Subscribe returns a channel that receives every new log line. Lines are
+// dropped for subscribers that fall too far behind. Call Unsubscribe when done.
+func Subscribe() <-chan string {
+	if defaultLogger == nil {
+		ch := make(chan string)
+		close(ch)
+		return ch
 	}
-	return filepath.Join(appData, "HomeSentry", "logs")
+	return defaultLogger.recent.subscribe()
 }
 
-// GetRecentLogs returns the most recent log entries
+// Unsubscribe stops delivery to a channel returned by Subscribe and closes it
+func Unsubscribe(ch <-chan string) {
+	if defaultLogger != nil {
+		defaultLogger.recent.unsubscribe(ch)
+	}
+}
+
+// GetRecentLogs returns the most recent log entries. They come from memory
+// when this process has logged enough lines, otherwise from today's log file.
 func GetRecentLogs(count int) ([]string, error) {
+	if defaultLogger != nil && defaultLogger.recent.len() >= count {
+		return defaultLogger.recent.last(count), nil
+	}
+
 	logDir := GetLogDir()
 	files, err := filepath.Glob(filepath.Join(logDir, "home-sentry-*.log"))
 	if err != nil {
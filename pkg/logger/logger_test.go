@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetConsoleOutput(t *testing.T) {
+	l, err := NewLogger(t.TempDir(), INFO, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	l.SetConsoleOutput(false)
+	l.log(INFO, "file only")
+	l.SetConsoleOutput(true)
+	l.log(INFO, "file and console")
+	os.Stdout = stdout
+	w.Close()
+
+	console, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(console), "file only") {
+		t.Error("Line logged with console output disabled reached stdout")
+	}
+	if !strings.Contains(string(console), "file and console") {
+		t.Error("Line logged with console output enabled missing from stdout")
+	}
+
+	data, err := os.ReadFile(l.file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"file only", "file and console"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Log file is missing %q", want)
+		}
+	}
+}
+
+// fakeEventSink records what would have gone to the event log
+type fakeEventSink struct {
+	lines  []string
+	closed bool
+}
+
+func (f *fakeEventSink) Write(level LogLevel, message string) error {
+	f.lines = append(f.lines, levelNames[level]+" "+message)
+	return nil
+}
+
+func (f *fakeEventSink) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestEventSinkMirrorsWarningsAndAudits(t *testing.T) {
+	l, err := NewLogger(t.TempDir(), ERROR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetConsoleOutput(false)
+	sink := &fakeEventSink{}
+	l.setEventSink(sink)
+
+	l.log(INFO, "routine check")
+	l.log(WARN, "below the log level")
+	l.log(ERROR, "failed %s", "badly")
+	l.log(AUDIT, "protection paused")
+	l.Close()
+
+	want := []string{"ERROR failed badly", "AUDIT protection paused"}
+	if strings.Join(sink.lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Event log got %q, want %q", sink.lines, want)
+	}
+	if !sink.closed {
+		t.Error("Close did not close the event sink")
+	}
+}
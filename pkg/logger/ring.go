@@ -0,0 +1,114 @@
+package logger
+
+import "sync"
+
+// RecentLogSize is how many log lines are kept in memory for the UI
+const RecentLogSize = 500
+
+// subscriberBuffer is how many lines a slow subscriber may fall behind
+// before new lines are dropped for it
+const subscriberBuffer = 64
+
+// ringBuffer keeps the most recent log lines in memory and fans new lines
+// out to subscribers. It is safe for concurrent use.
+type ringBuffer struct {
+	mu          sync.Mutex
+	lines       []string
+	next        int
+	full        bool
+	subscribers map[<-chan string]chan string
+	closed      bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{
+		lines:       make([]string, size),
+		subscribers: make(map[<-chan string]chan string),
+	}
+}
+
+// add stores a line and delivers it to subscribers without blocking
+func (r *ringBuffer) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+
+	for _, ch := range r.subscribers {
+		select {
+		case ch <- line:
+		default: // Subscriber is behind; drop rather than stall logging
+		}
+	}
+}
+
+// last returns up to count of the most recent lines, oldest first
+func (r *ringBuffer) last(count int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := r.next
+	if r.full {
+		size = len(r.lines)
+	}
+	if count > size || count < 0 {
+		count = size
+	}
+
+	result := make([]string, 0, count)
+	start := r.next - count
+	for i := 0; i < count; i++ {
+		idx := (start + i + len(r.lines)) % len(r.lines)
+		result = append(result, r.lines[idx])
+	}
+	return result
+}
+
+// len returns how many lines are buffered
+func (r *ringBuffer) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.full {
+		return len(r.lines)
+	}
+	return r.next
+}
+
+func (r *ringBuffer) subscribe() <-chan string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ch := make(chan string, subscriberBuffer)
+	if r.closed {
+		close(ch)
+		return ch
+	}
+	r.subscribers[ch] = ch
+	return ch
+}
+
+func (r *ringBuffer) unsubscribe(ch <-chan string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sub, ok := r.subscribers[ch]; ok {
+		delete(r.subscribers, ch)
+		close(sub)
+	}
+}
+
+// close ends all subscriptions
+func (r *ringBuffer) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	for key, sub := range r.subscribers {
+		delete(r.subscribers, key)
+		close(sub)
+	}
+}
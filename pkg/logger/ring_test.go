@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRingBufferLast(t *testing.T) {
+	r := newRingBuffer(3)
+
+	if got := r.last(5); len(got) != 0 {
+		t.Errorf("Empty buffer returned %v", got)
+	}
+
+	r.add("a")
+	r.add("b")
+	if got := r.last(5); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("last(5) = %v, want [a b]", got)
+	}
+
+	r.add("c")
+	r.add("d")
+	if got := r.last(3); !reflect.DeepEqual(got, []string{"b", "c", "d"}) {
+		t.Errorf("last(3) after wrap = %v, want [b c d]", got)
+	}
+	if got := r.last(2); !reflect.DeepEqual(got, []string{"c", "d"}) {
+		t.Errorf("last(2) = %v, want [c d]", got)
+	}
+	if r.len() != 3 {
+		t.Errorf("len() = %d, want 3", r.len())
+	}
+}
+
+func TestRingBufferSubscribe(t *testing.T) {
+	r := newRingBuffer(10)
+	ch := r.subscribe()
+
+	r.add("hello")
+	if got := <-ch; got != "hello" {
+		t.Errorf("Subscriber got %q, want hello", got)
+	}
+
+	// A subscriber that never reads must not block logging
+	for i := 0; i < subscriberBuffer*2; i++ {
+		r.add(fmt.Sprintf("line %d", i))
+	}
+
+	r.unsubscribe(ch)
+	drained := 0
+	for range ch {
+		drained++
+	}
+	if drained != subscriberBuffer {
+		t.Errorf("Subscriber received %d buffered lines, want %d", drained, subscriberBuffer)
+	}
+
+	late := r.subscribe()
+	r.close()
+	if _, ok := <-late; ok {
+		t.Error("Subscription should be closed after close()")
+	}
+	if _, ok := <-r.subscribe(); ok {
+		t.Error("Subscribing after close should return a closed channel")
+	}
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"home-sentry/pkg/logger"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrCommandFailed matches every CommandError, so callers can tell a broken
+// system tool (arp, netsh, route) from a check that simply found nothing
+var ErrCommandFailed = errors.New("network command failed")
+
+// CommandError describes a system tool that could not be run or exited with
+// an error
+type CommandError struct {
+	Command  string // Command line as run
+	ExitCode int    // -1 if the command could not be started
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	detail := e.Stderr
+	if detail == "" {
+		detail = e.Err.Error()
+	}
+	if e.ExitCode < 0 {
+		return fmt.Sprintf("%s: %s", e.Command, detail)
+	}
+	return fmt.Sprintf("%s exited with code %d: %s", e.Command, e.ExitCode, detail)
+}
+
+func (e *CommandError) Is(target error) bool { return target == ErrCommandFailed }
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// runCommand runs a system tool without a console window and returns its
+// output. Failures are logged at debug level with the tool's stderr and exit
+// code, since callers mostly fall back to an empty result.
+func runCommand(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	HideConsole(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		err = newCommandError(cmd, err)
+		logger.Debug("%v", err)
+	}
+	return output, err
+}
+
+// newCommandError wraps the error of a finished cmd
+func newCommandError(cmd *exec.Cmd, err error) *CommandError {
+	cmdErr := &CommandError{Command: strings.Join(cmd.Args, " "), ExitCode: -1, Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+		cmdErr.Stderr = strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return cmdErr
+}
+
+// CheckTools runs the system tools scans and presence checks depend on and
+// returns the first failure, so doctor can say why a scan finds nothing
+func CheckTools() error {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+	if _, err := runCommand("arp", "-a"); err != nil {
+		return err
+	}
+	_, err := runCommand("route", "print", "-4", "0.0.0.0")
+	return err
+}
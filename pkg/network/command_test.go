@@ -0,0 +1,31 @@
+package network
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestRunCommandError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	_, err := runCommand("sh", "-c", "echo 'arp: not found' >&2; exit 3")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || !errors.Is(err, ErrCommandFailed) {
+		t.Fatalf("runCommand() error = %v, want a CommandError", err)
+	}
+	if cmdErr.ExitCode != 3 || cmdErr.Stderr != "arp: not found" {
+		t.Errorf("CommandError = %+v, want exit code 3 and the stderr line", cmdErr)
+	}
+
+	_, err = runCommand("home-sentry-no-such-tool")
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != -1 {
+		t.Errorf("runCommand() of a missing tool = %v, want exit code -1", err)
+	}
+
+	if _, err := runCommand("sh", "-c", "exit 0"); err != nil {
+		t.Errorf("runCommand() of a succeeding command = %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxScanDumps is how many scan dumps are kept; older ones are deleted
+const MaxScanDumps = 20
+
+// ScanDumpPattern matches the files written by dumpScan in the dump directory
+const ScanDumpPattern = "scan-*.txt"
+
+// dumpScan writes a scan's devices and the raw `arp -a` output it was parsed
+// from to a timestamped file in dir, then trims the dumps to MaxScanDumps
+func dumpScan(dir string, devices []NetworkDevice, rawARP string, at time.Time) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create dump directory: %w", err)
+	}
+
+	list, err := json.MarshalIndent(devices, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode devices: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Scan at %s\n\n", at.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Devices (%d):\n%s\n\n", len(devices), list)
+	fmt.Fprintf(&b, "Raw ARP table:\n%s\n", rawARP)
+
+	name := fmt.Sprintf("scan-%s.txt", at.Format("2006-01-02-150405.000"))
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write scan dump: %w", err)
+	}
+
+	return pruneScanDumps(dir, MaxScanDumps)
+}
+
+// pruneScanDumps deletes all but the newest keep dumps. The timestamp in the
+// file name sorts chronologically.
+func pruneScanDumps(dir string, keep int) error {
+	files, err := filepath.Glob(filepath.Join(dir, ScanDumpPattern))
+	if err != nil {
+		return err
+	}
+	if len(files) <= keep {
+		return nil
+	}
+	sort.Strings(files)
+	for _, file := range files[:len(files)-keep] {
+		os.Remove(file)
+	}
+	return nil
+}
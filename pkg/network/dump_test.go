@@ -0,0 +1,44 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpScan(t *testing.T) {
+	dir := t.TempDir()
+	devices := []NetworkDevice{{IP: "192.168.1.42", MAC: "aa-bb-cc-dd-ee-02", Hostname: "Unknown"}}
+	raw := "  192.168.1.42          aa-bb-cc-dd-ee-02     dynamic   \r\n"
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < MaxScanDumps+3; i++ {
+		if err := dumpScan(dir, devices, raw, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, ScanDumpPattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != MaxScanDumps {
+		t.Fatalf("Kept %d dumps, want %d", len(files), MaxScanDumps)
+	}
+	oldest := filepath.Join(dir, "scan-2026-03-01-120000.000.txt")
+	if _, err := os.Stat(oldest); !os.IsNotExist(err) {
+		t.Error("Oldest dump should have been deleted")
+	}
+
+	data, err := os.ReadFile(files[len(files)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"aa-bb-cc-dd-ee-02", "Raw ARP table:", "dynamic"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Dump is missing %q", want)
+		}
+	}
+}
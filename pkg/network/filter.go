@@ -0,0 +1,135 @@
+package network
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// FilterDevices drops devices that cannot be the phone from a scan result:
+// multicast and broadcast MACs, the default gateway (gatewayMAC, may be
+// empty) and anything matching the ignore list. Ignore entries of hex digits
+// and dashes are MAC prefixes, such as the lowercase dashed form
+// SanitizeMACPrefix produces; longer text is matched case-insensitively
+// against the vendor and hostname.
+func FilterDevices(devices []NetworkDevice, ignore []string, gatewayMAC string) []NetworkDevice {
+	gatewayMAC = normalizeMAC(gatewayMAC)
+	filtered := make([]NetworkDevice, 0, len(devices))
+	for _, device := range devices {
+		mac := normalizeMAC(device.MAC)
+		if isMulticastMAC(mac) || (gatewayMAC != "" && mac == gatewayMAC) || ignored(device, mac, ignore) {
+			continue
+		}
+		filtered = append(filtered, device)
+	}
+	return filtered
+}
+
+// localAddresses returns the IPs and MACs (normalized) of every network
+// interface of this machine
+func localAddresses() map[string]bool {
+	local := make(map[string]bool)
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return local
+	}
+	for _, iface := range ifaces {
+		if len(iface.HardwareAddr) > 0 {
+			local[normalizeMAC(iface.HardwareAddr.String())] = true
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				local[ipNet.IP.String()] = true
+			}
+		}
+	}
+	return local
+}
+
+// excludeLocalAddresses drops devices whose IP or MAC is in local, as
+// returned by localAddresses
+func excludeLocalAddresses(devices []NetworkDevice, local map[string]bool) []NetworkDevice {
+	filtered := make([]NetworkDevice, 0, len(devices))
+	for _, device := range devices {
+		if local[device.IP] || local[normalizeMAC(device.MAC)] {
+			continue
+		}
+		filtered = append(filtered, device)
+	}
+	return filtered
+}
+
+// normalizeMAC lowercases a MAC address and uses dashes as separators
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(mac), ":", "-"))
+}
+
+// firstOctet parses the first octet of a MAC address
+func firstOctet(mac string) (byte, bool) {
+	if len(mac) < 2 {
+		return 0, false
+	}
+	first, err := strconv.ParseUint(mac[:2], 16, 8)
+	return byte(first), err == nil
+}
+
+// isMulticastMAC reports whether the group bit of the first octet is set,
+// which also covers the broadcast address
+func isMulticastMAC(mac string) bool {
+	first, ok := firstOctet(mac)
+	return ok && first&1 == 1
+}
+
+// IsRandomizedMAC reports whether mac is locally administered, the kind of
+// private address phones make up per network. Such an address changes when
+// the phone forgets the network or rotates it, and MAC detection then loses
+// the phone.
+func IsRandomizedMAC(mac string) bool {
+	first, ok := firstOctet(normalizeMAC(mac))
+	return ok && first&2 == 2 && first&1 == 0
+}
+
+// minIgnoreTextLength is the shortest ignore entry matched against vendor
+// and hostname; shorter ones would hide far too much
+const minIgnoreTextLength = 3
+
+// ignored reports whether device matches an ignore entry. Entries made of
+// hex digits and dashes, and entries shorter than minIgnoreTextLength, are
+// only MAC prefixes, so "de" hides de-ad-... but not "Dell" or "desktop-1";
+// the rest match the vendor or hostname.
+func ignored(device NetworkDevice, mac string, ignore []string) bool {
+	vendor := strings.ToLower(device.Vendor)
+	hostname := strings.ToLower(device.Hostname)
+	macDigits := strings.ReplaceAll(mac, "-", "")
+	for _, entry := range ignore {
+		entry = strings.ToLower(entry)
+		if entry == "" {
+			continue
+		}
+		digits := strings.ReplaceAll(entry, "-", "")
+		if isHex(digits) || len(entry) < minIgnoreTextLength {
+			if digits != "" && isHex(digits) && strings.HasPrefix(macDigits, digits) {
+				return true
+			}
+			continue
+		}
+		if strings.Contains(vendor, entry) || strings.Contains(hostname, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHex reports whether s is made of hex digits only
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterDevices(t *testing.T) {
+	devices := []NetworkDevice{
+		{IP: "192.168.1.1", MAC: "AA:BB:CC:00:00:01", Vendor: "Netgear", Hostname: "router"},
+		{IP: "192.168.1.20", MAC: "b8-27-eb-12-34-56", Vendor: "Raspberry Pi", Hostname: "pi"},
+		{IP: "192.168.1.21", MAC: "00-11-22-33-44-55", Vendor: "Espressif", Hostname: "plug"},
+		{IP: "192.168.1.22", MAC: "01-00-5e-00-00-fb", Vendor: "Unknown"},
+		{IP: "192.168.1.23", MAC: "de-ad-be-ef-00-01", Vendor: "Unknown", Hostname: "Living-Room-TV"},
+		{IP: "192.168.1.30", MAC: "da-a1-19-00-00-01", Vendor: "Unknown", Hostname: "pixel"},
+		{IP: "192.168.1.31", MAC: "f0-18-98-00-00-02", Vendor: "Apple", Hostname: "iphone"},
+		{IP: "192.168.1.32", MAC: "18-db-f2-00-00-03", Vendor: "Dell", Hostname: "desktop-7q2"},
+		{IP: "192.168.1.33", MAC: "00-24-e4-00-00-04", Vendor: "Withings", Hostname: "cafe-scale"},
+		{IP: "192.168.1.34", MAC: "ca-fe-00-00-00-05", Vendor: "Unknown", Hostname: "tablet"},
+	}
+	// "de" and "cafe" are MAC prefixes only; "pi" is too short to match text
+	ignore := []string{"b8-27-eb", "espressif", "living-room", "de", "cafe", "pi"}
+
+	got := FilterDevices(devices, ignore, "aa-bb-cc-00-00-01")
+	want := []NetworkDevice{devices[5], devices[6], devices[7], devices[8]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterDevices() = %v, want %v", got, want)
+	}
+
+	// Without an ignore list or gateway only multicast is dropped
+	if got := FilterDevices(devices, nil, ""); len(got) != len(devices)-1 {
+		t.Errorf("FilterDevices() kept %d devices, want %d", len(got), len(devices)-1)
+	}
+}
+
+func TestIsRandomizedMAC(t *testing.T) {
+	tests := []struct {
+		mac  string
+		want bool
+	}{
+		{"da-a1-19-00-00-01", true},
+		{"9A:BB:CC:DD:EE:FF", true},
+		{"02-00-00-00-00-01", true},
+		{"f0-18-98-00-00-02", false},
+		{"03-00-00-00-00-01", false}, // Multicast, not a device address
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsRandomizedMAC(tt.mac); got != tt.want {
+			t.Errorf("IsRandomizedMAC(%q) = %v, want %v", tt.mac, got, tt.want)
+		}
+	}
+}
+
+func TestExcludeLocalAddresses(t *testing.T) {
+	devices := []NetworkDevice{
+		{IP: "192.168.1.30", MAC: "DA-A1-19-00-00-01"},
+		{IP: "172.20.0.1", MAC: "00-15-5d-00-00-01"}, // Hyper-V switch
+		{IP: "192.168.1.31", MAC: "f0-18-98-00-00-02"},
+	}
+	local := map[string]bool{"192.168.1.5": true, "172.20.0.1": true, "da-a1-19-00-00-01": true}
+
+	got := excludeLocalAddresses(devices, local)
+	want := []NetworkDevice{devices[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("excludeLocalAddresses() = %v, want %v", got, want)
+	}
+
+	// Every interface of this machine is excluded from a list of them
+	var own []NetworkDevice
+	for addr := range localAddresses() {
+		own = append(own, NetworkDevice{IP: addr, MAC: addr})
+	}
+	if got := excludeLocalAddresses(own, localAddresses()); len(got) != 0 {
+		t.Errorf("excludeLocalAddresses() kept own addresses %v", got)
+	}
+}
@@ -0,0 +1,41 @@
+package network
+
+import "runtime"
+
+// GetDefaultGatewayMAC returns the MAC address of the default gateway, read
+// from the routing table and the ARP cache, or "" if it can't be determined
+func GetDefaultGatewayMAC() string {
+	if runtime.GOOS != "windows" {
+		return ""
+	}
+
+	output, err := runCommand("route", "print", "-4", "0.0.0.0")
+	if err != nil {
+		return ""
+	}
+	gateway := parseDefaultGateway(string(output))
+	if gateway == "" {
+		return ""
+	}
+
+	if mac := lookupARPEntry(gateway); mac != "" {
+		return mac
+	}
+	// The gateway may have aged out of the ARP cache; a ping brings it back
+	PingHostWithTimeout(gateway, DefaultPingOptions().TimeoutMs)
+	return lookupARPEntry(gateway)
+}
+
+// lookupARPEntry returns the MAC address the ARP cache holds for ip
+func lookupARPEntry(ip string) string {
+	output, err := runCommand("arp", "-a", ip)
+	if err != nil {
+		return ""
+	}
+	for _, device := range parseARPTable(string(output)) {
+		if device.IP == ip {
+			return device.MAC
+		}
+	}
+	return ""
+}
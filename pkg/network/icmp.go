@@ -0,0 +1,79 @@
+package network
+
+import (
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// icmpReplyWait is how long a fast sweep waits for replies after the last
+// echo request was sent
+const icmpReplyWait = time.Second
+
+// icmpSweep sends an echo request to every target from one raw ICMP socket
+// and collects replies until icmpReplyWait after the last send or the
+// deadline (zero means no limit). It reports whether every target was sent a
+// request and how many answered. Raw sockets need administrator rights; the
+// error says so and the caller falls back to pingSweep.
+func icmpSweep(ips []string, deadline time.Time) (complete bool, answered int, err error) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return false, 0, err
+	}
+	defer conn.Close()
+
+	id := os.Getpid() & 0xffff
+	complete = true
+	for seq, ip := range ips {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			complete = false
+			break
+		}
+		msg := icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Body: &icmp.Echo{ID: id, Seq: seq & 0xffff, Data: []byte("home-sentry")},
+		}
+		packet, err := msg.Marshal(nil)
+		if err != nil {
+			return false, 0, err
+		}
+		// A failed send (e.g. no route) just leaves that host unanswered
+		conn.WriteTo(packet, &net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	wait := time.Now().Add(icmpReplyWait)
+	if !deadline.IsZero() && deadline.Before(wait) {
+		wait = deadline
+	}
+	conn.SetReadDeadline(wait)
+
+	seen := make(map[string]bool)
+	buf := make([]byte, 1500)
+	for len(seen) < len(ips) {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			break // Wait is over
+		}
+		if isEchoReply(buf[:n], id) {
+			seen[peer.String()] = true
+		}
+	}
+	return complete, len(seen), nil
+}
+
+// isEchoReply reports whether packet is a reply to one of our echo requests.
+// Windows delivers raw ICMP packets with their IPv4 header, which is skipped.
+func isEchoReply(packet []byte, id int) bool {
+	if len(packet) >= 20 && packet[0]>>4 == 4 {
+		packet = packet[int(packet[0]&0x0f)*4:]
+	}
+	msg, err := icmp.ParseMessage(1, packet) // 1 is ICMP's IP protocol number
+	if err != nil || msg.Type != ipv4.ICMPTypeEchoReply {
+		return false
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	return ok && echo.ID == id
+}
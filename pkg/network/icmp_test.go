@@ -0,0 +1,40 @@
+package network
+
+import (
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestIsEchoReply(t *testing.T) {
+	marshal := func(typ ipv4.ICMPType, id int) []byte {
+		msg := icmp.Message{Type: typ, Body: &icmp.Echo{ID: id, Seq: 1, Data: []byte("home-sentry")}}
+		packet, err := msg.Marshal(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return packet
+	}
+
+	reply := marshal(ipv4.ICMPTypeEchoReply, 42)
+	if !isEchoReply(reply, 42) {
+		t.Error("isEchoReply() rejected our reply")
+	}
+	if isEchoReply(reply, 43) {
+		t.Error("isEchoReply() accepted another process's reply")
+	}
+	if isEchoReply(marshal(ipv4.ICMPTypeEcho, 42), 42) {
+		t.Error("isEchoReply() accepted a request")
+	}
+
+	// As read from a raw socket on Windows, with a 20-byte IPv4 header
+	header := make([]byte, 20)
+	header[0] = 0x45
+	if !isEchoReply(append(header, reply...), 42) {
+		t.Error("isEchoReply() rejected a reply with its IPv4 header")
+	}
+	if isEchoReply(header[:4], 42) {
+		t.Error("isEchoReply() accepted a truncated packet")
+	}
+}
@@ -0,0 +1,174 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// mdnsAddr is the IPv4 multicast group and port mDNS responders listen on
+const mdnsAddr = "224.0.0.251:5353"
+
+// mdnsMinTimeout is the shortest wait for an answer; responders may delay
+// theirs by up to 120ms, sleeping phones longer
+const mdnsMinTimeout = time.Second
+
+const (
+	dnsTypeA    = 1
+	dnsClassIN  = 1
+	dnsFlagQR   = 0x8000 // Message is a response
+	maxDNSJumps = 16     // Compression pointers followed per name
+)
+
+var errBadDNSMessage = errors.New("malformed DNS message")
+
+// IsMDNSNamePresent reports whether a device answers mDNS queries for name
+// (e.g. "my-iphone.local"), asking up to probeAttempts times. Unlike a MAC
+// address, the name stays the same when the phone rotates its private MAC.
+func IsMDNSNamePresent(name string, opts PingOptions) bool {
+	timeout := time.Duration(opts.TimeoutMs) * time.Millisecond
+	if timeout < mdnsMinTimeout {
+		timeout = mdnsMinTimeout
+	}
+	for attempt := 0; attempt < probeAttempts; attempt++ {
+		if ResolveMDNS(name, timeout) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveMDNS sends a one-shot mDNS query for name's IPv4 address and returns
+// the first address answered within timeout, or "" if none was. The query is
+// sent from an ephemeral port, so responders answer it directly instead of
+// to the multicast group, and no OS resolver cache is involved.
+func ResolveMDNS(name string, timeout time.Duration) string {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	group, err := net.ResolveUDPAddr("udp4", mdnsAddr)
+	if err != nil {
+		return ""
+	}
+	query, err := buildMDNSQuery(uint16(rand.Intn(1<<16)), name)
+	if err != nil {
+		return ""
+	}
+	if _, err := conn.WriteToUDP(query, group); err != nil {
+		return ""
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 9000) // mDNS messages fit in a jumbo frame
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return "" // Timed out
+		}
+		if ip := parseMDNSAnswer(buf[:n], name); ip != nil {
+			return ip.String()
+		}
+	}
+}
+
+// buildMDNSQuery encodes a DNS query for name's A record
+func buildMDNSQuery(id uint16, name string) ([]byte, error) {
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[4:], 1) // One question
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil, errBadDNSMessage
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, dnsTypeA)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	return msg, nil
+}
+
+// parseMDNSAnswer returns the IPv4 address a response gives for name, or nil
+// if msg is not a response or has no A record for it
+func parseMDNSAnswer(msg []byte, name string) net.IP {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[2:])&dnsFlagQR == 0 {
+		return nil
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	want := strings.TrimSuffix(name, ".")
+
+	off := 12
+	for i := 0; i < questions; i++ {
+		_, next, err := readDNSName(msg, off)
+		if err != nil || next+4 > len(msg) {
+			return nil
+		}
+		off = next + 4 // Type and class
+	}
+
+	for i := 0; i < records; i++ {
+		owner, next, err := readDNSName(msg, off)
+		if err != nil || next+10 > len(msg) {
+			return nil
+		}
+		rrType := binary.BigEndian.Uint16(msg[next:])
+		// The top bit of the class is mDNS's cache-flush flag
+		rrClass := binary.BigEndian.Uint16(msg[next+2:]) &^ 0x8000
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		data := next + 10
+		if data+length > len(msg) {
+			return nil
+		}
+		if rrType == dnsTypeA && rrClass == dnsClassIN && length == 4 && strings.EqualFold(owner, want) {
+			return net.IPv4(msg[data], msg[data+1], msg[data+2], msg[data+3])
+		}
+		off = data + length
+	}
+	return nil
+}
+
+// readDNSName decodes the possibly compressed name at off and returns it
+// without the trailing dot, along with the offset just past it
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1 // Offset after the name, set at the first compression pointer
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errBadDNSMessage
+		}
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case length&0xC0 == 0xC0:
+			if off+1 >= len(msg) || jumps >= maxDNSJumps {
+				return "", 0, errBadDNSMessage
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			jumps++
+		case length > 63:
+			return "", 0, errBadDNSMessage
+		default:
+			if off+1+length > len(msg) {
+				return "", 0, errBadDNSMessage
+			}
+			labels = append(labels, string(msg[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// mdnsResponse builds a response to query that first answers with an
+// unrelated AAAA record, then with an A record for the queried name using a
+// compression pointer to the question
+func mdnsResponse(t *testing.T, query []byte, ip [4]byte) []byte {
+	t.Helper()
+	msg := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(msg[2:], 0x8400) // Authoritative response
+	binary.BigEndian.PutUint16(msg[6:], 2)      // Two answers
+
+	// AAAA record for the same name
+	msg = append(msg, 0xC0, 12)
+	msg = binary.BigEndian.AppendUint16(msg, 28)
+	msg = binary.BigEndian.AppendUint16(msg, 0x8001)
+	msg = binary.BigEndian.AppendUint32(msg, 120)
+	msg = binary.BigEndian.AppendUint16(msg, 16)
+	msg = append(msg, make([]byte, 16)...)
+
+	// A record, with the cache-flush bit set
+	msg = append(msg, 0xC0, 12)
+	msg = binary.BigEndian.AppendUint16(msg, dnsTypeA)
+	msg = binary.BigEndian.AppendUint16(msg, 0x8001)
+	msg = binary.BigEndian.AppendUint32(msg, 120)
+	msg = binary.BigEndian.AppendUint16(msg, 4)
+	return append(msg, ip[:]...)
+}
+
+func TestParseMDNSAnswer(t *testing.T) {
+	query, err := buildMDNSQuery(7, "My-iPhone.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := mdnsResponse(t, query, [4]byte{192, 168, 1, 42})
+
+	if ip := parseMDNSAnswer(response, "my-iphone.local"); ip == nil || ip.String() != "192.168.1.42" {
+		t.Errorf("parseMDNSAnswer() = %v, want 192.168.1.42", ip)
+	}
+	if ip := parseMDNSAnswer(response, "other.local"); ip != nil {
+		t.Errorf("parseMDNSAnswer() for another name = %v, want nil", ip)
+	}
+	if ip := parseMDNSAnswer(query, "my-iphone.local"); ip != nil {
+		t.Errorf("parseMDNSAnswer() of a query = %v, want nil", ip)
+	}
+	for n := range response {
+		parseMDNSAnswer(response[:n], "my-iphone.local") // Truncated input must not panic
+	}
+
+	// A pointer loop is rejected instead of followed forever
+	loop := append([]byte(nil), response[:12]...)
+	binary.BigEndian.PutUint16(loop[4:], 1)
+	loop = append(loop, 0xC0, 12)
+	if ip := parseMDNSAnswer(loop, "my-iphone.local"); ip != nil {
+		t.Errorf("parseMDNSAnswer() of a pointer loop = %v, want nil", ip)
+	}
+
+	if _, err := buildMDNSQuery(1, "bad..local"); err == nil {
+		t.Error("buildMDNSQuery() accepted an empty label")
+	}
+}
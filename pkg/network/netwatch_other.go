@@ -0,0 +1,9 @@
+//go:build !windows
+
+package network
+
+// WatchNetworkChanges is only implemented on Windows; elsewhere the monitor
+// relies on polling alone
+func WatchNetworkChanges() <-chan struct{} {
+	return nil
+}
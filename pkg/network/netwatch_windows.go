@@ -0,0 +1,47 @@
+//go:build windows
+
+package network
+
+import (
+	"home-sentry/pkg/logger"
+	"syscall"
+	"time"
+)
+
+var (
+	iphlpapi             = syscall.NewLazyDLL("iphlpapi.dll")
+	procNotifyAddrChange = iphlpapi.NewProc("NotifyAddrChange")
+)
+
+// netwatchRetryDelay keeps a failing NotifyAddrChange from spinning
+const netwatchRetryDelay = 30 * time.Second
+
+// WatchNetworkChanges returns a channel that receives a value whenever an
+// IPv4 address is added or removed, which is what joining or leaving a WiFi
+// network looks like. Bursts of changes collapse into a single pending value.
+// The channel is nil if change notifications are unavailable.
+func WatchNetworkChanges() <-chan struct{} {
+	if err := iphlpapi.Load(); err != nil {
+		logger.Warn("Network change notifications unavailable: %v", err)
+		return nil
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		for {
+			// Without a handle or overlapped structure the call blocks until
+			// the next address change
+			ret, _, _ := procNotifyAddrChange.Call(0, 0)
+			if ret != 0 {
+				logger.Debug("NotifyAddrChange failed: %v", syscall.Errno(ret))
+				time.Sleep(netwatchRetryDelay)
+				continue
+			}
+			select {
+			case changes <- struct{}{}:
+			default: // A check is already pending
+			}
+		}
+	}()
+	return changes
+}
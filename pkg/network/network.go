@@ -1,15 +1,17 @@
 package network
 
 import (
+	"errors"
 	"fmt"
 	"home-sentry/pkg/config"
+	"home-sentry/pkg/logger"
 	"net"
 	"os/exec"
-	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type NetworkDevice struct {
@@ -19,6 +21,10 @@ type NetworkDevice struct {
 	Vendor   string `json:"vendor"`
 }
 
+// UnknownSSID is reported when no WiFi connection is available
+// (adapter disabled, missing or disconnected)
+const UnknownSSID = "Unknown"
+
 func GetCurrentSSID() string {
 	if runtime.GOOS == "windows" {
 		ssid, err := RetryWithResult(DefaultRetryConfig(), func() (string, error) {
@@ -29,7 +35,7 @@ func GetCurrentSSID() string {
 			return ssid, nil
 		})
 		if err != nil {
-			return "Unknown"
+			return UnknownSSID
 		}
 		return ssid
 	}
@@ -38,59 +44,112 @@ func GetCurrentSSID() string {
 
 func ScanWifiNetworks() []string {
 	if runtime.GOOS == "windows" {
-		cmd := exec.Command("netsh", "wlan", "show", "networks")
-		HideConsole(cmd)
-		output, err := cmd.Output()
+		output, err := runCommand("netsh", "wlan", "show", "networks")
 		if err != nil {
 			return []string{}
 		}
-
-		var ssids []string
-		re := regexp.MustCompile(`SSID \d+ : (.+)`)
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			matches := re.FindStringSubmatch(line)
-			if len(matches) > 1 {
-				ssid := strings.TrimSpace(matches[1])
-				if ssid != "" {
-					ssids = append(ssids, ssid)
-				}
-			}
-		}
-		return ssids
+		return parseWifiList(string(output))
 	}
 	return []string{"Simulated Network 1", "Simulated Network 2"}
 }
 
+// getWindowsSSID asks the WLAN API for the connected SSID and only falls back to
+// parsing `netsh` output (English/known labels) if the API is unavailable.
 func getWindowsSSID() string {
-	cmd := exec.Command("netsh", "wlan", "show", "interfaces")
-	HideConsole(cmd)
-	output, err := cmd.Output()
+	ssid, err := queryWLANSSID()
+	if err == nil {
+		return ssid
+	}
+	if errors.Is(err, errWLANNotConnected) {
+		return "Disconnected"
+	}
+	logger.Debug("WLAN API query failed, falling back to netsh: %v", err)
+
+	output, err := runCommand("netsh", "wlan", "show", "interfaces")
 	if err != nil {
 		return "Unknown"
 	}
 
-	re := regexp.MustCompile(`\s+SSID\s+:\s+(.+)`)
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		matches := re.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			return strings.TrimSpace(matches[1])
-		}
+	if ssid := parseSSID(string(output)); ssid != "" {
+		return ssid
 	}
 	return "Disconnected"
 }
 
-func ScanNetworkDevices() []NetworkDevice {
+// ScanOptions controls how a network scan is performed
+type ScanOptions struct {
+	// ResolveHostnames enables reverse-DNS lookups for each discovered device
+	ResolveHostnames bool
+	// Timeout bounds the whole sweep and ARP read; zero means no limit
+	Timeout time.Duration
+	// CIDRs are the ranges to sweep; empty means the local /24
+	CIDRs []string
+	// FastScan sweeps with native ICMP echoes from one raw socket instead of
+	// a ping process per host, falling back to ping without admin rights
+	FastScan bool
+	// ExcludeSelf drops this machine's own adapters (including VPN, Hyper-V
+	// and WSL ones) from the results
+	ExcludeSelf bool
+	// DumpDir receives a copy of every scan result and raw ARP table for
+	// diagnosing detection problems; empty disables dumps
+	DumpDir string
+}
+
+// DefaultScanOptions returns sensible defaults
+func DefaultScanOptions() ScanOptions {
+	return ScanOptions{
+		ResolveHostnames: config.DefaultResolveHostnames,
+		Timeout:          config.DefaultScanTimeoutSec * time.Second,
+		ExcludeSelf:      config.DefaultScanExcludeSelf,
+	}
+}
+
+// ScanOptionsFromSettings builds scan options from the user's settings
+func ScanOptionsFromSettings(settings config.Settings) ScanOptions {
+	opts := DefaultScanOptions()
+	opts.ResolveHostnames = settings.ResolveHostnames
+	opts.CIDRs = settings.ScanCIDRs
+	opts.FastScan = settings.FastScan
+	opts.ExcludeSelf = settings.ScanExcludeSelf
+	if settings.ScanTimeoutSec > 0 {
+		opts.Timeout = time.Duration(settings.ScanTimeoutSec) * time.Second
+	}
+	if settings.DebugDumpScans {
+		opts.DumpDir = logger.GetLogDir()
+	}
+	return opts
+}
+
+// pingSweepWorkers bounds how many pings run at once during a sweep
+const pingSweepWorkers = 32
+
+// ScanNetworkDevices sweeps the local subnet (or opts.CIDRs) and returns the
+// devices found in the ARP table. If opts.Timeout elapses first, whatever was
+// found so far is returned.
+func ScanNetworkDevices(opts ScanOptions) []NetworkDevice {
 	if runtime.GOOS == "windows" {
-		// 1. Determine local subnet
-		ip, _, err := getLocalIP()
-		if err == nil {
-			// 2. Ping sweep to populate ARP table
-			pingSweep(ip)
+		var deadline time.Time
+		if opts.Timeout > 0 {
+			deadline = time.Now().Add(opts.Timeout)
+		}
+
+		// 1. Determine what to sweep
+		ip, _, _ := getLocalIP()
+		// 2. Ping sweep to populate ARP table
+		if !sweep(sweepTargets(ip, opts.CIDRs), deadline, opts.FastScan) {
+			logger.Warn("Network scan truncated: ping sweep did not finish within %v", opts.Timeout)
 		}
 		// 3. Read ARP table
-		return scanARPWindows()
+		devices, rawARP := scanARPWindows(opts.ResolveHostnames, deadline)
+		if opts.DumpDir != "" {
+			if err := dumpScan(opts.DumpDir, devices, rawARP, time.Now()); err != nil {
+				logger.Warn("Failed to dump scan results: %v", err)
+			}
+		}
+		if opts.ExcludeSelf {
+			devices = excludeLocalAddresses(devices, localAddresses())
+		}
+		return devices
 	}
 	return []NetworkDevice{
 		{IP: "192.168.1.100", Hostname: "Simulated-iPhone", MAC: "00:11:22:33:44:55"},
@@ -110,97 +169,146 @@ func getLocalIP() (string, string, error) {
 	return localAddr.IP.String(), "255.255.255.0", nil
 }
 
-func pingSweep(myIP string) {
-	// Simple assumption: /24 network
-	parts := strings.Split(myIP, ".")
-	if len(parts) != 4 {
-		return
+// sweep pings every target, natively when fast is set and raw sockets are
+// permitted, and reports whether every target was covered
+func sweep(ips []string, deadline time.Time, fast bool) bool {
+	if fast {
+		complete, answered, err := icmpSweep(ips, deadline)
+		if err == nil {
+			logger.Info("Fast scan: %d of %d hosts answered", answered, len(ips))
+			return complete
+		}
+		logger.Warn("Fast scan unavailable, falling back to ping: %v", err)
 	}
-	baseIP := fmt.Sprintf("%s.%s.%s.", parts[0], parts[1], parts[2])
+	return pingSweep(ips, deadline)
+}
 
+// pingSweep pings every target using a bounded worker pool. It stops handing
+// out new targets once the deadline passes (a zero deadline means no limit)
+// and reports whether every target was covered.
+func pingSweep(ips []string, deadline time.Time) bool {
+	targets := make(chan string)
 	var wg sync.WaitGroup
-	// Ping 1-254
-	for i := 1; i < 255; i++ {
-		targetIP := baseIP + strconv.Itoa(i)
-		// Don't ping self
-		if targetIP == myIP {
-			continue
-		}
-
+	for w := 0; w < pingSweepWorkers; w++ {
 		wg.Add(1)
-		go func(ip string) {
+		go func() {
 			defer wg.Done()
-			// Fast timeout ping
-			PingHost(ip)
-		}(targetIP)
+			for ip := range targets {
+				// Fast timeout ping
+				PingHost(ip)
+			}
+		}()
+	}
+
+	complete := true
+	for _, ip := range ips {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			complete = false
+			break
+		}
+		targets <- ip
 	}
+	close(targets)
 	wg.Wait()
+	return complete
 }
 
-func scanARPWindows() []NetworkDevice {
-	cmd := exec.Command("arp", "-a")
-	HideConsole(cmd)
-	output, err := cmd.Output()
+// scanARPWindows reads the ARP table and returns its devices along with the
+// raw `arp -a` output
+func scanARPWindows(resolveHostnames bool, deadline time.Time) ([]NetworkDevice, string) {
+	output, err := runCommand("arp", "-a")
 	if err != nil {
-		return []NetworkDevice{}
+		logger.Warn("Network scan found nothing: %v", err)
+		return []NetworkDevice{}, ""
 	}
 
-	var devices []NetworkDevice
-	lines := strings.Split(string(output), "\n")
-	re := regexp.MustCompile(`(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\s+([0-9a-fA-F-]{17})`)
+	devices := parseARPTable(string(output))
+
+	if !resolveHostnames || len(devices) == 0 {
+		return devices, string(output)
+	}
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-
-	for _, line := range lines {
-		matches := re.FindStringSubmatch(line)
-		if len(matches) > 2 {
-			ip := matches[1]
-			mac := matches[2]
-			if strings.HasPrefix(ip, "224.") || strings.HasPrefix(ip, "239.") || mac == "ff-ff-ff-ff-ff-ff" {
-				continue
+	for i := range devices {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			names, lookupErr := net.LookupAddr(ip)
+			if lookupErr != nil || len(names) == 0 {
+				return
 			}
+			raw := strings.TrimSuffix(names[0], ".")
+			// Sanitize hostname from DNS to prevent injection
+			sanitizedHost, err := config.SanitizeHostname(raw)
+			if err == nil && sanitizedHost != "" {
+				mu.Lock()
+				devices[i].Hostname = sanitizedHost
+				mu.Unlock()
+			}
+		}(i, devices[i].IP)
+	}
 
-			wg.Add(1)
-			go func(ip, mac string) {
-				defer wg.Done()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-				// Validate IP from ARP table
-				sanitizedIP, err := config.SanitizeIP(ip)
-				if err != nil || sanitizedIP == "" {
-					return // Skip invalid IPs from ARP
-				}
+	if deadline.IsZero() {
+		<-done
+		return devices, string(output)
+	}
 
-				// Validate MAC from ARP table
-				sanitizedMAC, err := config.SanitizeMAC(mac)
-				if err != nil || sanitizedMAC == "" {
-					return // Skip invalid MACs from ARP
-				}
+	select {
+	case <-done:
+		return devices, string(output)
+	case <-time.After(time.Until(deadline)):
+		logger.Warn("Network scan truncated: hostname lookups did not finish in time, returning %d devices", len(devices))
+		// Lookups still running keep writing to the original slice, so hand out a copy
+		mu.Lock()
+		partial := make([]NetworkDevice, len(devices))
+		copy(partial, devices)
+		mu.Unlock()
+		return partial, string(output)
+	}
+}
 
-				hostname := "Unknown"
-				names, lookupErr := net.LookupAddr(sanitizedIP)
-				if lookupErr == nil && len(names) > 0 {
-					raw := strings.TrimSuffix(names[0], ".")
-					// Sanitize hostname from DNS to prevent injection
-					sanitizedHost, err := config.SanitizeHostname(raw)
-					if err == nil && sanitizedHost != "" {
-						hostname = sanitizedHost
-					}
-				}
+// PingOptions controls how presence pings are sent
+type PingOptions struct {
+	TimeoutMs int
+	// Count is the number of echo requests; the host counts as up if any reply arrives
+	Count int
+	// PacketSize is the payload size in bytes (0 uses the OS default)
+	PacketSize int
+	// SweepCIDRs limits the sweep used to find a device whose IP is unknown;
+	// empty means the local /24
+	SweepCIDRs []string
+	// ARPRefresh selects how a stale ARP entry is ruled out before trusting it
+	ARPRefresh config.ARPRefresh
+	// ProbePorts are TCP ports tried when no echo reply arrives, for devices
+	// whose firewall drops ping
+	ProbePorts []int
+}
 
-				mu.Lock()
-				devices = append(devices, NetworkDevice{
-					IP:       sanitizedIP,
-					Hostname: hostname,
-					MAC:      sanitizedMAC,
-					Vendor:   GetVendor(sanitizedMAC),
-				})
-				mu.Unlock()
-			}(ip, mac)
-		}
+// DefaultPingOptions returns sensible defaults
+func DefaultPingOptions() PingOptions {
+	return PingOptions{
+		TimeoutMs: config.DefaultPingTimeoutMs,
+		Count:     config.DefaultPingCount,
+	}
+}
+
+// PingOptionsFromSettings builds ping options from the user's settings
+func PingOptionsFromSettings(settings config.Settings) PingOptions {
+	return PingOptions{
+		TimeoutMs:  settings.PingTimeoutMs,
+		Count:      settings.PingCount,
+		PacketSize: settings.PingPacketSize,
+		SweepCIDRs: settings.ScanCIDRs,
+		ARPRefresh: settings.ARPRefresh,
+		ProbePorts: settings.ProbePorts,
 	}
-	wg.Wait()
-	return devices
 }
 
 func PingHost(ip string) bool {
@@ -208,51 +316,55 @@ func PingHost(ip string) bool {
 }
 
 func PingHostWithTimeout(ip string, timeoutMs int) bool {
+	return PingHostWithOptions(ip, PingOptions{TimeoutMs: timeoutMs, Count: 1})
+}
+
+// PingHostWithOptions sends up to opts.Count echo requests and reports the host
+// as up as soon as any of them is answered. Power-saving phones often miss a
+// single probe, so multiple probes reduce false negatives. If none is answered
+// and opts.ProbePorts is set, a TCP connection to one of them that is accepted
+// or refused also counts.
+func PingHostWithOptions(ip string, opts PingOptions) bool {
 	if runtime.GOOS == "windows" {
 		// Validate IP address to prevent command injection
 		if net.ParseIP(ip) == nil {
 			return false
 		}
-		cmd := exec.Command("ping", "-n", "1", "-w", strconv.Itoa(timeoutMs), ip)
-		HideConsole(cmd)
-		err := cmd.Run()
-		return err == nil
+
+		count := opts.Count
+		if count < 1 {
+			count = 1
+		}
+
+		args := []string{"-n", "1", "-w", strconv.Itoa(opts.TimeoutMs)}
+		if opts.PacketSize > 0 {
+			args = append(args, "-l", strconv.Itoa(opts.PacketSize))
+		}
+		args = append(args, ip)
+
+		for i := 0; i < count; i++ {
+			cmd := exec.Command("ping", args...)
+			HideConsole(cmd)
+			if err := cmd.Run(); err == nil {
+				return true
+			}
+		}
+		return len(opts.ProbePorts) > 0 && tcpProbe(ip, opts.ProbePorts, time.Duration(opts.TimeoutMs)*time.Millisecond)
 	}
 	return true
 }
 
-// IsDeviceOnNetwork checks if a device with the given MAC address is on the network
-// by actively verifying its presence (not trusting stale ARP cache).
-func IsDeviceOnNetwork(mac string) bool {
+// IsDeviceInARPTable passively checks whether the MAC address is present in the
+// existing ARP/neighbor table, without deleting entries or sending pings.
+// Entries age out of the OS neighbor cache on their own, so presence reflects
+// recent traffic - but it can lag behind a device that has just left.
+func IsDeviceInARPTable(mac string) bool {
 	if runtime.GOOS != "windows" {
 		return true // Simulated on non-Windows
 	}
 
-	// Normalize MAC to lowercase with dashes
 	mac = strings.ToLower(mac)
 	mac = strings.ReplaceAll(mac, ":", "-")
-
-	// First find the IP associated with this MAC (if any)
-	lastKnownIP := FindIPByMAC(mac)
-
-	// Delete stale ARP entry to force fresh lookup
-	if lastKnownIP != "" {
-		deleteARPEntry(lastKnownIP)
-	}
-
-	// If we had an IP, ping it directly to refresh ARP
-	if lastKnownIP != "" {
-		// Ping the specific IP with short timeout
-		PingHostWithTimeout(lastKnownIP, 500)
-	} else {
-		// No cached IP - do a quick ping sweep to find the device
-		ip, _, err := getLocalIP()
-		if err == nil {
-			pingSweep(ip)
-		}
-	}
-
-	// Now check if MAC appeared in fresh ARP table
 	return checkARPForMAC(mac)
 }
 
@@ -262,16 +374,12 @@ func deleteARPEntry(ip string) {
 	if net.ParseIP(ip) == nil {
 		return
 	}
-	cmd := exec.Command("arp", "-d", ip)
-	HideConsole(cmd)
-	cmd.Run() // Ignore errors - may fail if not admin, that's OK
+	runCommand("arp", "-d", ip) // Ignore errors - may fail if not admin, that's OK
 }
 
 // checkARPForMAC checks if the MAC address exists in the current ARP table
 func checkARPForMAC(mac string) bool {
-	cmd := exec.Command("arp", "-a")
-	HideConsole(cmd)
-	output, err := cmd.Output()
+	output, err := runCommand("arp", "-a")
 	if err != nil {
 		return false
 	}
@@ -290,18 +398,14 @@ func FindIPByMAC(mac string) string {
 	mac = strings.ToLower(mac)
 	mac = strings.ReplaceAll(mac, ":", "-")
 
-	cmd := exec.Command("arp", "-a")
-	HideConsole(cmd)
-	output, err := cmd.Output()
+	output, err := runCommand("arp", "-a")
 	if err != nil {
 		return ""
 	}
 
 	lines := strings.Split(string(output), "\n")
-	re := regexp.MustCompile(`(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\s+([0-9a-fA-F-]{17})`)
-
 	for _, line := range lines {
-		matches := re.FindStringSubmatch(line)
+		matches := arpEntryRe.FindStringSubmatch(line)
 		if len(matches) > 2 {
 			foundMAC := strings.ToLower(matches[2])
 			if foundMAC == mac {
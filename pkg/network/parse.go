@@ -0,0 +1,106 @@
+package network
+
+import (
+	"home-sentry/pkg/config"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Anchored so that "BSSID" and "AP BSSID" lines never match
+	ssidLineRe = regexp.MustCompile(`^\s*SSID\s*:\s*(.*?)\s*$`)
+	wifiListRe = regexp.MustCompile(`^\s*SSID \d+\s*:\s*(.*?)\s*$`)
+	arpEntryRe = regexp.MustCompile(`(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\s+([0-9a-fA-F-]{17})`)
+	// Active default route: destination, netmask, gateway, interface, metric
+	defaultRouteRe = regexp.MustCompile(`^\s*0\.0\.0\.0\s+0\.0\.0\.0\s+(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\s+\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\s+(\d+)\s*$`)
+)
+
+// parseSSID extracts the connected SSID from `netsh wlan show interfaces` output.
+// It returns an empty string when no SSID line is present.
+func parseSSID(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		matches := ssidLineRe.FindStringSubmatch(line)
+		if len(matches) > 1 && matches[1] != "" {
+			return matches[1]
+		}
+	}
+	return ""
+}
+
+// parseWifiList extracts visible SSIDs from `netsh wlan show networks` output
+func parseWifiList(output string) []string {
+	var ssids []string
+	for _, line := range strings.Split(output, "\n") {
+		matches := wifiListRe.FindStringSubmatch(line)
+		if len(matches) > 1 && matches[1] != "" {
+			ssids = append(ssids, matches[1])
+		}
+	}
+	return ssids
+}
+
+// parseARPTable extracts devices from `arp -a` output. Multicast and broadcast
+// entries are skipped and every IP/MAC is sanitized before it is returned.
+// Hostnames are left as "Unknown" for the caller to resolve.
+func parseARPTable(output string) []NetworkDevice {
+	var devices []NetworkDevice
+	for _, line := range strings.Split(output, "\n") {
+		matches := arpEntryRe.FindStringSubmatch(line)
+		if len(matches) < 3 {
+			continue
+		}
+		ip := matches[1]
+		mac := matches[2]
+		if strings.HasPrefix(ip, "224.") || strings.HasPrefix(ip, "239.") || strings.EqualFold(mac, "ff-ff-ff-ff-ff-ff") {
+			continue
+		}
+
+		// Validate IP from ARP table
+		sanitizedIP, err := config.SanitizeIP(ip)
+		if err != nil || sanitizedIP == "" {
+			continue // Skip invalid IPs from ARP
+		}
+
+		// Validate MAC from ARP table
+		sanitizedMAC, err := config.SanitizeMAC(mac)
+		if err != nil || sanitizedMAC == "" {
+			continue // Skip invalid MACs from ARP
+		}
+
+		devices = append(devices, NetworkDevice{
+			IP:       sanitizedIP,
+			Hostname: "Unknown",
+			MAC:      sanitizedMAC,
+			Vendor:   GetVendor(sanitizedMAC),
+		})
+	}
+	return devices
+}
+
+// parseDefaultGateway returns the gateway of the active default route with
+// the lowest metric from `route print -4 0.0.0.0` output, or "" if there is
+// none. Persistent routes and on-link entries don't match the column layout.
+func parseDefaultGateway(output string) string {
+	gateway := ""
+	bestMetric := -1
+	for _, line := range strings.Split(output, "\n") {
+		matches := defaultRouteRe.FindStringSubmatch(line)
+		if len(matches) < 3 {
+			continue
+		}
+		metric, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+		sanitized, err := config.SanitizeIP(matches[1])
+		if err != nil || sanitized == "" {
+			continue
+		}
+		if bestMetric < 0 || metric < bestMetric {
+			gateway = sanitized
+			bestMetric = metric
+		}
+	}
+	return gateway
+}
@@ -0,0 +1,165 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+const interfacesEnglish = "\r\nThere is 1 interface on the system:\r\n\r\n" +
+	"    Name                   : Wi-Fi\r\n" +
+	"    Description            : Intel(R) Wi-Fi 6 AX201 160MHz\r\n" +
+	"    GUID                   : 2b1f5c3e-8d4a-4c6b-9f1e-7a3d2c1b0e9f\r\n" +
+	"    Physical address       : 00:11:22:33:44:55\r\n" +
+	"    State                  : connected\r\n" +
+	"    SSID                   : HomeNet 5G\r\n" +
+	"    BSSID                  : aa:bb:cc:dd:ee:ff\r\n" +
+	"    Network type           : Infrastructure\r\n" +
+	"    Radio type             : 802.11ax\r\n"
+
+// Newer Windows builds print "AP BSSID" and may list it before the SSID
+const interfacesBSSIDFirst = "    Name                   : Wi-Fi\r\n" +
+	"    AP BSSID               : aa:bb:cc:dd:ee:ff\r\n" +
+	"    BSSID                  : aa:bb:cc:dd:ee:ff\r\n" +
+	"    SSID                   : HomeNet\r\n"
+
+const interfacesGerman = "\r\nEs gibt 1 Schnittstelle auf dem System:\r\n\r\n" +
+	"    Name                   : WLAN\r\n" +
+	"    Beschreibung           : Intel(R) Wi-Fi 6 AX201 160MHz\r\n" +
+	"    Physische Adresse      : 00:11:22:33:44:55\r\n" +
+	"    Status                 : Verbunden\r\n" +
+	"    SSID                   : Zuhause\r\n" +
+	"    BSSID                  : aa:bb:cc:dd:ee:ff\r\n" +
+	"    Netzwerktyp            : Infrastruktur\r\n"
+
+const interfacesDisconnected = "    Name                   : Wi-Fi\r\n" +
+	"    State                  : disconnected\r\n" +
+	"    Radio status           : Hardware On\r\n"
+
+func TestParseSSID(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"english", interfacesEnglish, "HomeNet 5G"},
+		{"BSSID listed first", interfacesBSSIDFirst, "HomeNet"},
+		{"german", interfacesGerman, "Zuhause"},
+		{"disconnected", interfacesDisconnected, ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSSID(tt.output); got != tt.want {
+				t.Errorf("parseSSID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWifiList(t *testing.T) {
+	english := "\r\nInterface name : Wi-Fi \r\nThere are 2 networks currently visible.\r\n\r\n" +
+		"SSID 1 : HomeNet\r\n" +
+		"    Network type            : Infrastructure\r\n" +
+		"    Authentication          : WPA2-Personal\r\n\r\n" +
+		"SSID 2 : \r\n" +
+		"    Network type            : Infrastructure\r\n\r\n" +
+		"SSID 3 : Coffee Shop\r\n" +
+		"    Network type            : Infrastructure\r\n"
+	german := "\r\nSchnittstellenname : WLAN \r\nZurzeit sind 1 Netzwerke sichtbar.\r\n\r\n" +
+		"SSID 1 : Zuhause\r\n" +
+		"    Netzwerktyp             : Infrastruktur\r\n" +
+		"    Authentifizierung       : WPA2-Personal\r\n"
+
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{"english skips hidden networks", english, []string{"HomeNet", "Coffee Shop"}},
+		{"german", german, []string{"Zuhause"}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseWifiList(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWifiList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseARPTable(t *testing.T) {
+	english := "\r\nInterface: 192.168.1.20 --- 0x5\r\n" +
+		"  Internet Address      Physical Address      Type\r\n" +
+		"  192.168.1.1           aa-bb-cc-dd-ee-01     dynamic   \r\n" +
+		"  192.168.1.42          AA-BB-CC-DD-EE-02     dynamic   \r\n" +
+		"  192.168.1.255         ff-ff-ff-ff-ff-ff     static    \r\n" +
+		"  224.0.0.22            01-00-5e-00-00-16     static    \r\n" +
+		"  239.255.255.250       01-00-5e-7f-ff-fa     static    \r\n"
+	german := "\r\nSchnittstelle: 192.168.1.20 --- 0x5\r\n" +
+		"  Internetadresse       Physische Adresse     Typ\r\n" +
+		"  192.168.1.1           aa-bb-cc-dd-ee-01     dynamisch \r\n" +
+		"  192.168.1.255         ff-ff-ff-ff-ff-ff     statisch  \r\n"
+
+	t.Run("english", func(t *testing.T) {
+		devices := parseARPTable(english)
+		if len(devices) != 2 {
+			t.Fatalf("parseARPTable() returned %d devices, want 2: %+v", len(devices), devices)
+		}
+		if devices[0].IP != "192.168.1.1" || devices[1].IP != "192.168.1.42" {
+			t.Errorf("Unexpected IPs: %+v", devices)
+		}
+		for _, d := range devices {
+			if d.Hostname != "Unknown" {
+				t.Errorf("Hostname = %q, want Unknown", d.Hostname)
+			}
+		}
+	})
+
+	t.Run("german", func(t *testing.T) {
+		devices := parseARPTable(german)
+		if len(devices) != 1 || devices[0].IP != "192.168.1.1" {
+			t.Errorf("parseARPTable() = %+v, want only 192.168.1.1", devices)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if devices := parseARPTable(""); len(devices) != 0 {
+			t.Errorf("parseARPTable(\"\") = %+v, want none", devices)
+		}
+	})
+}
+
+func TestParseDefaultGateway(t *testing.T) {
+	routes := "===========================================================================\r\n" +
+		"IPv4 Route Table\r\n" +
+		"===========================================================================\r\n" +
+		"Active Routes:\r\n" +
+		"Network Destination        Netmask          Gateway       Interface  Metric\r\n" +
+		"          0.0.0.0          0.0.0.0      10.8.0.1        10.8.0.12     50\r\n" +
+		"          0.0.0.0          0.0.0.0      192.168.1.1     192.168.1.50     25\r\n" +
+		"===========================================================================\r\n" +
+		"Persistent Routes:\r\n" +
+		"  Network Address          Netmask  Gateway Address  Metric\r\n" +
+		"          0.0.0.0          0.0.0.0        172.16.0.1  Default\r\n"
+	onLink := "          0.0.0.0          0.0.0.0         On-link     192.168.1.50     25\r\n"
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"lowest metric wins", routes, "192.168.1.1"},
+		{"on-link only", onLink, ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDefaultGateway(tt.output); got != tt.want {
+				t.Errorf("parseDefaultGateway() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
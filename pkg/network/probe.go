@@ -0,0 +1,181 @@
+package network
+
+import (
+	"home-sentry/pkg/config"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// probeAttempts is how many rounds of pings and TCP connection attempts the
+// probe strategy sends before counting a device as absent
+const probeAttempts = 3
+
+// probePorts are TCP ports phones commonly answer on: the iOS sync service
+// and web ports. A refused connection proves the host is up just as well as
+// an accepted one.
+var probePorts = []int{62078, 80, 443}
+
+// deletesARPEntry reports whether active detection should delete the ARP
+// entry before pinging, which only works with administrator rights
+func deletesARPEntry(refresh config.ARPRefresh, elevated bool) bool {
+	switch refresh {
+	case config.ARPRefreshDelete:
+		return true
+	case config.ARPRefreshProbe:
+		return false
+	default:
+		return elevated
+	}
+}
+
+// probeDevice checks a host without touching the ARP cache. It pings and tries
+// TCP connections up to probeAttempts times and reports whether anything
+// answered.
+func probeDevice(ip string, opts PingOptions) bool {
+	timeout := time.Duration(opts.TimeoutMs) * time.Millisecond
+	for attempt := 0; attempt < probeAttempts; attempt++ {
+		if PingHostWithOptions(ip, opts) || tcpProbe(ip, probePorts, timeout) {
+			return true
+		}
+	}
+	return false
+}
+
+// tcpProbe reports whether the host accepts or actively refuses a TCP
+// connection on any of the ports
+func tcpProbe(ip string, ports []int, timeout time.Duration) bool {
+	if net.ParseIP(ip) == nil {
+		return false
+	}
+	for _, port := range ports {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(port)), timeout)
+		if err == nil {
+			conn.Close()
+			return true
+		}
+		if isConnectionRefused(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// PresenceCheck counts how many independent methods looked for a device and
+// how many of them did not find it. IP is the address they probed, if any.
+type PresenceCheck struct {
+	Methods int
+	Absent  int
+	IP      string
+}
+
+// ConfirmedAbsent reports whether at least required methods agree the device
+// is gone. When fewer methods could run (e.g. without a known IP), all of
+// them must agree.
+func (c PresenceCheck) ConfirmedAbsent(required int) bool {
+	if required > c.Methods {
+		required = c.Methods
+	}
+	return c.Absent > 0 && c.Absent >= required
+}
+
+// presenceMethods are the building blocks of the checks CheckDevicePresence
+// combines; tests replace them
+type presenceMethods struct {
+	findIP func(mac string) string
+	inARP  func(mac string) bool
+	// clearARP deletes the ARP entry of ip. It is nil when the entry can't be
+	// cleared, as without elevation.
+	clearARP func(ip string)
+	sweep    func()
+	ping     func(ip string) bool // ICMP only
+	probe    func(ip string) bool // Several rounds of ping and TCP
+	tcp      func(ip string) bool
+}
+
+// CheckDevicePresence looks for the device with up to methods independent
+// checks: its MAC in a refreshed ARP cache, a plain ping, and a TCP
+// connection attempt. The IP comes from the ARP cache, or is knownIP once the
+// entry has expired, which is usual soon after the phone leaves. Checks only
+// continue while the earlier ones report the device absent, so a present
+// phone costs no extra time.
+func CheckDevicePresence(mac, knownIP string, opts PingOptions, methods int) PresenceCheck {
+	if runtime.GOOS != "windows" {
+		return PresenceCheck{Methods: 1} // Simulated on non-Windows
+	}
+
+	mac = strings.ReplaceAll(strings.ToLower(mac), ":", "-")
+	// The ping method must not fall back to TCP, or it would repeat the TCP one
+	icmpOnly := opts
+	icmpOnly.ProbePorts = nil
+	timeout := time.Duration(opts.TimeoutMs) * time.Millisecond
+	ports := append(append([]int(nil), probePorts...), opts.ProbePorts...)
+	m := presenceMethods{
+		findIP: FindIPByMAC,
+		inARP:  checkARPForMAC,
+		sweep: func() {
+			// Configured ranges can be large, so don't let the sweep stall the monitor
+			localIP, _, _ := getLocalIP()
+			pingSweep(sweepTargets(localIP, opts.SweepCIDRs), time.Now().Add(config.DefaultScanTimeoutSec*time.Second))
+		},
+		ping:  func(ip string) bool { return PingHostWithOptions(ip, icmpOnly) },
+		probe: func(ip string) bool { return probeDevice(ip, opts) },
+		tcp:   func(ip string) bool { return tcpProbe(ip, ports, timeout) },
+	}
+	if deletesARPEntry(opts.ARPRefresh, IsElevated()) {
+		m.clearARP = deleteARPEntry
+	}
+	return checkPresence(mac, knownIP, methods, m)
+}
+
+func checkPresence(mac, knownIP string, methods int, m presenceMethods) PresenceCheck {
+	check := PresenceCheck{IP: m.findIP(mac)}
+	if check.IP == "" {
+		check.IP = knownIP
+	}
+
+	checks := []func() bool{
+		func() bool { return m.arp(mac, check.IP) },
+	}
+	if check.IP != "" {
+		checks = append(checks,
+			func() bool { return m.ping(check.IP) },
+			func() bool { return m.tcp(check.IP) },
+		)
+	}
+	for _, found := range checks {
+		if check.Methods >= methods {
+			break
+		}
+		check.Methods++
+		if found() {
+			break
+		}
+		check.Absent++
+	}
+	return check
+}
+
+// arp looks for the MAC in a refreshed ARP cache
+func (m presenceMethods) arp(mac, ip string) bool {
+	switch {
+	case ip == "":
+		// Nothing to refresh; a sweep makes the phone show up if it's there
+		m.sweep()
+	case m.inARP(mac):
+		if m.clearARP == nil {
+			// The cached entry would outlive the phone, so ask the phone
+			// itself, with TCP for phones that ignore pings
+			return m.probe(ip)
+		}
+		m.clearARP(ip)
+		m.ping(ip)
+	default:
+		// The entry expired: a ping makes the phone answer ARP even when it
+		// ignores the ping itself
+		m.ping(ip)
+	}
+	return m.inARP(mac)
+}
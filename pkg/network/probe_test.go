@@ -0,0 +1,156 @@
+package network
+
+import (
+	"home-sentry/pkg/config"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeletesARPEntry(t *testing.T) {
+	tests := []struct {
+		refresh  config.ARPRefresh
+		elevated bool
+		want     bool
+	}{
+		{config.ARPRefreshAuto, true, true},
+		{config.ARPRefreshAuto, false, false},
+		{config.ARPRefreshDelete, false, true},
+		{config.ARPRefreshProbe, true, false},
+	}
+	for _, tt := range tests {
+		if got := deletesARPEntry(tt.refresh, tt.elevated); got != tt.want {
+			t.Errorf("deletesARPEntry(%q, %v) = %v, want %v", tt.refresh, tt.elevated, got, tt.want)
+		}
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := listener.Addr().(*net.TCPAddr).Port
+
+	if !tcpProbe("127.0.0.1", []int{open}, time.Second) {
+		t.Error("tcpProbe() = false for a listening port, want true")
+	}
+
+	// Nothing listens once the listener is closed, so the host refuses
+	listener.Close()
+	if !tcpProbe("127.0.0.1", []int{open}, time.Second) {
+		t.Error("tcpProbe() = false for a refused port, want true")
+	}
+
+	if tcpProbe("not-an-ip", []int{open}, time.Second) {
+		t.Error("tcpProbe() = true for an invalid IP, want false")
+	}
+}
+
+func TestConfirmedAbsent(t *testing.T) {
+	tests := []struct {
+		check    PresenceCheck
+		required int
+		want     bool
+	}{
+		{PresenceCheck{Methods: 1}, 1, false},
+		{PresenceCheck{Methods: 1, Absent: 1}, 1, true},
+		{PresenceCheck{Methods: 2, Absent: 1}, 2, false}, // The ping answered
+		{PresenceCheck{Methods: 2, Absent: 2}, 2, true},
+		{PresenceCheck{Methods: 1, Absent: 1}, 3, true}, // No known IP for the other methods
+		{PresenceCheck{Methods: 3, Absent: 2}, 3, false},
+	}
+	for _, tt := range tests {
+		if got := tt.check.ConfirmedAbsent(tt.required); got != tt.want {
+			t.Errorf("%+v.ConfirmedAbsent(%d) = %v, want %v", tt.check, tt.required, got, tt.want)
+		}
+	}
+}
+
+// fakeMethods records the probes checkPresence makes
+type fakeMethods struct {
+	cachedIP string // The phone's ARP entry, "" once it expired
+	elevated bool
+	answers  map[string]bool // Methods the phone answers
+	probed   []string
+}
+
+func (f *fakeMethods) methods() presenceMethods {
+	record := func(name string) func(ip string) bool {
+		return func(ip string) bool {
+			f.probed = append(f.probed, name+" "+ip)
+			return f.answers[name]
+		}
+	}
+	m := presenceMethods{
+		findIP: func(string) string { return f.cachedIP },
+		inARP:  func(string) bool { return f.cachedIP != "" },
+		sweep:  func() { f.probed = append(f.probed, "sweep") },
+		ping:   record("ping"),
+		probe:  record("probe"),
+		tcp:    record("tcp"),
+	}
+	if f.elevated {
+		m.clearARP = func(ip string) {
+			f.probed = append(f.probed, "clear "+ip)
+			f.cachedIP = ""
+		}
+	}
+	return m
+}
+
+func TestCheckPresence(t *testing.T) {
+	const ip = "192.168.1.20"
+	tests := []struct {
+		name        string
+		fake        fakeMethods
+		knownIP     string
+		methods     int
+		wantMethods int
+		wantAbsent  int
+		wantProbed  []string
+	}{
+		{
+			name: "entry expired, all methods absent", knownIP: ip, methods: 3,
+			wantMethods: 3, wantAbsent: 3,
+			wantProbed: []string{"ping " + ip, "ping " + ip, "tcp " + ip},
+		},
+		{
+			name: "entry expired, ping answers", fake: fakeMethods{answers: map[string]bool{"ping": true}}, knownIP: ip, methods: 3,
+			wantMethods: 2, wantAbsent: 1,
+			wantProbed: []string{"ping " + ip, "ping " + ip},
+		},
+		{
+			name: "no IP known", methods: 3,
+			wantMethods: 1, wantAbsent: 1,
+			wantProbed: []string{"sweep"},
+		},
+		{
+			name: "unelevated with a cached entry, phone answers TCP", fake: fakeMethods{cachedIP: ip, answers: map[string]bool{"probe": true}}, methods: 1,
+			wantMethods: 1, wantAbsent: 0,
+			wantProbed: []string{"probe " + ip},
+		},
+		{
+			name: "unelevated with a cached entry, phone gone", fake: fakeMethods{cachedIP: ip}, methods: 1,
+			wantMethods: 1, wantAbsent: 1,
+			wantProbed: []string{"probe " + ip},
+		},
+		{
+			name: "elevated with a cached entry", fake: fakeMethods{cachedIP: ip, elevated: true}, methods: 1,
+			wantMethods: 1, wantAbsent: 1,
+			wantProbed: []string{"clear " + ip, "ping " + ip},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := checkPresence("aa-bb-cc-dd-ee-ff", tt.knownIP, tt.methods, tt.fake.methods())
+			if check.Methods != tt.wantMethods || check.Absent != tt.wantAbsent {
+				t.Errorf("checkPresence() = %+v, want %d methods, %d absent", check, tt.wantMethods, tt.wantAbsent)
+			}
+			if !reflect.DeepEqual(tt.fake.probed, tt.wantProbed) {
+				t.Errorf("Probed %v, want %v", tt.fake.probed, tt.wantProbed)
+			}
+		})
+	}
+}
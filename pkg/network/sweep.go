@@ -0,0 +1,60 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// sweepTargets lists the hosts a ping sweep should cover. With no CIDRs it
+// is the /24 around myIP; otherwise every host address in each CIDR. myIP is
+// never included, and network/broadcast addresses are skipped for ranges
+// large enough to have them.
+func sweepTargets(myIP string, cidrs []string) []string {
+	if len(cidrs) == 0 {
+		// Simple assumption: /24 network
+		parts := strings.Split(myIP, ".")
+		if len(parts) != 4 {
+			return nil
+		}
+		baseIP := fmt.Sprintf("%s.%s.%s.", parts[0], parts[1], parts[2])
+
+		targets := make([]string, 0, 253)
+		for i := 1; i < 255; i++ {
+			if ip := baseIP + strconv.Itoa(i); ip != myIP {
+				targets = append(targets, ip)
+			}
+		}
+		return targets
+	}
+
+	seen := make(map[string]bool)
+	var targets []string
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil || ipNet.IP.To4() == nil {
+			continue // Settings validation already warned about it
+		}
+		ones, bits := ipNet.Mask.Size()
+		first := binary.BigEndian.Uint32(ipNet.IP.To4())
+		last := first | (1<<uint(bits-ones) - 1)
+		if bits-ones >= 2 {
+			first++
+			last--
+		}
+
+		for n := uint64(first); n <= uint64(last); n++ {
+			ip := make(net.IP, 4)
+			binary.BigEndian.PutUint32(ip, uint32(n))
+			s := ip.String()
+			if s == myIP || seen[s] {
+				continue
+			}
+			seen[s] = true
+			targets = append(targets, s)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,37 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSweepTargets(t *testing.T) {
+	t.Run("local /24", func(t *testing.T) {
+		targets := sweepTargets("192.168.1.10", nil)
+		if len(targets) != 253 {
+			t.Fatalf("got %d targets, want 253", len(targets))
+		}
+		if targets[0] != "192.168.1.1" || targets[len(targets)-1] != "192.168.1.254" {
+			t.Errorf("range = %s..%s, want 192.168.1.1..192.168.1.254", targets[0], targets[len(targets)-1])
+		}
+		for _, ip := range targets {
+			if ip == "192.168.1.10" {
+				t.Error("own address should be skipped")
+			}
+		}
+	})
+
+	t.Run("no local IP", func(t *testing.T) {
+		if targets := sweepTargets("", nil); len(targets) != 0 {
+			t.Errorf("got %d targets without a local IP, want none", len(targets))
+		}
+	})
+
+	t.Run("configured CIDRs", func(t *testing.T) {
+		got := sweepTargets("10.0.0.2", []string{"10.0.0.0/29", "10.0.5.7/32", "10.0.0.4/30", "bogus"})
+		want := []string{"10.0.0.1", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6", "10.0.5.7"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("sweepTargets() = %v, want %v", got, want)
+		}
+	})
+}
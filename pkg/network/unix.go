@@ -3,9 +3,25 @@
 
 package network
 
-import "os/exec"
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
 
 // HideConsole is a no-op on non-Windows platforms
 func HideConsole(cmd *exec.Cmd) {
 	// Nothing to do on non-Windows
 }
+
+// IsElevated reports whether the process runs as root
+func IsElevated() bool {
+	return os.Geteuid() == 0
+}
+
+// isConnectionRefused reports whether a dial failed because the host answered
+// with a reset
+func isConnectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -4,8 +4,11 @@
 package network
 
 import (
+	"errors"
 	"os/exec"
 	"syscall"
+
+	"golang.org/x/sys/windows"
 )
 
 // HideConsole configures a command to run without showing a console window on Windows
@@ -15,3 +18,15 @@ func HideConsole(cmd *exec.Cmd) {
 		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
 	}
 }
+
+// IsElevated reports whether the process runs as administrator, which active
+// detection needs to clear stale ARP entries
+func IsElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// isConnectionRefused reports whether a dial failed because the host answered
+// with a reset
+func isConnectionRefused(err error) bool {
+	return errors.Is(err, windows.WSAECONNREFUSED)
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package network
+
+import "errors"
+
+var errWLANNotConnected = errors.New("no connected wireless interface")
+
+// queryWLANSSID is only implemented on Windows
+func queryWLANSSID() (string, error) {
+	return "", errors.New("WLAN API is only available on Windows")
+}
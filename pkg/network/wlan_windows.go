@@ -0,0 +1,117 @@
+//go:build windows
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	wlanapi                = syscall.NewLazyDLL("wlanapi.dll")
+	procWlanOpenHandle     = wlanapi.NewProc("WlanOpenHandle")
+	procWlanCloseHandle    = wlanapi.NewProc("WlanCloseHandle")
+	procWlanEnumInterfaces = wlanapi.NewProc("WlanEnumInterfaces")
+	procWlanQueryInterface = wlanapi.NewProc("WlanQueryInterface")
+	procWlanFreeMemory     = wlanapi.NewProc("WlanFreeMemory")
+)
+
+const (
+	wlanClientVersion            = 2 // Windows Vista and later
+	wlanInterfaceStateConnected  = 1
+	wlanIntfOpcodeCurrentConnect = 7 // wlan_intf_opcode_current_connection
+)
+
+// errWLANNotConnected is returned when no wireless interface is connected
+var errWLANNotConnected = errors.New("no connected wireless interface")
+
+// wlanInterfaceInfo mirrors WLAN_INTERFACE_INFO
+type wlanInterfaceInfo struct {
+	interfaceGUID [16]byte
+	description   [256]uint16
+	state         uint32
+}
+
+// wlanInterfaceInfoList mirrors the header of WLAN_INTERFACE_INFO_LIST
+type wlanInterfaceInfoList struct {
+	numberOfItems uint32
+	index         uint32
+	interfaceInfo [1]wlanInterfaceInfo
+}
+
+// wlanConnectionAttributes mirrors the leading fields of WLAN_CONNECTION_ATTRIBUTES
+// up to the BSSID; the remaining fields are never read.
+type wlanConnectionAttributes struct {
+	state          uint32
+	connectionMode uint32
+	profileName    [256]uint16
+	ssidLength     uint32
+	ssid           [32]byte
+	bssType        uint32
+	bssid          [6]byte
+}
+
+// queryWLANSSID reads the connected SSID through the native WLAN API. Unlike
+// parsing `netsh` output this does not depend on the display language.
+func queryWLANSSID() (string, error) {
+	if err := wlanapi.Load(); err != nil {
+		return "", fmt.Errorf("wlanapi.dll unavailable: %w", err)
+	}
+
+	var negotiated uint32
+	var handle syscall.Handle
+	ret, _, _ := procWlanOpenHandle.Call(
+		wlanClientVersion,
+		0,
+		uintptr(unsafe.Pointer(&negotiated)),
+		uintptr(unsafe.Pointer(&handle)),
+	)
+	if ret != 0 {
+		return "", fmt.Errorf("WlanOpenHandle failed: %v", syscall.Errno(ret))
+	}
+	defer procWlanCloseHandle.Call(uintptr(handle), 0)
+
+	var list *wlanInterfaceInfoList
+	ret, _, _ = procWlanEnumInterfaces.Call(uintptr(handle), 0, uintptr(unsafe.Pointer(&list)))
+	if ret != 0 {
+		return "", fmt.Errorf("WlanEnumInterfaces failed: %v", syscall.Errno(ret))
+	}
+	defer procWlanFreeMemory.Call(uintptr(unsafe.Pointer(list)))
+
+	interfaces := unsafe.Slice(&list.interfaceInfo[0], list.numberOfItems)
+	for i := range interfaces {
+		if interfaces[i].state != wlanInterfaceStateConnected {
+			continue
+		}
+
+		var dataSize uint32
+		var attrs *wlanConnectionAttributes
+		ret, _, _ = procWlanQueryInterface.Call(
+			uintptr(handle),
+			uintptr(unsafe.Pointer(&interfaces[i].interfaceGUID)),
+			wlanIntfOpcodeCurrentConnect,
+			0,
+			uintptr(unsafe.Pointer(&dataSize)),
+			uintptr(unsafe.Pointer(&attrs)),
+			0,
+		)
+		if ret != 0 {
+			return "", fmt.Errorf("WlanQueryInterface failed: %v", syscall.Errno(ret))
+		}
+
+		length := attrs.ssidLength
+		if length > uint32(len(attrs.ssid)) {
+			length = uint32(len(attrs.ssid))
+		}
+		ssid := string(attrs.ssid[:length])
+		procWlanFreeMemory.Call(uintptr(unsafe.Pointer(attrs)))
+
+		if ssid != "" {
+			return ssid, nil
+		}
+	}
+
+	return "", errWLANNotConnected
+}
@@ -0,0 +1,55 @@
+// Package notify delivers Home Sentry's alerts. Each backend (the local toast,
+// the critical webhook, ...) implements Notifier, and MultiNotifier fans an
+// event out to all of them, so the monitor only ever talks to one Notifier.
+package notify
+
+import "errors"
+
+// Level says how urgent an event is
+type Level int
+
+const (
+	// Info events are only shown locally
+	Info Level = iota
+	// Critical events announce an imminent or failed shutdown and also go
+	// to remote backends
+	Critical
+)
+
+// Event is one notification
+type Event struct {
+	Level   Level
+	Title   string
+	Message string
+	// Template is the payload sent to webhooks, with {placeholder} tokens
+	// filled from Fields. Events without one are not sent to webhooks.
+	Template string
+	Fields   map[string]string
+}
+
+// Notifier delivers events through one backend
+type Notifier interface {
+	Notify(event Event) error
+}
+
+// MultiNotifier sends every event to each of its notifiers, even when earlier
+// ones fail, and returns their errors joined
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Notify(event Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Discard drops every event, for monitors whose alerts are left to another
+// instance
+type Discard struct{}
+
+func (Discard) Notify(Event) error {
+	return nil
+}
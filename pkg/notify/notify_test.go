@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recorder counts the events it receives and fails with err
+type recorder struct {
+	events int
+	err    error
+}
+
+func (r *recorder) Notify(Event) error {
+	r.events++
+	return r.err
+}
+
+func TestMultiNotifier(t *testing.T) {
+	failed := errors.New("backend down")
+	first := &recorder{err: failed}
+	second := &recorder{}
+
+	err := MultiNotifier{first, second}.Notify(Event{Title: "Home Sentry"})
+	if !errors.Is(err, failed) {
+		t.Errorf("Notify() error = %v, want %v", err, failed)
+	}
+	if first.events != 1 || second.events != 1 {
+		t.Errorf("Notifiers got %d and %d events, want 1 each", first.events, second.events)
+	}
+
+	if err := (MultiNotifier{second}).Notify(Event{}); err != nil {
+		t.Errorf("Notify() error = %v, want nil", err)
+	}
+}
+
+func TestWebhookOnlyPostsCriticalEvents(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	w := Webhook{URL: server.URL}
+	fields := map[string]string{"hostname": "LAPTOP"}
+	events := []Event{
+		{Level: Info, Title: "Welcome home", Template: "{hostname} is home", Fields: fields},
+		{Level: Critical, Title: "No payload"},
+		{Level: Critical, Title: "Alert", Template: "{hostname} shuts down", Fields: fields},
+	}
+	for _, event := range events {
+		if err := w.Notify(event); err != nil {
+			t.Fatalf("Notify(%q) error = %v", event.Title, err)
+		}
+	}
+	if len(bodies) != 1 || bodies[0] != "LAPTOP shuts down" {
+		t.Errorf("Webhook received %q, want only %q", bodies, "LAPTOP shuts down")
+	}
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"fmt"
+	"home-sentry/pkg/network"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Toast shows events as a Windows tray balloon. It does nothing elsewhere.
+type Toast struct{}
+
+func (Toast) Notify(event Event) error {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+
+	// Escape inputs to prevent PowerShell injection
+	safeTitle := escapePowerShellString(event.Title)
+	safeMessage := escapePowerShellString(event.Message)
+
+	// Use PowerShell for toast notification
+	script := fmt.Sprintf(`
+		Add-Type -AssemblyName System.Windows.Forms
+		$balloon = New-Object System.Windows.Forms.NotifyIcon
+		$balloon.Icon = [System.Drawing.SystemIcons]::Warning
+		$balloon.BalloonTipIcon = [System.Windows.Forms.ToolTipIcon]::Warning
+		$balloon.BalloonTipTitle = '%s'
+		$balloon.BalloonTipText = '%s'
+		$balloon.Visible = $true
+		$balloon.ShowBalloonTip(10000)
+		Start-Sleep -Seconds 10
+		$balloon.Dispose()
+	`, safeTitle, safeMessage)
+	cmd := exec.Command("powershell", "-WindowStyle", "Hidden", "-Command", script)
+	network.HideConsole(cmd)
+	// The balloon stays up for 10 seconds; don't wait for it
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// escapePowerShellString escapes a string for safe use inside single-quoted PowerShell strings.
+// Handles single quotes, null bytes, backticks (escape char), and newlines.
+func escapePowerShellString(s string) string {
+	// In PowerShell, single quotes are escaped by doubling them
+	s = strings.ReplaceAll(s, "'", "''")
+	// Remove null bytes for safety
+	s = strings.ReplaceAll(s, "\x00", "")
+	// Remove backticks (PowerShell escape character)
+	s = strings.ReplaceAll(s, "`", "")
+	// Remove newlines/carriage returns that could break the script structure
+	s = strings.ReplaceAll(s, "\n", " ")
+	s = strings.ReplaceAll(s, "\r", "")
+	// Truncate to prevent buffer abuse
+	const maxPSStringLen = 256
+	if len(s) > maxPSStringLen {
+		s = s[:maxPSStringLen]
+	}
+	return s
+}
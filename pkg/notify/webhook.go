@@ -0,0 +1,15 @@
+package notify
+
+import "home-sentry/pkg/webhook"
+
+// Webhook posts critical events with a payload template to URL
+type Webhook struct {
+	URL string
+}
+
+func (w Webhook) Notify(event Event) error {
+	if w.URL == "" || event.Level < Critical || event.Template == "" {
+		return nil
+	}
+	return webhook.Post(w.URL, webhook.Render(event.Template, event.Fields))
+}
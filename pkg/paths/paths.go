@@ -0,0 +1,33 @@
+// Package paths decides where Home Sentry keeps its settings, state and logs,
+// so every file lands in the same directory even when %APPDATA% is missing.
+package paths
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// AppName is the directory Home Sentry's files live in, under the base
+const AppName = "HomeSentry"
+
+// baseVars are the environment variables tried for the base directory, in order
+var baseVars = []string{"APPDATA", "LOCALAPPDATA", "USERPROFILE"}
+
+// Base returns the directory AppDir lives in and where it came from: the
+// first of %APPDATA%, %LOCALAPPDATA% and %USERPROFILE% that is set, or else
+// the temp directory ("temp"). The working directory is never used; it may
+// be a read-only install directory.
+func Base() (dir, source string) {
+	for _, name := range baseVars {
+		if dir := os.Getenv(name); dir != "" {
+			return dir, name
+		}
+	}
+	return os.TempDir(), "temp"
+}
+
+// AppDir returns the directory holding Home Sentry's files
+func AppDir() string {
+	base, _ := Base()
+	return filepath.Join(base, AppName)
+}
@@ -0,0 +1,35 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseFallbacks(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantDir    string
+		wantSource string
+	}{
+		{"appdata", map[string]string{"APPDATA": `C:\Roaming`, "LOCALAPPDATA": `C:\Local`}, `C:\Roaming`, "APPDATA"},
+		{"appdata empty", map[string]string{"LOCALAPPDATA": `C:\Local`, "USERPROFILE": `C:\Users\me`}, `C:\Local`, "LOCALAPPDATA"},
+		{"profile only", map[string]string{"USERPROFILE": `C:\Users\me`}, `C:\Users\me`, "USERPROFILE"},
+		{"nothing set", nil, os.TempDir(), "temp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range baseVars {
+				t.Setenv(name, tt.env[name])
+			}
+			dir, source := Base()
+			if dir != tt.wantDir || source != tt.wantSource {
+				t.Errorf("Base() = %q, %q, want %q, %q", dir, source, tt.wantDir, tt.wantSource)
+			}
+			if want := filepath.Join(tt.wantDir, AppName); AppDir() != want {
+				t.Errorf("AppDir() = %q, want %q", AppDir(), want)
+			}
+		})
+	}
+}
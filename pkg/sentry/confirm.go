@@ -0,0 +1,100 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"home-sentry/pkg/logger"
+	"home-sentry/pkg/network"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Buttons of the WScript.Shell popup
+const (
+	popupOKCancel    = 0x01
+	popupExclamation = 0x30
+	popupOK          = 1
+	popupCancel      = 2
+)
+
+// awaitConfirmation gives the user delay to cancel the shutdown before the
+// countdown starts, showing the confirmation dialog if there is one. It
+// reports whether the countdown should go ahead: when the user confirms or
+// the delay runs out.
+func (s *SentryManager) awaitConfirmation(delay time.Duration, absent string, cancelled <-chan struct{}) bool {
+	logger.Info("Waiting %s for the shutdown to be confirmed or cancelled", delay)
+
+	ctx, dismiss := context.WithCancel(context.Background())
+	defer dismiss() // Closes the dialog if it is still open
+	var answers <-chan bool
+	if s.askConfirmation != nil {
+		answers = s.askConfirmation(ctx, fmt.Sprintf(
+			"Phone not detected for %s. The shutdown countdown starts in %s.\n\nOK starts it now, Cancel stops the shutdown.",
+			absent, delay), delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case confirmed, ok := <-answers:
+			if !ok {
+				answers = nil // The dialog failed; wait out the delay
+				continue
+			}
+			if confirmed {
+				logger.Info("Shutdown confirmed by user")
+				return true
+			}
+			s.CancelShutdown()
+			s.setStatus(StatusMonitoring)
+			return false
+		case <-cancelled:
+			logger.Info("Shutdown cancelled during confirmation")
+			s.setStatus(StatusMonitoring)
+			return false
+		}
+	}
+}
+
+// showConfirmDialog shows an OK/Cancel popup that closes itself after
+// timeout or when ctx is cancelled. It delivers true for OK and false for
+// Cancel, and closes the channel without an answer otherwise.
+func showConfirmDialog(ctx context.Context, message string, timeout time.Duration) <-chan bool {
+	answers := make(chan bool, 1)
+	if runtime.GOOS != "windows" {
+		close(answers)
+		return answers
+	}
+
+	// The message is passed through the environment so it is never parsed as script
+	script := fmt.Sprintf("(New-Object -ComObject WScript.Shell).Popup($env:HOME_SENTRY_MESSAGE, %d, 'Home Sentry', %d)",
+		int(timeout.Seconds()), popupOKCancel|popupExclamation)
+	cmd := exec.CommandContext(ctx, "powershell", "-WindowStyle", "Hidden", "-Command", script)
+	cmd.Env = append(os.Environ(), "HOME_SENTRY_MESSAGE="+message)
+	network.HideConsole(cmd)
+
+	go func() {
+		defer close(answers)
+		output, err := cmd.Output()
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("Confirmation dialog failed: %v", err)
+			}
+			return
+		}
+		switch button, _ := strconv.Atoi(strings.TrimSpace(string(output))); button {
+		case popupOK:
+			answers <- true
+		case popupCancel:
+			answers <- false
+		}
+	}()
+	return answers
+}
@@ -0,0 +1,193 @@
+package sentry
+
+import (
+	"fmt"
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/dock"
+	"home-sentry/pkg/logger"
+	"home-sentry/pkg/network"
+	"home-sentry/pkg/notify"
+	"sync"
+)
+
+// PresenceChecker reports whether the monitored device is on the network
+type PresenceChecker interface {
+	IsPresent(settings config.Settings) bool
+}
+
+// SSIDProvider reports the WiFi network the machine is connected to
+type SSIDProvider interface {
+	CurrentSSID() string
+}
+
+// GatewayProvider reports the MAC address of the default gateway
+type GatewayProvider interface {
+	GatewayMAC() string
+}
+
+// PeripheralProvider lists the monitors and USB devices attached to the machine
+type PeripheralProvider interface {
+	Peripherals() []string
+}
+
+// SettingsProvider supplies the settings used for each monitor check
+type SettingsProvider interface {
+	Load() (config.Settings, error)
+}
+
+// Dependencies are the outside-world lookups the monitor relies on.
+// Tests replace them to drive the monitor without a real network.
+type Dependencies struct {
+	Presence PresenceChecker
+	SSID     SSIDProvider
+	Gateway  GatewayProvider
+	Settings SettingsProvider
+	// Peripherals feeds DisarmWhenDockedTo; a monitor without it (e.g. a
+	// rehearsal) never counts as docked
+	Peripherals PeripheralProvider
+	// Notifier receives every alert; a monitor without one shows nothing
+	Notifier notify.Notifier
+	// DryRun runs the shutdown countdown but never the shutdown action,
+	// pre-shutdown command or critical webhook
+	DryRun bool
+}
+
+// DefaultDependencies returns the real network and config implementations
+func DefaultDependencies() Dependencies {
+	return Dependencies{
+		Presence: &networkPresence{},
+		SSID:     networkSSID{},
+		Gateway:  networkGateway{},
+		Settings: configSettings{},
+
+		Peripherals: dockPeripherals{},
+		Notifier:    defaultNotifier(configSettings{}),
+	}
+}
+
+// defaultNotifier shows alerts as toasts and posts critical ones to the
+// webhook configured in the settings
+func defaultNotifier(settings SettingsProvider) notify.Notifier {
+	return notify.MultiNotifier{notify.Toast{}, settingsWebhook{settings: settings}}
+}
+
+// networkPresence checks presence using the configured detection mode. It
+// remembers the phone's last IP so an expired ARP entry doesn't leave the
+// active checks with nothing to probe.
+type networkPresence struct {
+	mu     sync.Mutex
+	lastIP string
+	// readOnly keeps a changed phone IP out of the settings, for dry runs
+	readOnly bool
+}
+
+func (p *networkPresence) IsPresent(settings config.Settings) bool {
+	if settings.DetectionType == config.DetectionTypeIP {
+		ping := func(ip string) bool {
+			return network.PingHostWithOptions(ip, network.PingOptionsFromSettings(settings))
+		}
+		save := func(ip string) error { return config.UpdateDevice(ip, "", "") }
+		if p.readOnly {
+			save = func(string) error { return nil }
+		}
+		return isPresentByIP(settings, ping, network.FindIPByMAC, save)
+	}
+	if settings.DetectionType == config.DetectionTypeMDNS {
+		return network.IsMDNSNamePresent(settings.PhoneHostname, network.PingOptionsFromSettings(settings))
+	}
+	if settings.DetectionMode == config.DetectionModePassive {
+		return network.IsDeviceInARPTable(settings.PhoneMAC)
+	}
+	p.mu.Lock()
+	knownIP := p.lastIP
+	p.mu.Unlock()
+	if knownIP == "" {
+		knownIP = settings.PhoneIP
+	}
+	check := network.CheckDevicePresence(settings.PhoneMAC, knownIP, network.PingOptionsFromSettings(settings), settings.ConfirmAbsenceMethods)
+	present := !check.ConfirmedAbsent(settings.ConfirmAbsenceMethods)
+	if present && check.IP != "" {
+		p.mu.Lock()
+		p.lastIP = check.IP
+		p.mu.Unlock()
+	}
+	return present
+}
+
+// isPresentByIP pings the configured phone IP. When that fails and
+// AutoUpdateDeviceIP is on, the phone may just have a new DHCP lease: its
+// current IP is looked up by MAC, saved, and pinged instead.
+func isPresentByIP(settings config.Settings, ping func(ip string) bool, findIP func(mac string) string, save func(ip string) error) bool {
+	if ping(settings.PhoneIP) {
+		return true
+	}
+	if !settings.AutoUpdateDeviceIP || settings.PhoneMAC == "" {
+		return false
+	}
+	ip := findIP(settings.PhoneMAC)
+	if ip == "" || ip == settings.PhoneIP || !ping(ip) {
+		return false
+	}
+
+	logger.Info("Phone IP changed from %s to %s", config.SanitizeDisplayString(settings.PhoneIP), config.SanitizeDisplayString(ip))
+	if err := save(ip); err != nil {
+		logger.Error("Failed to save the new phone IP: %v", err)
+	}
+	return true
+}
+
+// networkSSID reads the SSID from the WiFi adapter
+type networkSSID struct{}
+
+func (networkSSID) CurrentSSID() string {
+	return network.GetCurrentSSID()
+}
+
+// networkGateway looks the gateway up in the routing table and ARP cache
+type networkGateway struct{}
+
+func (networkGateway) GatewayMAC() string {
+	return network.GetDefaultGatewayMAC()
+}
+
+// dockPeripherals lists the peripherals through the dock package
+type dockPeripherals struct{}
+
+func (dockPeripherals) Peripherals() []string {
+	peripherals, err := dock.Peripherals()
+	if err != nil {
+		logger.Debug("Listing peripherals failed: %v", err)
+	}
+	return peripherals
+}
+
+// settingsWebhook posts critical alerts to the critical webhook of the
+// current settings, so a changed URL applies without a restart
+type settingsWebhook struct {
+	settings SettingsProvider
+}
+
+func (w settingsWebhook) Notify(event notify.Event) error {
+	if event.Level < notify.Critical || event.Template == "" {
+		return nil
+	}
+	settings, err := w.settings.Load()
+	if err != nil {
+		return err
+	}
+	if settings.CriticalWebhookURL == "" {
+		return nil
+	}
+	if err := (notify.Webhook{URL: settings.CriticalWebhookURL}).Notify(event); err != nil {
+		return fmt.Errorf("critical webhook: %w", err)
+	}
+	logger.Info("Critical webhook sent")
+	return nil
+}
+
+// configSettings loads settings from the settings file
+type configSettings struct{}
+
+func (configSettings) Load() (config.Settings, error) {
+	return config.Load()
+}
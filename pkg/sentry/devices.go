@@ -0,0 +1,101 @@
+package sentry
+
+import (
+	"fmt"
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/logger"
+	"home-sentry/pkg/network"
+	"sort"
+	"strings"
+)
+
+// rememberDevice marks mac as the most recently seen known device, forgetting
+// the least recently seen ones beyond MaxKnownDevices so a busy network can't
+// bloat the state file. A forgotten device that comes back is reported as new
+// again. Callers must hold s.mu.
+func (s *SentryManager) rememberDevice(mac string) {
+	if s.knownMACs[mac] {
+		for i, known := range s.knownOrder {
+			if known == mac {
+				s.knownOrder = append(s.knownOrder[:i], s.knownOrder[i+1:]...)
+				break
+			}
+		}
+	}
+	s.knownMACs[mac] = true
+	s.knownOrder = append(s.knownOrder, mac)
+	if excess := len(s.knownOrder) - config.MaxKnownDevices; excess > 0 {
+		for _, old := range s.knownOrder[:excess] {
+			delete(s.knownMACs, old)
+		}
+		s.knownOrder = append([]string(nil), s.knownOrder[excess:]...)
+	}
+}
+
+// ObserveDevices compares a fresh home-network scan against the persisted set
+// of known devices and returns the ones never seen before. When alert is true
+// a notification is shown for newcomers. The very first scan only seeds the
+// known set, otherwise every device on the network would be reported as new.
+func (s *SentryManager) ObserveDevices(devices []network.NetworkDevice, alert bool) []network.NetworkDevice {
+	var newDevices []network.NetworkDevice
+
+	s.mu.Lock()
+	if s.knownMACs == nil {
+		s.knownMACs = make(map[string]bool)
+	}
+	seeding := len(s.knownMACs) == 0
+	for _, device := range devices {
+		mac, err := config.SanitizeMAC(device.MAC)
+		if err != nil || mac == "" {
+			continue
+		}
+		known := s.knownMACs[mac]
+		s.rememberDevice(mac)
+		if !known && !seeding {
+			newDevices = append(newDevices, device)
+		}
+	}
+	s.mu.Unlock()
+
+	if seeding {
+		logger.Info("Known device list initialized with %d devices", len(devices))
+		s.saveState()
+		return nil
+	}
+	if len(newDevices) == 0 {
+		return nil
+	}
+	s.saveState()
+
+	labels := make([]string, 0, len(newDevices))
+	for _, device := range newDevices {
+		label := fmt.Sprintf("%s (%s)", device.MAC, device.Vendor)
+		logger.Info("New device joined the network: %s, IP %s", label, device.IP)
+		labels = append(labels, label)
+	}
+
+	if alert {
+		var message string
+		if len(labels) == 1 {
+			message = fmt.Sprintf("New device %s joined your network", labels[0])
+		} else {
+			message = fmt.Sprintf("%d new devices joined your network: %s", len(labels), strings.Join(labels, ", "))
+		}
+		s.showNotification("Home Sentry - New Device", message)
+	}
+
+	return newDevices
+}
+
+// sortedKeys returns the keys of a set in sorted order for stable persistence
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
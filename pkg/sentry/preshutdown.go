@@ -0,0 +1,63 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/logger"
+	"home-sentry/pkg/network"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runPreShutdownCommand runs the user's pre-shutdown script, if enabled, and
+// waits for it up to the configured timeout. The shutdown action always runs
+// afterwards, whatever the script's outcome.
+func runPreShutdownCommand(settings config.Settings) {
+	if !settings.PreShutdownEnabled || settings.PreShutdownCommand == "" {
+		return
+	}
+
+	path := settings.PreShutdownCommand
+	safePath := config.SanitizeDisplayString(path)
+
+	// Re-check right before running in case the file changed since load
+	if err := config.ValidatePreShutdownCommand(path); err != nil {
+		logger.Error("Skipping pre-shutdown command %s: %v", safePath, err)
+		return
+	}
+
+	timeout := time.Duration(settings.PreShutdownTimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = config.DefaultPreShutdownSec * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := preShutdownCommand(ctx, path)
+	network.HideConsole(cmd)
+
+	logger.Info("Running pre-shutdown command %s (timeout %v)", safePath, timeout)
+	err := cmd.Run()
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		logger.Warn("Pre-shutdown command timed out after %v", timeout)
+	case cmd.ProcessState != nil:
+		logger.Info("Pre-shutdown command exited with code %d", cmd.ProcessState.ExitCode())
+	case err != nil:
+		logger.Error("Pre-shutdown command failed to start: %v", err)
+	}
+}
+
+// preShutdownCommand builds the command for a validated script path using
+// fixed arguments only. PowerShell scripts go through powershell.exe.
+func preShutdownCommand(ctx context.Context, path string) *exec.Cmd {
+	if strings.EqualFold(filepath.Ext(path), ".ps1") {
+		return exec.CommandContext(ctx, "powershell.exe",
+			"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", path)
+	}
+	return exec.CommandContext(ctx, path)
+}
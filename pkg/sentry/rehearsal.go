@@ -0,0 +1,99 @@
+package sentry
+
+import (
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/notify"
+	"time"
+)
+
+// Rehearsal walks the user's own settings through a phone that disappears:
+// grace checks, the warning toast, sounds and the cancellable countdown all
+// run for real, but the shutdown action and the critical webhook are skipped.
+// Nothing is read from or written to the state file.
+type Rehearsal struct {
+	sm       *SentryManager
+	script   *scriptedNetwork
+	settings config.Settings
+	reached  bool // The countdown was started
+}
+
+// rehearsalSettings adapts the user's settings so the rehearsal always runs:
+// protection is active, grace checks are one second apart, and a missing home
+// network or device is filled in with a placeholder
+func rehearsalSettings(settings config.Settings) config.Settings {
+	settings.IsPaused = false
+	settings.PausedUntil = time.Time{}
+	settings.PollInterval = 1
+	settings.AutoSwitchProfile = false
+	// The scripted network only reports an SSID
+	if settings.HomeDetection == config.HomeDetectionGateway || settings.HomeSSID == "" {
+		settings.HomeDetection = config.HomeDetectionSSID
+		settings.HomeSSID = "Rehearsal Home"
+	}
+	if !settings.HasDeviceConfigured() {
+		settings.DetectionType = config.DetectionTypeMAC
+		settings.PhoneMAC = "02-00-00-00-00-01"
+	}
+	return settings
+}
+
+// NewRehearsal prepares a rehearsal of the shutdown flow. onChange receives
+// every status change.
+func NewRehearsal(settings config.Settings, onChange func(StatusChange)) *Rehearsal {
+	settings = rehearsalSettings(settings)
+	script := &scriptedNetwork{settings: settings}
+
+	r := &Rehearsal{script: script, settings: settings}
+	r.sm = &SentryManager{
+		status:         StatusRoaming,
+		cancelShutdown: make(chan struct{}),
+		knownMACs:      make(map[string]bool),
+		StatusCallback: onChange,
+		deps: Dependencies{
+			Presence: script,
+			SSID:     script,
+			Settings: staticSettings{settings: settings},
+			Notifier: notify.Toast{},
+			DryRun:   true,
+		},
+	}
+	r.sm.askConfirmation = showConfirmDialog
+	r.sm.onShutdown = func(settings config.Settings, ssid string) {
+		r.reached = true
+		r.sm.triggerShutdownWithCountdown(settings, ssid)
+	}
+	return r
+}
+
+// Run plays the rehearsal and blocks until the countdown ran out or was
+// cancelled. It reports whether the countdown ran out, i.e. whether the real
+// shutdown action would have run.
+func (r *Rehearsal) Run() bool {
+	return r.run(time.Sleep)
+}
+
+func (r *Rehearsal) run(sleep func(time.Duration)) bool {
+	r.script.current = simHome
+	_, wait := r.sm.step()
+
+	r.script.current = simMissing
+	for i := 0; i < r.settings.GraceChecks && !r.reached; i++ {
+		sleep(wait)
+		_, wait = r.sm.step()
+	}
+
+	r.sm.mu.Lock()
+	defer r.sm.mu.Unlock()
+	return r.reached && r.sm.shutdownCount > 0
+}
+
+// Cancel stops a running countdown, just like the tray's cancel option. It
+// reports whether a countdown was running.
+func (r *Rehearsal) Cancel() bool {
+	return r.sm.CancelShutdown()
+}
+
+// Settings returns the settings the rehearsal runs with
+func (r *Rehearsal) Settings() config.Settings {
+	return r.settings
+}
@@ -1,16 +1,22 @@
 package sentry
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"home-sentry/pkg/config"
+	"home-sentry/pkg/dock"
 	"home-sentry/pkg/network"
+	"home-sentry/pkg/notify"
+	"home-sentry/pkg/paths"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"home-sentry/pkg/logger"
@@ -25,24 +31,110 @@ const (
 	StatusShutdownImminent SentryStatus = "ShutdownImminent"
 	StatusPaused           SentryStatus = "Paused"
 	StatusWaitingForPhone  SentryStatus = "WaitingForPhone"
+	StatusNoNetwork        SentryStatus = "NoNetwork"
+	StatusCooldown         SentryStatus = "Cooldown" // Phone missing, but a shutdown ran too recently
+	StatusDocked           SentryStatus = "Docked"   // A DisarmWhenDockedTo peripheral is attached
 )
 
+// StatusChange describes a status transition together with the monitor
+// context it happened in, so consumers don't need to re-read settings.
+type StatusChange struct {
+	Status          SentryStatus
+	SSID            string
+	Device          string
+	GraceCount      int
+	ShutdownPending bool
+}
+
 type SentryManager struct {
 	status          SentryStatus
 	graceCount      int
+	missingSince    time.Time // First miss of the current grace period
+	earlyCheck      bool      // The current check was woken by a network change
 	phoneEverSeen   bool
-	StatusCallback  func(SentryStatus)
+	lastSeen        time.Time
+	lastSeenSaved   time.Time
+	knownMACs       map[string]bool
+	knownOrder      []string // knownMACs' keys, least recently seen first
+	lastScan        []network.NetworkDevice
+	lastScanAt      time.Time
+	wasHome         bool
+	atHome          bool      // Result of the last check's home detection
+	lastHomeAt      time.Time // Last check that found the home network
+	lastTrigger     time.Time // Last shutdown attempt or cancellation
+	pausedAway      bool
+	currentSSID     string
+	deviceID        string
+	StatusCallback  func(StatusChange)
 	cancelShutdown  chan struct{}
 	shutdownPending bool
 	mu              sync.Mutex
 	stateFile       string
+
+	startedAt       time.Time
+	pauseSeen       time.Time // Fallback pause start for settings without one
+	monitoringSince time.Time
+	homeSince       time.Time
+	shutdownCount   int
+	cancelCount     int
+	graceEntries    []time.Time // Recent grace period starts, for flapping alerts
+	soundPlaying    atomic.Bool
+
+	deps Dependencies
+	// onShutdown runs when the grace period expires; tests replace it to
+	// avoid the real countdown
+	onShutdown func(settings config.Settings, ssid string)
+	// askConfirmation shows the dialog of the confirmation delay; nil waits
+	// out the delay without one
+	askConfirmation func(ctx context.Context, message string, timeout time.Duration) <-chan bool
+	// lockSession locks the workstation when LockGraceChecks is reached; nil
+	// only logs it
+	lockSession func() error
 }
 
 type SentryState struct {
-	PhoneEverSeen bool `json:"phone_ever_seen"`
+	PhoneEverSeen bool        `json:"phone_ever_seen"`
+	LastSeen      time.Time   `json:"last_seen,omitempty"`
+	KnownMACs     []string    `json:"known_macs,omitempty"` // Oldest first
+	ShutdownCount int         `json:"shutdown_count,omitempty"`
+	CancelCount   int         `json:"cancel_count,omitempty"`
+	GraceEntries  []time.Time `json:"grace_entries,omitempty"`
+	LastTrigger   time.Time   `json:"last_trigger,omitempty"`
 }
 
+// networkSettleDelay is how long a check waits after a network change
+const networkSettleDelay = time.Second
+
+// lastSeenSaveInterval throttles how often the last-seen time is written to disk
+const lastSeenSaveInterval = time.Minute
+
 func NewSentryManager() *SentryManager {
+	return NewSentryManagerWithDependencies(DefaultDependencies())
+}
+
+// NewSentryManagerWithDependencies creates a manager that uses the given
+// presence, SSID and settings providers. Nil fields fall back to the defaults.
+func NewSentryManagerWithDependencies(deps Dependencies) *SentryManager {
+	defaults := DefaultDependencies()
+	if deps.Presence == nil {
+		deps.Presence = defaults.Presence
+	}
+	if deps.SSID == nil {
+		deps.SSID = defaults.SSID
+	}
+	if deps.Gateway == nil {
+		deps.Gateway = defaults.Gateway
+	}
+	if deps.Settings == nil {
+		deps.Settings = defaults.Settings
+	}
+	if deps.Peripherals == nil {
+		deps.Peripherals = defaults.Peripherals
+	}
+	if deps.Notifier == nil {
+		deps.Notifier = defaultNotifier(deps.Settings)
+	}
+
 	statePath := getStateFilePath()
 	sm := &SentryManager{
 		status:          StatusRoaming,
@@ -50,55 +142,116 @@ func NewSentryManager() *SentryManager {
 		phoneEverSeen:   false,
 		cancelShutdown:  make(chan struct{}),
 		shutdownPending: false,
+		knownMACs:       make(map[string]bool),
 		stateFile:       statePath,
+		startedAt:       time.Now(),
+		deps:            deps,
 	}
+	sm.onShutdown = sm.triggerShutdownWithCountdown
+	sm.askConfirmation = showConfirmDialog
+	sm.lockSession = lockSession
 	// Load persisted state
 	sm.loadState()
 	return sm
 }
 
 func getStateFilePath() string {
-	appData := os.Getenv("APPDATA")
-	if appData == "" {
-		return "sentry-state.json"
-	}
-	dir := filepath.Join(appData, "HomeSentry")
-	os.MkdirAll(dir, 0700)
-	return filepath.Join(dir, "sentry-state.json")
+	path := StateFilePath()
+	os.MkdirAll(filepath.Dir(path), 0700)
+	return path
+}
+
+// StateFilePath returns where the sentry state is persisted
+func StateFilePath() string {
+	return filepath.Join(paths.AppDir(), "sentry-state.json")
 }
 
 func (s *SentryManager) loadState() {
-	data, err := os.ReadFile(s.stateFile)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			logger.Info("Failed to load state file: %v", err)
-		}
+	state, signed, err := readStateFile(s.stateFile)
+	switch {
+	case os.IsNotExist(err):
+		return
+	case errors.Is(err, errStateTampered):
+		// Someone edited the file, possibly to disarm protection: start armed
+		logger.Warn("State file %s failed its integrity check, it may have been tampered with. Resetting and treating the phone as seen before.", s.stateFile)
+		s.phoneEverSeen = true
+		return
+	case err != nil:
+		// A corrupt, oversized or unverifiable file must not disarm
+		// protection any more than a tampered one
+		logger.Warn("Failed to load state file %s, resetting and treating the phone as seen before: %v", s.stateFile, err)
+		s.phoneEverSeen = true
 		return
 	}
 
-	// Validate file size to prevent memory exhaustion from corrupted files
-	const maxStateFileSize = 1024 // 1KB is more than enough for state
-	if len(data) > maxStateFileSize {
-		logger.Info("State file too large (%d bytes), ignoring and resetting to defaults", len(data))
-		return
+	// An unsigned file (written before signing, or without a key) is trusted
+	// for statistics, but can't be allowed to disarm protection
+	s.phoneEverSeen = state.PhoneEverSeen
+	if !signed && !state.PhoneEverSeen {
+		logger.Warn("State file is not signed, treating the phone as seen before")
+		s.phoneEverSeen = true
+	}
+	s.lastSeen = state.LastSeen
+	s.lastSeenSaved = state.LastSeen
+	if state.ShutdownCount > 0 {
+		s.shutdownCount = state.ShutdownCount
+	}
+	if state.CancelCount > 0 {
+		s.cancelCount = state.CancelCount
 	}
 
-	var state SentryState
-	if err := json.Unmarshal(data, &state); err != nil {
-		logger.Info("Failed to parse state file (may be corrupted), resetting to defaults: %v", err)
-		return
+	// Grace entries from the future can't be trusted, and the list never
+	// needs to be longer than the largest alert threshold
+	now := time.Now()
+	if !state.LastTrigger.After(now) {
+		s.lastTrigger = state.LastTrigger
+	}
+	for _, entry := range state.GraceEntries {
+		if !entry.After(now) {
+			s.graceEntries = append(s.graceEntries, entry)
+		}
+	}
+	if len(s.graceEntries) > config.MaxGraceAlertCount {
+		s.graceEntries = s.graceEntries[len(s.graceEntries)-config.MaxGraceAlertCount:]
 	}
 
-	// PhoneEverSeen is a bool, so json.Unmarshal handles type validation.
-	// If the JSON had a non-bool value for phone_ever_seen, Unmarshal would
-	// have returned an error above. The value is safe to use.
-	s.phoneEverSeen = state.PhoneEverSeen
-	logger.Info("Loaded state: phoneEverSeen=%v", s.phoneEverSeen)
+	// Known MACs come from disk, so validate each one
+	s.knownMACs = make(map[string]bool, len(state.KnownMACs))
+	s.knownOrder = nil
+	for _, mac := range state.KnownMACs {
+		sanitized, err := config.SanitizeMAC(mac)
+		if err != nil || sanitized == "" || s.knownMACs[sanitized] {
+			continue
+		}
+		s.rememberDevice(sanitized)
+	}
+	logger.Info("Loaded state: phoneEverSeen=%v, lastSeen=%v, knownDevices=%d", s.phoneEverSeen, s.lastSeen, len(s.knownMACs))
+}
+
+// SaveState persists the sentry state now, e.g. before the app exits
+func (s *SentryManager) SaveState() {
+	s.saveState()
 }
 
 func (s *SentryManager) saveState() {
-	state := SentryState{PhoneEverSeen: s.phoneEverSeen}
-	data, err := json.MarshalIndent(state, "", "  ")
+	if s.stateFile == "" {
+		return // In-memory manager (simulation)
+	}
+
+	s.mu.Lock()
+	state := SentryState{
+		PhoneEverSeen: s.phoneEverSeen,
+		LastSeen:      s.lastSeen,
+		KnownMACs:     append([]string(nil), s.knownOrder...),
+		ShutdownCount: s.shutdownCount,
+		CancelCount:   s.cancelCount,
+		GraceEntries:  append([]time.Time(nil), s.graceEntries...),
+		LastTrigger:   s.lastTrigger,
+	}
+	s.lastSeenSaved = s.lastSeen
+	s.mu.Unlock()
+
+	data, err := encodeState(state)
 	if err != nil {
 		logger.Info("Failed to marshal state: %v", err)
 		return
@@ -108,38 +261,71 @@ func (s *SentryManager) saveState() {
 	}
 }
 
-func (s *SentryManager) SetStatusCallback(cb func(SentryStatus)) {
+func (s *SentryManager) SetStatusCallback(cb func(StatusChange)) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.StatusCallback = cb
 }
 
+// setContext records the SSID and device identifier of the current check
+func (s *SentryManager) setContext(ssid, deviceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentSSID = ssid
+	s.deviceID = deviceID
+}
+
 func (s *SentryManager) setStatus(status SentryStatus) {
 	s.mu.Lock()
 	s.status = status
+	change := StatusChange{
+		Status:          status,
+		SSID:            s.currentSSID,
+		Device:          s.deviceID,
+		GraceCount:      s.graceCount,
+		ShutdownPending: s.shutdownPending,
+	}
 	cb := s.StatusCallback
 	s.mu.Unlock()
 
 	// Call callback outside lock to avoid deadlocks with UI code
 	if cb != nil {
-		cb(status)
+		cb(change)
 	}
 }
 
 // CancelShutdown cancels a pending shutdown if one is in progress
 func (s *SentryManager) CancelShutdown() bool {
+	s.mu.Lock()
+	if !s.shutdownPending {
+		s.mu.Unlock()
+		return false
+	}
+	close(s.cancelShutdown)
+	s.cancelShutdown = make(chan struct{}) // Reset for future use
+	s.shutdownPending = false
+	s.graceCount = 0
+	s.cancelCount++
+	s.lastTrigger = time.Now()
+	s.mu.Unlock()
+
+	logger.Audit("Shutdown cancelled by user")
+	s.saveState()
+	return true
+}
+
+// Status returns the current sentry status
+func (s *SentryManager) Status() SentryStatus {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.status
+}
 
-	if s.shutdownPending {
-		close(s.cancelShutdown)
-		s.cancelShutdown = make(chan struct{}) // Reset for future use
-		s.shutdownPending = false
-		s.graceCount = 0
-		logger.Info("Shutdown cancelled by user")
-		return true
-	}
-	return false
+// GraceCount returns how many consecutive checks have missed the phone
+func (s *SentryManager) GraceCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.graceCount
 }
 
 // IsShutdownPending returns true if a shutdown countdown is in progress
@@ -149,108 +335,550 @@ func (s *SentryManager) IsShutdownPending() bool {
 	return s.shutdownPending
 }
 
+// AtHome reports whether the last check found the home network, by SSID or
+// gateway as configured
+func (s *SentryManager) AtHome() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.atHome
+}
+
+// StartMonitor runs the checks forever, every poll interval and right after
+// the machine joins or leaves a network
 func (s *SentryManager) StartMonitor() {
 	logger.Info("Starting Sentry Monitor...")
+	changes := network.WatchNetworkChanges()
+	early := false
 	for {
-		settings, err := config.Load()
-		if err != nil {
-			logger.Info("Error loading settings: %v. Retrying in %ds...", err, settings.PollInterval)
-			time.Sleep(time.Duration(settings.PollInterval) * time.Second)
-			continue
+		s.mu.Lock()
+		s.earlyCheck = early
+		s.mu.Unlock()
+		_, wait := s.step()
+		if early = waitForNextCheck(wait, changes); early {
+			// The SSID can lag the address change slightly
+			time.Sleep(networkSettleDelay)
+			logger.Info("Network change detected, checking now")
 		}
+	}
+}
+
+// waitForNextCheck waits for the poll interval or a network change, whichever
+// comes first, and reports whether it was cut short by a change
+func waitForNextCheck(wait time.Duration, changes <-chan struct{}) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return false
+	case <-changes:
+		return true
+	}
+}
+
+// step performs one monitor check and returns the resulting status together
+// with how long to wait before the next check.
+func (s *SentryManager) step() (SentryStatus, time.Duration) {
+	settings, err := s.deps.Settings.Load()
+	wait := time.Duration(settings.PollInterval) * time.Second
+	if err != nil {
+		logger.Info("Error loading settings: %v. Retrying in %ds...", err, settings.PollInterval)
+		return s.Status(), wait
+	}
 
-		ssid := network.GetCurrentSSID()
+	ssid := s.deps.SSID.CurrentSSID()
+	// Switching profiles rewrites the settings file, which dry runs never do
+	if settings.AutoSwitchProfile && !s.deps.DryRun {
+		settings = s.switchProfile(settings, ssid)
+	}
+	s.setContext(ssid, settings.GetDeviceIdentifier())
 
-		if settings.IsPaused {
+	atHome := s.isHome(settings, ssid)
+	s.mu.Lock()
+	s.atHome = atHome
+	s.mu.Unlock()
+	if settings.IsPaused {
+		if !s.pauseExpired(settings, time.Now()) && !s.autoResume(settings, atHome) {
+			s.trackPeriods(atHome, false, time.Now())
 			logger.Info("Status: PAUSED. Protection disabled.")
 			s.setStatus(StatusPaused)
-			time.Sleep(time.Duration(settings.PollInterval) * time.Second)
-			continue
+			return StatusPaused, wait
 		}
+	} else {
+		// Only a roam that happens while paused counts towards auto-resume
+		s.mu.Lock()
+		s.pausedAway = false
+		s.pauseSeen = time.Time{}
+		s.mu.Unlock()
+	}
+	s.trackPeriods(atHome, true, time.Now())
+
+	if peripheral := s.dockedTo(settings); peripheral != "" {
+		s.mu.Lock()
+		s.graceCount = 0
+		s.mu.Unlock()
+		logger.Info("Status: DOCKED to %s. Treated as safe.", config.SanitizeDisplayString(peripheral))
+		s.setStatus(StatusDocked)
+		return StatusDocked, wait
+	}
+
+	// Sanitize SSID and MAC before logging to prevent format string injection
+	safeSSID := config.SanitizeDisplayString(ssid)
+	safeHomeSSID := config.SanitizeDisplayString(settings.HomeSSID)
+	safeMAC := config.SanitizeDisplayString(settings.PhoneMAC)
+	logger.Info("Monitor Check: Current SSID=%s, Home SSID=%s, MAC=%s", safeSSID, safeHomeSSID, safeMAC)
+
+	if atHome {
+		s.mu.Lock()
+		s.wasHome = true
+		s.lastHomeAt = time.Now()
+		s.mu.Unlock()
+
+		// At home, check for phone
+		if settings.HasDeviceConfigured() {
+			alive := s.deps.Presence.IsPresent(settings)
+			if alive {
+				logger.Info("Phone (MAC: %s) detected. Safe.", safeMAC)
+
+				now := time.Now()
+				s.mu.Lock()
+				recovered := s.graceCount > 0
+				s.graceCount = 0
+				everSeen := s.phoneEverSeen
+				if !everSeen {
+					s.phoneEverSeen = true
+				}
+				s.lastSeen = now
+				saveDue := now.Sub(s.lastSeenSaved) >= lastSeenSaveInterval
+				s.mu.Unlock()
+
+				s.setStatus(StatusMonitoring)
+				if recovered && settings.NotifyOnRecovery {
+					s.showNotification("Home Sentry", "Phone reconnected - protection resumed safely")
+				}
 
-		// Sanitize SSID and MAC before logging to prevent format string injection
-		safeSSID := config.SanitizeDisplayString(ssid)
-		safeHomeSSID := config.SanitizeDisplayString(settings.HomeSSID)
-		safeMAC := config.SanitizeDisplayString(settings.PhoneMAC)
-		logger.Info("Monitor Check: Current SSID=%s, Home SSID=%s, MAC=%s", safeSSID, safeHomeSSID, safeMAC)
-
-		if ssid == settings.HomeSSID {
-			// At home, check for phone
-			if settings.HasDeviceConfigured() {
-				alive := network.IsDeviceOnNetwork(settings.PhoneMAC)
-				if alive {
-					logger.Info("Phone (MAC: %s) detected. Safe.", safeMAC)
-					s.setStatus(StatusMonitoring)
-
-					s.mu.Lock()
-					s.graceCount = 0
-					everSeen := s.phoneEverSeen
-					if !everSeen {
-						s.phoneEverSeen = true
-					}
-					s.mu.Unlock()
-
-					if !everSeen {
-						s.saveState()
-						logger.Info("Phone first seen - state persisted")
-					}
-				} else {
-					logger.Info("WARNING: Phone (MAC: %s) NOT detected on home wifi!", safeMAC)
-
-					s.mu.Lock()
-					everSeen := s.phoneEverSeen
-					s.mu.Unlock()
-
-					// Only enter grace period if we've seen the phone before
-					if everSeen {
-						s.mu.Lock()
-						s.graceCount++
-						currentGrace := s.graceCount
-						s.mu.Unlock()
-
-						s.setStatus(StatusGracePeriod)
-						logger.Info("Status: GRACE PERIOD (%d/%d)", currentGrace, settings.GraceChecks)
-
-						if currentGrace >= settings.GraceChecks {
-							s.setStatus(StatusShutdownImminent)
-							logger.Info("CRITICAL: Grace period expired. SHUTDOWN IMMINENT!")
-							s.triggerShutdownWithCountdown(settings)
-						}
-					} else {
-						// Phone never seen yet, waiting for initial connection
-						logger.Info("Waiting for phone to be detected for the first time...")
-						s.setStatus(StatusWaitingForPhone)
-					}
+				if !everSeen {
+					s.saveState()
+					logger.Info("Phone first seen - state persisted")
+				} else if saveDue {
+					s.saveState()
 				}
 			} else {
-				logger.Info("No device configured. Monitoring disabled.")
-				s.setStatus(StatusRoaming)
+				logger.Info("WARNING: Phone (MAC: %s) NOT detected on home wifi!", safeMAC)
+				s.handlePhoneMissing(settings, ssid)
 			}
 		} else {
+			logger.Info("No device %s configured for %s detection. Monitoring disabled.",
+				strings.ToUpper(string(settings.DetectionType)), settings.DetectionType)
 			s.setStatus(StatusRoaming)
-			s.mu.Lock()
-			s.graceCount = 0
-			s.mu.Unlock()
-			logger.Info("Status: Roaming (Not on Home WiFi).")
 		}
+	} else if held := s.homeHeld(settings, ssid, time.Now()); held > 0 {
+		logger.Info("WiFi unknown - holding home state for up to %s more", held.Round(time.Second))
+	} else if ssid == network.UnknownSSID && settings.HomeConfigured() {
+		s.handleNoNetwork(settings, ssid)
+	} else {
+		s.mu.Lock()
+		s.graceCount = 0
+		s.wasHome = false
+		s.mu.Unlock()
+		s.setStatus(StatusRoaming)
+		logger.Info("Status: Roaming (Not on Home WiFi).")
+	}
+
+	return s.Status(), wait
+}
+
+// switchProfile activates the profile whose home SSID is ssid, if it isn't
+// active already, and returns the settings to check with
+func (s *SentryManager) switchProfile(settings config.Settings, ssid string) config.Settings {
+	name := settings.ProfileForSSID(ssid)
+	if name == "" || name == settings.ActiveProfile {
+		return settings
+	}
+	if err := config.UseProfile(name); err != nil {
+		logger.Error("Failed to switch to profile %s: %v", name, err)
+		return settings
+	}
+	switched, err := s.deps.Settings.Load()
+	if err != nil {
+		logger.Error("Failed to load profile %s: %v", name, err)
+		return settings
+	}
+
+	// Misses counted against the previous profile's device don't carry over
+	s.mu.Lock()
+	s.graceCount = 0
+	s.mu.Unlock()
+
+	logger.Info("On %s - switched to profile %s", config.SanitizeDisplayString(ssid), name)
+	s.showNotification("Home Sentry", fmt.Sprintf("Switched to profile %s", config.SanitizeDisplayString(name)))
+	return switched
+}
+
+// isHome reports whether the machine is on the home network, recognized by
+// SSID or by the default gateway's MAC depending on HomeDetection
+func (s *SentryManager) isHome(settings config.Settings, ssid string) bool {
+	if settings.HomeDetection != config.HomeDetectionGateway {
+		return settings.HomeSSID != "" && ssid == settings.HomeSSID
+	}
+	if settings.HomeGatewayMAC == "" {
+		return false
+	}
+	mac, err := config.SanitizeMAC(s.deps.Gateway.GatewayMAC())
+	return err == nil && mac == settings.HomeGatewayMAC
+}
+
+// autoResume tracks whether the laptop left home while paused and, when
+// AutoResumeOnHome is enabled, resumes protection once it is back on the home
+// network. It reports whether protection was resumed.
+func (s *SentryManager) autoResume(settings config.Settings, atHome bool) bool {
+	if !settings.HomeConfigured() {
+		return false
+	}
+
+	s.mu.Lock()
+	if !atHome {
+		s.pausedAway = true
+		s.mu.Unlock()
+		return false
+	}
+	returned := s.pausedAway
+	s.mu.Unlock()
+
+	// Dry runs leave resuming to the tray app, which owns the settings
+	if !returned || !settings.AutoResumeOnHome || s.deps.DryRun {
+		return false
+	}
+
+	if err := config.SetPaused(false); err != nil {
+		logger.Error("Failed to auto-resume protection: %v", err)
+		return false
+	}
+
+	s.mu.Lock()
+	s.pausedAway = false
+	s.mu.Unlock()
+
+	logger.Audit("Back on home WiFi - protection resumed automatically")
+	s.showNotification("Home Sentry", "Welcome home - protection resumed automatically")
+	return true
+}
+
+// pauseExpired resumes protection once a timed pause is over or the pause
+// has outlasted MaxPauseMinutes. Pauses saved without a start time are
+// capped from when the monitor first saw them. It reports whether
+// protection was resumed.
+func (s *SentryManager) pauseExpired(settings config.Settings, now time.Time) bool {
+	ends := settings.PauseEnds()
+	if maxPause := settings.MaxPause(); maxPause > 0 && settings.PausedAt.IsZero() {
+		s.mu.Lock()
+		if s.pauseSeen.IsZero() {
+			s.pauseSeen = now
+		}
+		capped := s.pauseSeen.Add(maxPause)
+		s.mu.Unlock()
+		if ends.IsZero() || capped.Before(ends) {
+			ends = capped
+		}
+	}
+	if ends.IsZero() || now.Before(ends) || s.deps.DryRun {
+		return false
+	}
+
+	if err := config.SetPaused(false); err != nil {
+		logger.Error("Failed to end expired pause: %v", err)
+		return false
+	}
+
+	s.mu.Lock()
+	s.pausedAway = false
+	s.pauseSeen = time.Time{}
+	s.mu.Unlock()
+
+	logger.Audit("Pause ended - protection resumed automatically")
+	s.showNotification("Home Sentry", "Pause ended - protection resumed automatically")
+	return true
+}
+
+// handlePhoneMissing advances the grace period when the phone is not detected
+// and triggers the shutdown countdown once it expires.
+func (s *SentryManager) handlePhoneMissing(settings config.Settings, ssid string) {
+	s.mu.Lock()
+	everSeen := s.phoneEverSeen
+	lastSeen := s.lastSeen
+	s.mu.Unlock()
+
+	// Only enter grace period if we've seen the phone before
+	if !everSeen {
+		// Phone never seen yet, waiting for initial connection
+		logger.Info("Waiting for phone to be detected for the first time...")
+		s.setStatus(StatusWaitingForPhone)
+		return
+	}
+
+	// A sighting that is too old (e.g. during setup at another location)
+	// must not arm protection
+	if maxAge := settings.MaxSeenAge(); maxAge > 0 && time.Since(lastSeen) > maxAge {
+		if lastSeen.IsZero() {
+			logger.Info("Phone last-seen time unknown - waiting for phone to be detected again...")
+		} else {
+			logger.Info("Phone last seen %s ago (max %s) - waiting for phone to be detected again...",
+				time.Since(lastSeen).Round(time.Second), maxAge)
+		}
+		s.setStatus(StatusWaitingForPhone)
+		return
+	}
+
+	s.mu.Lock()
+	// Only checks on the poll interval advance a running grace period, so
+	// WiFi flapping as the user leaves can't use it up in seconds
+	if s.earlyCheck && s.graceCount > 0 && s.graceCount < settings.GraceChecks {
+		currentGrace := s.graceCount
+		s.mu.Unlock()
+		s.setStatus(StatusGracePeriod)
+		logger.Info("Status: GRACE PERIOD (%d/%d), early check not counted", currentGrace, settings.GraceChecks)
+		return
+	}
+	s.graceCount++
+	currentGrace := s.graceCount
+	if currentGrace == 1 {
+		s.missingSince = time.Now()
+	}
+	s.mu.Unlock()
+
+	s.setStatus(StatusGracePeriod)
+	logger.Info("Status: GRACE PERIOD (%d/%d)", currentGrace, settings.GraceChecks)
+	if currentGrace == 1 {
+		s.recordGraceEntry(settings, time.Now())
+		// With a single grace check the shutdown warning follows right away
+		if settings.NotifyOnGrace && currentGrace < settings.GraceChecks {
+			s.showNotification("Home Sentry", fmt.Sprintf("Phone not detected - shutting down in about %s unless it returns",
+				formatAbsence(timeToShutdown(settings, currentGrace))))
+		}
+	}
+
+	if currentGrace == settings.LockGraceChecks && currentGrace < settings.GraceChecks {
+		s.lockEarly(settings, currentGrace)
+	}
+
+	if currentGrace >= settings.GraceChecks {
+		if left := s.cooldownLeft(settings, time.Now()); left > 0 {
+			s.holdForCooldown(left)
+			return
+		}
+		s.mu.Lock()
+		s.lastTrigger = time.Now()
+		s.mu.Unlock()
+		s.saveState()
+
+		s.setStatus(StatusShutdownImminent)
+		logger.Info("CRITICAL: Grace period expired. SHUTDOWN IMMINENT!")
+		s.onShutdown(settings, ssid)
+	}
+}
+
+// lockEarly locks the session partway through the grace period: a
+// recoverable first step while the phone may still come back before the
+// shutdown action, which loses work. Dry runs only log it.
+func (s *SentryManager) lockEarly(settings config.Settings, graceCount int) {
+	if settings.ShutdownAction == config.ShutdownActionLock {
+		return // Locking is already the final action
+	}
+	logger.Audit("Phone missing for %d checks - locking the session, %s in about %s unless it returns",
+		graceCount, settings.ShutdownAction, formatAbsence(timeToShutdown(settings, graceCount)))
+	if s.deps.DryRun || s.lockSession == nil {
+		logger.Info("Dry run - would lock the session now")
+		return
+	}
+	if err := s.lockSession(); err != nil {
+		logger.Error("Failed to lock the session: %v", err)
+	}
+}
+
+// lockSession locks the workstation. Elsewhere it only logs.
+func lockSession() error {
+	if runtime.GOOS != "windows" {
+		logger.Info("Lock simulation (Non-Windows OS)")
+		return nil
+	}
+	return runShutdownAction(config.ShutdownActionLock)
+}
+
+// timeToShutdown estimates how long after the graceCount-th missed check the
+// shutdown action runs: the remaining grace checks, the confirmation delay
+// and the countdown
+func timeToShutdown(settings config.Settings, graceCount int) time.Duration {
+	checks := settings.GraceChecks - graceCount
+	if checks < 0 {
+		checks = 0
+	}
+	return time.Duration(checks*settings.PollInterval+settings.ConfirmationDelaySec+settings.ShutdownDelay) * time.Second
+}
+
+// dockedTo returns the attached peripheral that matches DisarmWhenDockedTo,
+// or "" if there is none
+func (s *SentryManager) dockedTo(settings config.Settings) string {
+	if len(settings.DisarmWhenDockedTo) == 0 || s.deps.Peripherals == nil {
+		return ""
+	}
+	return dock.Match(s.deps.Peripherals.Peripherals(), settings.DisarmWhenDockedTo)
+}
+
+// cooldownLeft returns how much of ShutdownCooldown is left since the last
+// shutdown attempt or cancellation
+func (s *SentryManager) cooldownLeft(settings config.Settings, now time.Time) time.Duration {
+	s.mu.Lock()
+	last := s.lastTrigger
+	s.mu.Unlock()
+
+	cooldown := settings.ShutdownCooldown()
+	if cooldown <= 0 || last.IsZero() {
+		return 0
+	}
+	if left := cooldown - now.Sub(last); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// holdForCooldown keeps the grace period expired without starting another
+// countdown. The user is alerted once per cooldown; the countdown starts on
+// the first check after it ends if the phone is still missing.
+func (s *SentryManager) holdForCooldown(left time.Duration) {
+	entering := s.Status() != StatusCooldown
+	s.setStatus(StatusCooldown)
+	logger.Info("Status: COOLDOWN. Grace period expired, shutdown held for %s more", left.Round(time.Second))
+	if entering {
+		s.showNotification("Home Sentry Alert", fmt.Sprintf("Phone still not detected! A shutdown ran recently, the next one is held for %s.", formatAbsence(left)))
+	}
+}
+
+// recordGraceEntry remembers the start of a grace period and warns once
+// GraceAlertCount of them fall within GraceAlertWindow. Each one recovering on
+// its own still points at a flaky device or someone interfering. It reports
+// whether the alert was shown.
+func (s *SentryManager) recordGraceEntry(settings config.Settings, now time.Time) bool {
+	if settings.GraceAlertCount <= 0 {
+		return false
+	}
+	window := settings.GraceAlertPeriod()
+
+	s.mu.Lock()
+	recent := s.graceEntries[:0]
+	for _, entry := range s.graceEntries {
+		if now.Sub(entry) < window {
+			recent = append(recent, entry)
+		}
+	}
+	recent = append(recent, now)
+	if len(recent) > config.MaxGraceAlertCount {
+		recent = recent[len(recent)-config.MaxGraceAlertCount:]
+	}
+	count := len(recent)
+	flapping := count >= settings.GraceAlertCount
+	if flapping {
+		// Start counting afresh so the alert isn't repeated on every grace period
+		recent = nil
+	}
+	s.graceEntries = recent
+	s.mu.Unlock()
+	s.saveState()
+
+	if !flapping {
+		return false
+	}
+	logger.Warn("%d grace periods within %s - protection is flapping", count, window)
+	s.showNotification("Home Sentry - Protection Flapping",
+		fmt.Sprintf("%d grace periods in the last %s. Check the phone's WiFi or increase the grace checks.", count, formatWindow(window)))
+	return true
+}
+
+// formatWindow renders a whole-minute window without trailing zero units
+func formatWindow(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
+
+// absentFor returns how long the phone has been missing in the current grace
+// period, or 0 outside one
+func (s *SentryManager) absentFor(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.graceCount == 0 || s.missingSince.IsZero() {
+		return 0
+	}
+	return now.Sub(s.missingSince)
+}
+
+// formatAbsence renders an absence to the second, e.g. "47s" or "2m5s"
+func formatAbsence(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// homeHeld returns how much longer an unknown SSID right after being home is
+// still treated as home, leaving the status untouched, or 0 once
+// HoldLastHomeSec has run out. It smooths over adapter scan glitches.
+func (s *SentryManager) homeHeld(settings config.Settings, ssid string, now time.Time) time.Duration {
+	if ssid != network.UnknownSSID || settings.HoldLastHomeSec <= 0 {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.wasHome || s.lastHomeAt.IsZero() {
+		return 0
+	}
+	left := time.Duration(settings.HoldLastHomeSec)*time.Second - now.Sub(s.lastHomeAt)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// handleNoNetwork deals with the WiFi adapter being disabled or missing.
+// Losing WiFi while at home is exactly what a thief might cause, so it can
+// optionally count as the phone being missing instead of disarming.
+func (s *SentryManager) handleNoNetwork(settings config.Settings, ssid string) {
+	s.mu.Lock()
+	wasHome := s.wasHome
+	s.mu.Unlock()
 
-		time.Sleep(time.Duration(settings.PollInterval) * time.Second)
+	if wasHome && settings.TreatNoWifiAsAway && settings.HasDeviceConfigured() {
+		logger.Info("WARNING: WiFi lost while at home - treating phone as away")
+		s.handlePhoneMissing(settings, ssid)
+		return
 	}
+
+	// Grace count is deliberately kept: disabling WiFi must not wipe an
+	// in-progress grace period.
+	logger.Info("Status: NO NETWORK (WiFi disabled or missing).")
+	s.setStatus(StatusNoNetwork)
 }
 
-func (s *SentryManager) triggerShutdownWithCountdown(settings config.Settings) {
+func (s *SentryManager) triggerShutdownWithCountdown(settings config.Settings, ssid string) {
 	s.mu.Lock()
 	s.shutdownPending = true
+	cancelled := s.cancelShutdown // CancelShutdown replaces the field after closing it
 	s.mu.Unlock()
 
-	// Show local notification
-	s.showNotification("Home Sentry Alert", fmt.Sprintf("Phone not detected! Shutting down in %d seconds...", settings.ShutdownDelay))
+	absent := formatAbsence(s.absentFor(time.Now()))
+	logger.Info("Phone absent for %s", absent)
+
+	if delay := settings.ConfirmationDelay(); delay > 0 && !s.awaitConfirmation(delay, absent, cancelled) {
+		return
+	}
+
+	// Local alert, and a last-chance one through the user's own relay (SMS,
+	// email, ...)
+	s.notify(criticalEvent(settings, "Home Sentry Alert",
+		fmt.Sprintf("Phone not detected for %s! Shutting down in %d seconds...", absent, settings.ShutdownDelay),
+		settings.CriticalWebhookPayloadTemplate(), config.WebhookStatusShuttingDown, ssid, absent))
 
 	// Play initial warning sound
-	s.playWarningSound()
+	s.playWarningSound(settings)
 
 	// Shutdown countdown with cancel option and periodic beeps
-	logger.Info("Starting %d second shutdown countdown...", settings.ShutdownDelay)
+	logger.Audit("Starting %d second shutdown countdown...", settings.ShutdownDelay)
 
 	// Timer for the total countdown
 	shutdownTimer := time.NewTimer(time.Duration(settings.ShutdownDelay) * time.Second)
@@ -265,7 +893,7 @@ func (s *SentryManager) triggerShutdownWithCountdown(settings config.Settings) {
 		select {
 		case <-beepTicker.C:
 			if countdown > 0 {
-				s.playWarningSound()
+				s.playWarningSound(settings)
 				logger.Info("Shutdown in %d seconds...", countdown)
 				countdown -= 2
 			}
@@ -276,7 +904,7 @@ func (s *SentryManager) triggerShutdownWithCountdown(settings config.Settings) {
 			s.mu.Unlock()
 			s.executeShutdown(settings)
 			return
-		case <-s.cancelShutdown:
+		case <-cancelled:
 			// Shutdown was cancelled locally
 			logger.Info("Shutdown countdown cancelled (local)")
 			s.setStatus(StatusMonitoring)
@@ -285,86 +913,116 @@ func (s *SentryManager) triggerShutdownWithCountdown(settings config.Settings) {
 	}
 }
 
-// playWarningSound plays a system warning beep
-func (s *SentryManager) playWarningSound() {
-	if runtime.GOOS == "windows" {
-		cmd := exec.Command("powershell", "-WindowStyle", "Hidden", "-Command",
-			"[console]::beep(1000, 300)")
-		network.HideConsole(cmd)
-		go cmd.Run()
+// playWarningSound plays the configured warning sound, or a system beep when
+// none is set. Nothing is played in silent mode or during quiet hours.
+func (s *SentryManager) playWarningSound(settings config.Settings) {
+	if settings.SilentMode || settings.InQuietHours(time.Now()) || runtime.GOOS != "windows" {
+		return
 	}
-}
 
-// escapePowerShellString escapes a string for safe use inside single-quoted PowerShell strings.
-// Handles single quotes, null bytes, backticks (escape char), and newlines.
-func escapePowerShellString(s string) string {
-	// In PowerShell, single quotes are escaped by doubling them
-	s = strings.ReplaceAll(s, "'", "''")
-	// Remove null bytes for safety
-	s = strings.ReplaceAll(s, "\x00", "")
-	// Remove backticks (PowerShell escape character)
-	s = strings.ReplaceAll(s, "`", "")
-	// Remove newlines/carriage returns that could break the script structure
-	s = strings.ReplaceAll(s, "\n", " ")
-	s = strings.ReplaceAll(s, "\r", "")
-	// Truncate to prevent buffer abuse
-	const maxPSStringLen = 256
-	if len(s) > maxPSStringLen {
-		s = s[:maxPSStringLen]
+	path := settings.WarningSoundPath()
+	if path == "" {
+		beep()
+		return
+	}
+	// The countdown asks for a sound every 2 seconds; a longer file is left
+	// to finish instead of being started again on top of itself
+	if !s.soundPlaying.CompareAndSwap(false, true) {
+		return
 	}
-	return s
+	// The path is passed through the environment so it is never parsed as script
+	cmd := exec.Command("powershell", "-WindowStyle", "Hidden", "-Command",
+		"(New-Object System.Media.SoundPlayer $env:HOME_SENTRY_SOUND).PlaySync()")
+	cmd.Env = append(os.Environ(), "HOME_SENTRY_SOUND="+path)
+	network.HideConsole(cmd)
+	go func() {
+		defer s.soundPlaying.Store(false)
+		if err := cmd.Run(); err != nil {
+			logger.Info("Failed to play warning sound, using the beep: %v", err)
+			beep()
+		}
+	}()
+}
+
+// beep plays a short system warning beep
+func beep() {
+	cmd := exec.Command("powershell", "-WindowStyle", "Hidden", "-Command",
+		"[console]::beep(1000, 300)")
+	network.HideConsole(cmd)
+	go cmd.Run()
+}
+
+// Notify shows a desktop notification on behalf of other parts of the app
+func (s *SentryManager) Notify(title, message string) {
+	s.showNotification(title, message)
 }
 
+// showNotification sends an informational alert to the notifiers
 func (s *SentryManager) showNotification(title, message string) {
-	if runtime.GOOS == "windows" {
-		// Escape inputs to prevent PowerShell injection
-		safeTitle := escapePowerShellString(title)
-		safeMessage := escapePowerShellString(message)
-
-		// Use PowerShell for toast notification
-		script := fmt.Sprintf(`
-			Add-Type -AssemblyName System.Windows.Forms
-			$balloon = New-Object System.Windows.Forms.NotifyIcon
-			$balloon.Icon = [System.Drawing.SystemIcons]::Warning
-			$balloon.BalloonTipIcon = [System.Windows.Forms.ToolTipIcon]::Warning
-			$balloon.BalloonTipTitle = '%s'
-			$balloon.BalloonTipText = '%s'
-			$balloon.Visible = $true
-			$balloon.ShowBalloonTip(10000)
-			Start-Sleep -Seconds 10
-			$balloon.Dispose()
-		`, safeTitle, safeMessage)
-		cmd := exec.Command("powershell", "-WindowStyle", "Hidden", "-Command", script)
-		network.HideConsole(cmd)
-		go cmd.Run() // Run async
+	s.notify(notify.Event{Level: notify.Info, Title: title, Message: message})
+}
+
+// criticalEvent builds a shutdown alert whose webhook payload is rendered
+// from template; status is one of the config.WebhookStatus values
+func criticalEvent(settings config.Settings, title, message, template, status, ssid, absent string) notify.Event {
+	return notify.Event{
+		Level:    notify.Critical,
+		Title:    title,
+		Message:  message,
+		Template: template,
+		Fields: map[string]string{
+			"delay":    strconv.Itoa(settings.ShutdownDelay),
+			"ssid":     config.RemoveControlChars(ssid),
+			"device":   config.RemoveControlChars(settings.GetDeviceIdentifier()),
+			"hostname": config.RemoveControlChars(settings.MachineName()),
+			"absent":   absent,
+			"action":   settings.ShutdownAction,
+			"status":   status,
+		},
+	}
+}
+
+// notify hands an event to the notifiers in the background, so a slow
+// backend never delays a check or the countdown. Dry runs keep alerts local.
+func (s *SentryManager) notify(event notify.Event) {
+	notifier := s.deps.Notifier
+	if notifier == nil {
+		return
+	}
+	if s.deps.DryRun {
+		event.Template = ""
 	}
+	go func() {
+		if err := notifier.Notify(event); err != nil {
+			logger.Error("Failed to send notification %q: %v", event.Title, err)
+		}
+	}()
 }
 
 func (s *SentryManager) executeShutdown(settings config.Settings) {
+	// Persist the count now; the machine may be gone before the next save
+	s.mu.Lock()
+	s.shutdownCount++
+	s.mu.Unlock()
+	s.saveState()
+
+	if s.deps.DryRun {
+		logger.Info("Dry run - would execute %s now", settings.ShutdownAction)
+		return
+	}
+
 	if runtime.GOOS != "windows" {
 		logger.Info("Shutdown simulation (Non-Windows OS) - action: %s", settings.ShutdownAction)
 		return
 	}
 
-	logger.Info("Executing %s command...", settings.ShutdownAction)
-
-	var cmd *exec.Cmd
-	switch settings.ShutdownAction {
-	case config.ShutdownActionShutdown:
-		cmd = exec.Command("shutdown", "/s", "/t", "0")
-	case config.ShutdownActionHibernate:
-		cmd = exec.Command("rundll32.exe", "powrprof.dll,SetSuspendState", "1,1,0")
-	case config.ShutdownActionSleep:
-		cmd = exec.Command("rundll32.exe", "powrprof.dll,SetSuspendState", "0,1,0")
-	case config.ShutdownActionLock:
-		cmd = exec.Command("rundll32.exe", "user32.dll,LockWorkStation")
-	default:
-		cmd = exec.Command("shutdown", "/s", "/t", "0")
-	}
+	runPreShutdownCommand(settings)
 
-	network.HideConsole(cmd)
-	err := cmd.Run()
+	logger.Audit("Executing %s command...", settings.ShutdownAction)
+	started := time.Now()
+	err := runShutdownAction(settings.ShutdownAction)
 	if err != nil {
-		logger.Info("Failed to execute %s: %v", settings.ShutdownAction, err)
+		logger.Error("Failed to execute %s: %v", settings.ShutdownAction, err)
 	}
+	s.verifyShutdown(settings, err, started)
 }
@@ -1,6 +1,15 @@
 package sentry
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/network"
+	"home-sentry/pkg/notify"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,23 +34,69 @@ func TestNewSentryManager(t *testing.T) {
 func TestSetStatus(t *testing.T) {
 	sm := NewSentryManager()
 
-	var callbackStatus SentryStatus
-	sm.SetStatusCallback(func(status SentryStatus) {
-		callbackStatus = status
+	var callbackChange StatusChange
+	sm.SetStatusCallback(func(change StatusChange) {
+		callbackChange = change
 	})
 
+	sm.setContext("HomeWiFi", "aa-bb-cc-dd-ee-ff")
+	sm.mu.Lock()
+	sm.graceCount = 2
+	sm.mu.Unlock()
+
 	sm.setStatus(StatusMonitoring)
 
 	if sm.status != StatusMonitoring {
 		t.Errorf("Status = %v, want %v", sm.status, StatusMonitoring)
 	}
-	if callbackStatus != StatusMonitoring {
-		t.Errorf("Callback received status = %v, want %v", callbackStatus, StatusMonitoring)
+	if callbackChange.Status != StatusMonitoring {
+		t.Errorf("Callback received status = %v, want %v", callbackChange.Status, StatusMonitoring)
+	}
+	if callbackChange.SSID != "HomeWiFi" {
+		t.Errorf("Callback received SSID = %q, want %q", callbackChange.SSID, "HomeWiFi")
+	}
+	if callbackChange.Device != "aa-bb-cc-dd-ee-ff" {
+		t.Errorf("Callback received Device = %q, want %q", callbackChange.Device, "aa-bb-cc-dd-ee-ff")
+	}
+	if callbackChange.GraceCount != 2 {
+		t.Errorf("Callback received GraceCount = %d, want 2", callbackChange.GraceCount)
+	}
+	if callbackChange.ShutdownPending {
+		t.Error("Callback received ShutdownPending = true, want false")
+	}
+}
+
+func TestStatusGetters(t *testing.T) {
+	sm := &SentryManager{status: StatusRoaming}
+
+	// Readers called from the callback must not deadlock
+	var seen SentryStatus
+	var seenGrace int
+	sm.SetStatusCallback(func(change StatusChange) {
+		seen = sm.Status()
+		seenGrace = sm.GraceCount()
+	})
+
+	sm.mu.Lock()
+	sm.graceCount = 3
+	sm.mu.Unlock()
+	sm.setStatus(StatusGracePeriod)
+
+	if sm.Status() != StatusGracePeriod {
+		t.Errorf("Status() = %v, want %v", sm.Status(), StatusGracePeriod)
+	}
+	if sm.GraceCount() != 3 {
+		t.Errorf("GraceCount() = %d, want 3", sm.GraceCount())
+	}
+	if seen != StatusGracePeriod || seenGrace != 3 {
+		t.Errorf("Callback read status=%v grace=%d, want %v and 3", seen, seenGrace, StatusGracePeriod)
 	}
 }
 
 func TestCancelShutdown(t *testing.T) {
 	sm := NewSentryManager()
+	// Cancelling persists the cancel count
+	sm.stateFile = filepath.Join(t.TempDir(), "sentry-state.json")
 
 	// Test cancel when no shutdown pending
 	result := sm.CancelShutdown()
@@ -97,6 +152,8 @@ func TestStatusConstants(t *testing.T) {
 		StatusShutdownImminent,
 		StatusPaused,
 		StatusWaitingForPhone,
+		StatusNoNetwork,
+		StatusCooldown,
 	}
 
 	seen := make(map[SentryStatus]bool)
@@ -107,3 +164,1062 @@ func TestStatusConstants(t *testing.T) {
 		seen[s] = true
 	}
 }
+
+func TestHandlePhoneMissingStaleSighting(t *testing.T) {
+	settings := config.DefaultSettings()
+	settings.MaxSeenAgeHours = 24
+
+	t.Run("stale sighting waits for phone", func(t *testing.T) {
+		sm := NewSentryManager()
+		sm.phoneEverSeen = true
+		sm.lastSeen = time.Now().Add(-48 * time.Hour)
+
+		sm.handlePhoneMissing(settings, "HomeWiFi")
+
+		if sm.status != StatusWaitingForPhone {
+			t.Errorf("Status = %v, want %v", sm.status, StatusWaitingForPhone)
+		}
+		if sm.graceCount != 0 {
+			t.Errorf("graceCount = %d, want 0", sm.graceCount)
+		}
+	})
+
+	t.Run("recent sighting enters grace", func(t *testing.T) {
+		sm := NewSentryManager()
+		sm.phoneEverSeen = true
+		sm.lastSeen = time.Now().Add(-time.Hour)
+
+		sm.handlePhoneMissing(settings, "HomeWiFi")
+
+		if sm.status != StatusGracePeriod {
+			t.Errorf("Status = %v, want %v", sm.status, StatusGracePeriod)
+		}
+		if sm.graceCount != 1 {
+			t.Errorf("graceCount = %d, want 1", sm.graceCount)
+		}
+	})
+}
+
+func TestSaveLoadState(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "sentry-state.json")
+	seen := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	sm := &SentryManager{stateFile: statePath, phoneEverSeen: true, lastSeen: seen}
+	sm.saveState()
+
+	loaded := &SentryManager{stateFile: statePath}
+	loaded.loadState()
+
+	if !loaded.phoneEverSeen {
+		t.Error("Loaded phoneEverSeen = false, want true")
+	}
+	if !loaded.lastSeen.Equal(seen) {
+		t.Errorf("Loaded lastSeen = %v, want %v", loaded.lastSeen, seen)
+	}
+}
+
+func TestStateFileSignature(t *testing.T) {
+	key := []byte("test key")
+	orig := stateKey
+	stateKey = func() ([]byte, error) { return key, nil }
+	t.Cleanup(func() { stateKey = orig })
+
+	statePath := filepath.Join(t.TempDir(), "sentry-state.json")
+	sm := &SentryManager{stateFile: statePath, phoneEverSeen: false, shutdownCount: 3}
+	sm.saveState()
+
+	loaded := &SentryManager{stateFile: statePath}
+	loaded.loadState()
+	if loaded.phoneEverSeen || loaded.shutdownCount != 3 {
+		t.Errorf("Signed state loaded as phoneEverSeen=%v shutdownCount=%d, want false 3", loaded.phoneEverSeen, loaded.shutdownCount)
+	}
+
+	// Editing the signed state is detected, and protection starts armed
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(data), `"shutdown_count": 3`, `"shutdown_count": 0`, 1)
+	if tampered == string(data) {
+		t.Fatalf("State file has an unexpected layout: %s", data)
+	}
+	if err := os.WriteFile(statePath, []byte(tampered), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded = &SentryManager{stateFile: statePath}
+	loaded.loadState()
+	if !loaded.phoneEverSeen || loaded.shutdownCount != 0 {
+		t.Errorf("Tampered state loaded as phoneEverSeen=%v shutdownCount=%d, want true 0", loaded.phoneEverSeen, loaded.shutdownCount)
+	}
+	if _, _, err := readStateFile(statePath); !errors.Is(err, errStateTampered) {
+		t.Errorf("Reading tampered state error = %v, want %v", err, errStateTampered)
+	}
+
+	// An unsigned version 1 file can't disarm protection either
+	if err := os.WriteFile(statePath, []byte(`{"phone_ever_seen": false, "shutdown_count": 2}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded = &SentryManager{stateFile: statePath}
+	loaded.loadState()
+	if !loaded.phoneEverSeen || loaded.shutdownCount != 2 {
+		t.Errorf("Unsigned state loaded as phoneEverSeen=%v shutdownCount=%d, want true 2", loaded.phoneEverSeen, loaded.shutdownCount)
+	}
+
+	// Neither can a file that does not load at all
+	unreadable := map[string]string{
+		"corrupt":       `{"phone_ever_seen": fal`,
+		"newer version": `{"version": 99, "state": {"phone_ever_seen": false}}`,
+	}
+	for name, content := range unreadable {
+		if err := os.WriteFile(statePath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		loaded = &SentryManager{stateFile: statePath}
+		loaded.loadState()
+		if !loaded.phoneEverSeen {
+			t.Errorf("%s state file loaded as phoneEverSeen=false, want true", name)
+		}
+	}
+}
+
+func TestObserveDevices(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "sentry-state.json")
+	sm := &SentryManager{stateFile: statePath}
+
+	initial := []network.NetworkDevice{
+		{IP: "192.168.1.10", MAC: "aa-bb-cc-dd-ee-01"},
+		{IP: "192.168.1.11", MAC: "aa-bb-cc-dd-ee-02"},
+	}
+	if got := sm.ObserveDevices(initial, false); len(got) != 0 {
+		t.Errorf("First scan should only seed known devices, got %d new", len(got))
+	}
+
+	next := append(initial, network.NetworkDevice{IP: "192.168.1.12", MAC: "AA:BB:CC:DD:EE:03"})
+	got := sm.ObserveDevices(next, false)
+	if len(got) != 1 || got[0].IP != "192.168.1.12" {
+		t.Fatalf("Expected one new device, got %+v", got)
+	}
+	if got := sm.ObserveDevices(next, false); len(got) != 0 {
+		t.Errorf("Known devices reported as new again: %+v", got)
+	}
+
+	loaded := &SentryManager{stateFile: statePath}
+	loaded.loadState()
+	if len(loaded.knownMACs) != 3 {
+		t.Errorf("Loaded %d known MACs, want 3", len(loaded.knownMACs))
+	}
+}
+
+func TestKnownDevicesCapped(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "sentry-state.json")
+	sm := &SentryManager{stateFile: statePath}
+
+	devices := make([]network.NetworkDevice, config.MaxKnownDevices+2)
+	for i := range devices {
+		devices[i].MAC = fmt.Sprintf("02-00-00-00-%02x-%02x", i/256, i%256)
+	}
+	sm.ObserveDevices(devices[:config.MaxKnownDevices], false)
+	sm.ObserveDevices(devices, false)
+
+	if len(sm.knownMACs) != config.MaxKnownDevices {
+		t.Fatalf("Known %d devices, want the cap of %d", len(sm.knownMACs), config.MaxKnownDevices)
+	}
+	for _, forgotten := range []string{devices[0].MAC, devices[1].MAC} {
+		if sm.knownMACs[forgotten] {
+			t.Errorf("Oldest device %s still known, want it evicted", forgotten)
+		}
+	}
+	if newest := devices[len(devices)-1].MAC; !sm.knownMACs[newest] {
+		t.Errorf("Newest device %s not known", newest)
+	}
+
+	// A device seen in every scan is never the one evicted
+	resident := devices[2].MAC
+	for i := 0; i < 3; i++ {
+		extra := network.NetworkDevice{MAC: fmt.Sprintf("02-00-00-01-00-%02x", i)}
+		sm.ObserveDevices([]network.NetworkDevice{{MAC: resident}, extra}, false)
+	}
+	if !sm.knownMACs[resident] {
+		t.Errorf("Device %s seen in every scan was evicted", resident)
+	}
+	if evicted := devices[3].MAC; sm.knownMACs[evicted] {
+		t.Errorf("Least recently seen device %s still known, want it evicted", evicted)
+	}
+
+	// An oversized list on disk is trimmed the same way on load
+	sm.knownOrder = append([]string{"02-00-00-00-ff-ff"}, sm.knownOrder...)
+	sm.saveState()
+	loaded := &SentryManager{stateFile: statePath}
+	loaded.loadState()
+	if len(loaded.knownMACs) != config.MaxKnownDevices || loaded.knownMACs["02-00-00-00-ff-ff"] {
+		t.Errorf("Loaded %d known devices, want %d without the oldest", len(loaded.knownMACs), config.MaxKnownDevices)
+	}
+}
+
+func TestAutoResumeRequiresRoam(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("APPDATA", tmpDir)
+	if err := config.Save(config.Settings{HomeSSID: "HomeNet", IsPaused: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := &SentryManager{stateFile: filepath.Join(tmpDir, "sentry-state.json")}
+	settings := config.Settings{HomeSSID: "HomeNet", IsPaused: true, AutoResumeOnHome: true}
+
+	if sm.autoResume(settings, true) {
+		t.Error("Should not resume when paused at home without having left")
+	}
+	if sm.autoResume(settings, false) {
+		t.Error("Should not resume while away")
+	}
+
+	settings.AutoResumeOnHome = false
+	if sm.autoResume(settings, true) {
+		t.Error("Should not resume when AutoResumeOnHome is disabled")
+	}
+
+	settings.AutoResumeOnHome = true
+	if !sm.autoResume(settings, true) {
+		t.Fatal("Expected resume after returning home")
+	}
+	loaded, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.IsPaused {
+		t.Error("Settings should no longer be paused after auto-resume")
+	}
+}
+
+func TestPauseExpired(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("APPDATA", tmpDir)
+	if err := config.Save(config.Settings{HomeSSID: "HomeNet", IsPaused: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := &SentryManager{stateFile: filepath.Join(tmpDir, "sentry-state.json")}
+	now := time.Now()
+	settings := config.Settings{IsPaused: true, PausedAt: now.Add(-30 * time.Minute)}
+
+	if sm.pauseExpired(settings, now) {
+		t.Error("Unlimited pause should never expire")
+	}
+	settings.MaxPauseMinutes = 60
+	if sm.pauseExpired(settings, now) {
+		t.Error("Pause within the limit should not expire")
+	}
+
+	// A pause saved without a start is timed from when it was first seen
+	legacy := config.Settings{IsPaused: true, MaxPauseMinutes: 60}
+	if sm.pauseExpired(legacy, now) {
+		t.Error("Pause without a start should not expire when first seen")
+	}
+	if !sm.pauseExpired(legacy, now.Add(61*time.Minute)) {
+		t.Fatal("Pause without a start should expire once the limit passes")
+	}
+	loaded, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.IsPaused {
+		t.Error("Settings should no longer be paused after the limit")
+	}
+
+	timed := config.Settings{IsPaused: true, PausedAt: now.Add(-time.Hour), PausedUntil: now.Add(time.Minute)}
+	if sm.pauseExpired(timed, now) {
+		t.Error("Timed pause should not end early")
+	}
+	if !sm.pauseExpired(timed, now.Add(2*time.Minute)) {
+		t.Error("Timed pause should end once its time is up")
+	}
+}
+
+type fakePresence struct{ present bool }
+
+func (f *fakePresence) IsPresent(config.Settings) bool { return f.present }
+
+type fakeSSID struct{ ssid string }
+
+func (f *fakeSSID) CurrentSSID() string { return f.ssid }
+
+type fakeGateway struct{ mac string }
+
+func (f *fakeGateway) GatewayMAC() string { return f.mac }
+
+type fakePeripherals struct{ attached []string }
+
+func (f *fakePeripherals) Peripherals() []string { return f.attached }
+
+type fakeSettings struct {
+	settings config.Settings
+	err      error
+}
+
+func (f *fakeSettings) Load() (config.Settings, error) { return f.settings, f.err }
+
+// monitorHarness drives step() with fake network and settings
+type monitorHarness struct {
+	sm        *SentryManager
+	presence  *fakePresence
+	ssid      *fakeSSID
+	gateway   *fakeGateway
+	settings  *fakeSettings
+	shutdowns int
+}
+
+func newMonitorHarness(t *testing.T) *monitorHarness {
+	t.Helper()
+	h := &monitorHarness{
+		presence: &fakePresence{},
+		ssid:     &fakeSSID{ssid: "HomeNet"},
+		gateway:  &fakeGateway{mac: "11-22-33-44-55-66"},
+		settings: &fakeSettings{settings: config.Settings{
+			HomeSSID:      "HomeNet",
+			PhoneMAC:      "aa-bb-cc-dd-ee-ff",
+			DetectionType: config.DetectionTypeMAC,
+			GraceChecks:   2,
+			PollInterval:  10,
+		}},
+	}
+	h.sm = &SentryManager{
+		status:         StatusRoaming,
+		cancelShutdown: make(chan struct{}),
+		stateFile:      filepath.Join(t.TempDir(), "sentry-state.json"),
+		deps:           Dependencies{Presence: h.presence, SSID: h.ssid, Gateway: h.gateway, Settings: h.settings},
+	}
+	h.sm.onShutdown = func(config.Settings, string) { h.shutdowns++ }
+	return h
+}
+
+func (h *monitorHarness) expect(t *testing.T, want SentryStatus) {
+	t.Helper()
+	got, wait := h.sm.step()
+	if got != want {
+		t.Fatalf("step() status = %v, want %v", got, want)
+	}
+	if wait != 10*time.Second {
+		t.Errorf("step() wait = %v, want 10s", wait)
+	}
+}
+
+func TestStepRoamHomeMissingShutdown(t *testing.T) {
+	h := newMonitorHarness(t)
+
+	h.ssid.ssid = "CoffeeShop"
+	h.expect(t, StatusRoaming)
+
+	h.ssid.ssid = "HomeNet"
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+
+	h.presence.present = false
+	h.expect(t, StatusGracePeriod)
+	if h.sm.GraceCount() != 1 {
+		t.Errorf("GraceCount = %d, want 1", h.sm.GraceCount())
+	}
+	if h.shutdowns != 0 {
+		t.Fatal("Shutdown triggered before grace period expired")
+	}
+
+	h.expect(t, StatusShutdownImminent)
+	if h.shutdowns != 1 {
+		t.Errorf("Shutdown triggered %d times, want 1", h.shutdowns)
+	}
+}
+
+func TestStepLocksBeforeShutdown(t *testing.T) {
+	h := newMonitorHarness(t)
+	h.settings.settings.GraceChecks = 3
+	h.settings.settings.LockGraceChecks = 2
+	h.settings.settings.ShutdownAction = config.ShutdownActionShutdown
+	locks := 0
+	h.sm.lockSession = func() error {
+		locks++
+		return nil
+	}
+
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+
+	h.presence.present = false
+	h.expect(t, StatusGracePeriod)
+	if locks != 0 {
+		t.Fatal("Session locked on the first missed check")
+	}
+	h.expect(t, StatusGracePeriod)
+	if locks != 1 {
+		t.Fatalf("Session locked %d times at LockGraceChecks, want 1", locks)
+	}
+	h.expect(t, StatusShutdownImminent)
+	if locks != 1 || h.shutdowns != 1 {
+		t.Errorf("Got %d locks and %d shutdowns after the grace period, want 1 and 1", locks, h.shutdowns)
+	}
+}
+
+func TestStepRecoveryResetsGrace(t *testing.T) {
+	h := newMonitorHarness(t)
+
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+
+	h.presence.present = false
+	h.expect(t, StatusGracePeriod)
+
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+	if h.sm.GraceCount() != 0 {
+		t.Errorf("GraceCount = %d after recovery, want 0", h.sm.GraceCount())
+	}
+
+	// Leaving home also clears the grace period
+	h.presence.present = false
+	h.expect(t, StatusGracePeriod)
+	h.ssid.ssid = "CoffeeShop"
+	h.expect(t, StatusRoaming)
+	if h.sm.GraceCount() != 0 {
+		t.Errorf("GraceCount = %d after roaming, want 0", h.sm.GraceCount())
+	}
+	if h.shutdowns != 0 {
+		t.Errorf("Unexpected shutdown during recovery")
+	}
+}
+
+func TestStepNotifyOnRecovery(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			h := newMonitorHarness(t)
+			h.settings.settings.NotifyOnRecovery = enabled
+			events := make(eventRecorder, 4)
+			h.sm.deps.Notifier = events
+
+			h.presence.present = true
+			h.expect(t, StatusMonitoring)
+			h.presence.present = false
+			h.expect(t, StatusGracePeriod)
+			h.presence.present = true
+			h.expect(t, StatusMonitoring)
+
+			// notify delivers from a goroutine; give it time to arrive
+			var got []notify.Event
+			timeout := time.After(200 * time.Millisecond)
+		collect:
+			for {
+				select {
+				case event := <-events:
+					got = append(got, event)
+				case <-timeout:
+					break collect
+				}
+			}
+
+			want := 0
+			if enabled {
+				want = 1
+			}
+			if len(got) != want {
+				t.Fatalf("Got %d notifications, want %d: %+v", len(got), want, got)
+			}
+			if enabled && !strings.Contains(got[0].Message, "reconnected") {
+				t.Errorf("Notification = %q, want the recovery message", got[0].Message)
+			}
+		})
+	}
+}
+
+func TestStepWaitsForFirstSighting(t *testing.T) {
+	h := newMonitorHarness(t)
+
+	h.expect(t, StatusWaitingForPhone)
+	h.expect(t, StatusWaitingForPhone)
+	if h.sm.GraceCount() != 0 {
+		t.Errorf("GraceCount = %d before phone was ever seen, want 0", h.sm.GraceCount())
+	}
+}
+
+func TestStepSettingsErrorKeepsStatus(t *testing.T) {
+	h := newMonitorHarness(t)
+
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+
+	h.settings.err = config.ErrIO
+	h.settings.settings.PollInterval = 10
+	h.expect(t, StatusMonitoring)
+}
+
+func TestStepPaused(t *testing.T) {
+	h := newMonitorHarness(t)
+	h.settings.settings.IsPaused = true
+	h.expect(t, StatusPaused)
+}
+
+func TestHoldLastHomeState(t *testing.T) {
+	h := newMonitorHarness(t)
+	h.settings.settings.HoldLastHomeSec = 30
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+
+	// A brief unknown SSID keeps the home status and skips the phone check
+	h.ssid.ssid = network.UnknownSSID
+	h.presence.present = false
+	h.expect(t, StatusMonitoring)
+	if h.sm.graceCount != 0 {
+		t.Errorf("graceCount = %d while holding home, want 0", h.sm.graceCount)
+	}
+
+	// Once the hold runs out the usual no-network handling applies
+	h.sm.lastHomeAt = time.Now().Add(-time.Minute)
+	h.expect(t, StatusNoNetwork)
+
+	h.settings.settings.HoldLastHomeSec = 0
+	h.ssid.ssid = "HomeNet"
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+	h.ssid.ssid = network.UnknownSSID
+	h.expect(t, StatusNoNetwork)
+}
+
+func TestWatchSettings(t *testing.T) {
+	settings := &fakeSettings{settings: config.Settings{HomeSSID: "HomeNet", AutoSwitchProfile: true}}
+	got, err := watchSettings{settings}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.SilentMode || got.AutoSwitchProfile || got.HomeSSID != "HomeNet" {
+		t.Errorf("watchSettings.Load() = silent %v, auto-switch %v, home %q", got.SilentMode, got.AutoSwitchProfile, got.HomeSSID)
+	}
+}
+
+func TestAutoSwitchProfile(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	if err := config.Update("OfficeNet", "aa-bb-cc-dd-ee-02"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SaveProfile("office"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Update("HomeNet", "aa-bb-cc-dd-ee-01"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SaveProfile("home"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		h := newMonitorHarness(t)
+		h.settings.settings, _ = config.Load()
+		h.settings.settings.AutoSwitchProfile = true
+		h.sm.deps.DryRun = dryRun
+		h.ssid.ssid = "OfficeNet"
+		h.sm.step()
+
+		want := "office"
+		if dryRun {
+			want = "home" // A dry run never writes the settings
+		}
+		if got, _ := config.Load(); got.ActiveProfile != want {
+			t.Errorf("Dry run %v: active profile = %q, want %q", dryRun, got.ActiveProfile, want)
+		}
+	}
+}
+
+func TestDryRunLeavesPauseToTray(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	paused := config.Settings{HomeSSID: "HomeNet", IsPaused: true, PausedUntil: time.Now().Add(-time.Minute)}
+	if err := config.Save(paused); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newMonitorHarness(t)
+	h.sm.deps.DryRun = true
+	h.settings.settings.IsPaused = true
+	h.settings.settings.PausedUntil = paused.PausedUntil
+	h.expect(t, StatusPaused)
+	if got, _ := config.Load(); !got.IsPaused {
+		t.Error("Dry run resumed protection in the settings file, want it left to the tray app")
+	}
+}
+
+func TestShutdownCooldown(t *testing.T) {
+	h := newMonitorHarness(t)
+	h.settings.settings.ShutdownCooldownSec = 60
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+
+	h.presence.present = false
+	h.expect(t, StatusGracePeriod)
+	h.expect(t, StatusShutdownImminent)
+
+	// The phone returns and leaves again right away: no second countdown
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+	h.presence.present = false
+	h.expect(t, StatusGracePeriod)
+	h.expect(t, StatusCooldown)
+	h.expect(t, StatusCooldown)
+	if h.shutdowns != 1 {
+		t.Errorf("Shutdowns during cooldown = %d, want 1", h.shutdowns)
+	}
+
+	// The last trigger survives a restart
+	loaded := &SentryManager{stateFile: h.sm.stateFile}
+	loaded.loadState()
+	if !loaded.lastTrigger.Equal(h.sm.lastTrigger) {
+		t.Errorf("Loaded lastTrigger = %v, want %v", loaded.lastTrigger, h.sm.lastTrigger)
+	}
+
+	h.sm.lastTrigger = time.Now().Add(-2 * time.Minute)
+	h.expect(t, StatusShutdownImminent)
+	if h.shutdowns != 2 {
+		t.Errorf("Shutdowns after cooldown = %d, want 2", h.shutdowns)
+	}
+}
+
+func TestIsPresentByIP(t *testing.T) {
+	settings := config.Settings{
+		PhoneIP:            "192.168.1.20",
+		PhoneMAC:           "aa-bb-cc-dd-ee-ff",
+		DetectionType:      config.DetectionTypeIP,
+		AutoUpdateDeviceIP: true,
+	}
+	answering := map[string]bool{"192.168.1.35": true}
+	ping := func(ip string) bool { return answering[ip] }
+	findIP := func(string) string { return "192.168.1.35" }
+
+	var saved string
+	save := func(ip string) error { saved = ip; return nil }
+	if !isPresentByIP(settings, ping, findIP, save) {
+		t.Error("isPresentByIP() = false after a lease change, want true")
+	}
+	if saved != "192.168.1.35" {
+		t.Errorf("Saved IP = %q, want the new lease", saved)
+	}
+
+	saved = ""
+	settings.AutoUpdateDeviceIP = false
+	if isPresentByIP(settings, ping, findIP, save) || saved != "" {
+		t.Error("isPresentByIP() should not follow the lease when AutoUpdateDeviceIP is off")
+	}
+
+	settings.AutoUpdateDeviceIP = true
+	answering = map[string]bool{}
+	if isPresentByIP(settings, ping, findIP, save) || saved != "" {
+		t.Error("isPresentByIP() should not save an IP that does not answer")
+	}
+}
+
+func TestRunSimulation(t *testing.T) {
+	tests := []struct {
+		scenario string
+		want     []SentryStatus
+	}{
+		{"phone-returns", []SentryStatus{
+			StatusMonitoring, StatusMonitoring, StatusGracePeriod, StatusGracePeriod, StatusMonitoring, StatusMonitoring,
+		}},
+		{"leave-home", []SentryStatus{
+			StatusMonitoring, StatusMonitoring, StatusRoaming, StatusRoaming, StatusMonitoring,
+		}},
+		{"wifi-off", []SentryStatus{
+			StatusMonitoring, StatusMonitoring, StatusNoNetwork, StatusNoNetwork, StatusMonitoring,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			scenario, ok := FindScenario(tt.scenario)
+			if !ok {
+				t.Fatalf("Scenario %q not found", tt.scenario)
+			}
+
+			var got []SentryStatus
+			runSimulation(scenario, func(change StatusChange) {
+				got = append(got, change.Status)
+			}, func(time.Duration) {})
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Statuses = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Statuses = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, ok := FindScenario("no-such-scenario"); ok {
+		t.Error("FindScenario should not find unknown scenarios")
+	}
+}
+
+func TestRehearsal(t *testing.T) {
+	settings := config.DefaultSettings()
+	settings.IsPaused = true
+	settings.GraceChecks = 2
+	settings.ShutdownDelay = config.ShutdownMinDelay
+	settings.SilentMode = true
+
+	var got []SentryStatus
+	r := NewRehearsal(settings, func(change StatusChange) {
+		got = append(got, change.Status)
+	})
+	if s := r.Settings(); s.IsPaused || !s.HomeConfigured() || !s.HasDeviceConfigured() {
+		t.Fatalf("Rehearsal settings = %+v, want active with home and device placeholders", s)
+	}
+
+	done := make(chan bool)
+	go func() { done <- r.run(func(time.Duration) {}) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !r.Cancel() {
+		if time.Now().After(deadline) {
+			t.Fatal("Countdown never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if completed := <-done; completed {
+		t.Error("Run() = true after cancelling, want false")
+	}
+	want := []SentryStatus{StatusMonitoring, StatusGracePeriod, StatusGracePeriod, StatusShutdownImminent, StatusMonitoring}
+	if len(got) != len(want) {
+		t.Fatalf("Statuses = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("Statuses = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := newMonitorHarness(t)
+	h.presence.present = true
+
+	h.expect(t, StatusMonitoring)
+	stats := h.sm.Stats()
+	if stats.MonitoringSince.IsZero() || stats.HomeSince.IsZero() || stats.LastPhoneSeen.IsZero() {
+		t.Fatalf("Stats() after a home check = %+v, want monitoring, home and phone times set", stats)
+	}
+	since := stats.MonitoringSince
+
+	h.expect(t, StatusMonitoring)
+	if got := h.sm.Stats().MonitoringSince; !got.Equal(since) {
+		t.Errorf("MonitoringSince moved from %v to %v during continuous protection", since, got)
+	}
+
+	h.ssid.ssid = "CoffeeShop"
+	h.expect(t, StatusRoaming)
+	if !h.sm.Stats().HomeSince.IsZero() {
+		t.Error("HomeSince should be cleared after leaving home")
+	}
+
+	h.settings.settings.IsPaused = true
+	h.expect(t, StatusPaused)
+	if !h.sm.Stats().MonitoringSince.IsZero() {
+		t.Error("MonitoringSince should be cleared while paused")
+	}
+
+	// Counters survive a restart
+	h.sm.mu.Lock()
+	h.sm.shutdownPending = true
+	h.sm.mu.Unlock()
+	if !h.sm.CancelShutdown() {
+		t.Fatal("CancelShutdown() = false with a pending shutdown")
+	}
+	h.sm.deps.DryRun = true
+	h.sm.executeShutdown(config.Settings{ShutdownAction: config.ShutdownActionLock})
+
+	restored := &SentryManager{stateFile: h.sm.stateFile}
+	restored.loadState()
+	if got := restored.Stats(); got.Cancels != 1 || got.Shutdowns != 1 {
+		t.Errorf("Restored counters = %d cancels, %d shutdowns, want 1 and 1", got.Cancels, got.Shutdowns)
+	}
+}
+
+func TestRecordGraceEntry(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "sentry-state.json")
+	sm := &SentryManager{stateFile: statePath}
+	settings := config.Settings{GraceAlertCount: 3, GraceAlertWindow: 60}
+	now := time.Now()
+
+	if sm.recordGraceEntry(settings, now.Add(-90*time.Minute)) {
+		t.Error("First grace period should not alert")
+	}
+	if sm.recordGraceEntry(settings, now.Add(-20*time.Minute)) {
+		t.Error("Second grace period should not alert")
+	}
+	// The first entry has left the window, so this is only the second
+	if sm.recordGraceEntry(settings, now.Add(-10*time.Minute)) {
+		t.Error("Entries outside the window should not count")
+	}
+
+	loaded := &SentryManager{stateFile: statePath}
+	loaded.loadState()
+	if len(loaded.graceEntries) != 2 {
+		t.Fatalf("Loaded %d grace entries, want 2", len(loaded.graceEntries))
+	}
+	if !loaded.recordGraceEntry(settings, now) {
+		t.Error("Third grace period within the window should alert")
+	}
+	if loaded.recordGraceEntry(settings, now.Add(time.Minute)) {
+		t.Error("Alert should not repeat on the next grace period")
+	}
+
+	disabled := &SentryManager{stateFile: statePath}
+	for i := 0; i < 5; i++ {
+		if disabled.recordGraceEntry(config.Settings{GraceAlertWindow: 60}, now) {
+			t.Fatal("Flapping alert should be disabled when GraceAlertCount is 0")
+		}
+	}
+}
+
+func TestWaitForNextCheck(t *testing.T) {
+	if waitForNextCheck(time.Millisecond, nil) {
+		t.Error("Poll interval elapsing should not count as a network change")
+	}
+
+	changes := make(chan struct{}, 1)
+	changes <- struct{}{}
+	start := time.Now()
+	if !waitForNextCheck(time.Hour, changes) {
+		t.Error("Network change should end the wait")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Network change did not cut the wait short")
+	}
+}
+
+func TestEarlyChecksDontAdvanceGrace(t *testing.T) {
+	h := newMonitorHarness(t)
+	h.settings.settings.GraceChecks = 3
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+
+	// A network change may start the grace period...
+	h.presence.present = false
+	h.sm.earlyCheck = true
+	h.expect(t, StatusGracePeriod)
+
+	// ...but flapping WiFi waking more checks doesn't run it down
+	for i := 0; i < 5; i++ {
+		h.expect(t, StatusGracePeriod)
+	}
+	if got := h.sm.GraceCount(); got != 1 {
+		t.Fatalf("Grace count after early checks = %d, want 1", got)
+	}
+
+	h.sm.earlyCheck = false
+	h.expect(t, StatusGracePeriod)
+	h.expect(t, StatusShutdownImminent)
+	if h.shutdowns != 1 {
+		t.Errorf("Shutdowns = %d, want 1 after the checks on the poll interval", h.shutdowns)
+	}
+}
+
+func TestStepGatewayHomeDetection(t *testing.T) {
+	h := newMonitorHarness(t)
+	h.settings.settings.HomeDetection = config.HomeDetectionGateway
+	h.settings.settings.HomeGatewayMAC = "11-22-33-44-55-66"
+	h.presence.present = true
+
+	// A spoofed SSID with someone else's gateway is not home
+	h.gateway.mac = "aa-aa-aa-aa-aa-aa"
+	h.expect(t, StatusRoaming)
+
+	// The home gateway counts even after the router was renamed
+	h.gateway.mac = "11:22:33:44:55:66"
+	h.ssid.ssid = "RenamedRouter"
+	h.expect(t, StatusMonitoring)
+	if !h.sm.AtHome() {
+		t.Error("AtHome() = false on the home gateway, want true whatever the SSID")
+	}
+
+	h.settings.settings.HomeGatewayMAC = ""
+	h.expect(t, StatusRoaming)
+	if h.sm.AtHome() {
+		t.Error("AtHome() = true without a home gateway, want false")
+	}
+}
+
+func TestStepDocked(t *testing.T) {
+	h := newMonitorHarness(t)
+	peripherals := &fakePeripherals{attached: []string{`Generic PnP Monitor (DISPLAY\DEL4231\5&1A2B3C4D&0&UID4353)`}}
+	h.sm.deps.Peripherals = peripherals
+	h.settings.settings.DisarmWhenDockedTo = []string{`display\del4231\5&1a2b3c4d&0&uid4353`}
+
+	h.presence.present = true
+	h.expect(t, StatusDocked)
+
+	// The missing phone doesn't matter while docked
+	h.presence.present = false
+	h.expect(t, StatusDocked)
+	h.expect(t, StatusDocked)
+	if h.shutdowns != 0 || h.sm.GraceCount() != 0 {
+		t.Fatalf("Docked: %d shutdowns, grace count %d", h.shutdowns, h.sm.GraceCount())
+	}
+
+	// Once undocked, the missing phone counts again
+	peripherals.attached = nil
+	h.sm.phoneEverSeen = true
+	h.expect(t, StatusGracePeriod)
+}
+
+func TestAbsentFor(t *testing.T) {
+	h := newMonitorHarness(t)
+	h.settings.settings.GraceChecks = 5
+
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+	if got := h.sm.absentFor(time.Now()); got != 0 {
+		t.Errorf("absentFor() = %v while the phone is present, want 0", got)
+	}
+
+	h.presence.present = false
+	h.expect(t, StatusGracePeriod)
+	start := h.sm.missingSince
+	h.expect(t, StatusGracePeriod)
+	if h.sm.missingSince != start {
+		t.Error("Later misses should not move the start of the absence")
+	}
+	if got := h.sm.absentFor(start.Add(47 * time.Second)); formatAbsence(got) != "47s" {
+		t.Errorf("absentFor() = %s, want 47s", formatAbsence(got))
+	}
+
+	h.presence.present = true
+	h.expect(t, StatusMonitoring)
+	if got := h.sm.absentFor(time.Now()); got != 0 {
+		t.Errorf("absentFor() = %v after recovery, want 0", got)
+	}
+}
+
+func TestTimeToShutdown(t *testing.T) {
+	settings := config.DefaultSettings()
+	settings.GraceChecks = 5
+	settings.PollInterval = 10
+	settings.ShutdownDelay = 30
+
+	if got := timeToShutdown(settings, 1); got != 70*time.Second {
+		t.Errorf("timeToShutdown() after the first miss = %v, want 1m10s", got)
+	}
+	if got := timeToShutdown(settings, 5); got != 30*time.Second {
+		t.Errorf("timeToShutdown() after the last miss = %v, want the countdown only", got)
+	}
+}
+
+func TestAwaitConfirmation(t *testing.T) {
+	answer := func(confirmed bool) func(context.Context, string, time.Duration) <-chan bool {
+		return func(context.Context, string, time.Duration) <-chan bool {
+			answers := make(chan bool, 1)
+			answers <- confirmed
+			return answers
+		}
+	}
+	pending := func() *SentryManager {
+		return &SentryManager{
+			status:          StatusShutdownImminent,
+			cancelShutdown:  make(chan struct{}),
+			shutdownPending: true,
+		}
+	}
+
+	sm := pending()
+	sm.askConfirmation = answer(true)
+	if !sm.awaitConfirmation(time.Hour, "1m0s", sm.cancelShutdown) {
+		t.Error("awaitConfirmation() = false after OK, want true")
+	}
+
+	sm = pending()
+	sm.askConfirmation = answer(false)
+	if sm.awaitConfirmation(time.Hour, "1m0s", sm.cancelShutdown) {
+		t.Error("awaitConfirmation() = true after Cancel, want false")
+	}
+	if sm.cancelCount != 1 || sm.shutdownPending || sm.Status() != StatusMonitoring {
+		t.Errorf("After Cancel: cancelCount %d, pending %v, status %v", sm.cancelCount, sm.shutdownPending, sm.Status())
+	}
+
+	// Without a dialog, or once it closes unanswered, the delay runs out
+	sm = pending()
+	if !sm.awaitConfirmation(10*time.Millisecond, "1m0s", sm.cancelShutdown) {
+		t.Error("awaitConfirmation() = false after the delay, want true")
+	}
+	sm.askConfirmation = func(context.Context, string, time.Duration) <-chan bool {
+		answers := make(chan bool)
+		close(answers)
+		return answers
+	}
+	if !sm.awaitConfirmation(10*time.Millisecond, "1m0s", sm.cancelShutdown) {
+		t.Error("awaitConfirmation() = false after a failed dialog, want true")
+	}
+
+	// Cancelling from the tray ends the wait
+	sm = pending()
+	cancelled := sm.cancelShutdown
+	go sm.CancelShutdown()
+	if sm.awaitConfirmation(time.Hour, "1m0s", cancelled) {
+		t.Error("awaitConfirmation() = true after a tray cancel, want false")
+	}
+}
+
+func TestActionTookEffect(t *testing.T) {
+	failed := errors.New("access denied")
+	tests := []struct {
+		name    string
+		action  string
+		runErr  error
+		locked  bool
+		resumed bool
+		want    bool
+		waits   bool
+	}{
+		{"shutdown still running", config.ShutdownActionShutdown, nil, false, false, false, true},
+		{"shutdown refused", config.ShutdownActionShutdown, failed, false, false, false, false},
+		{"still logged on", config.ShutdownActionLogoff, nil, false, false, false, true},
+		{"locked", config.ShutdownActionLock, nil, true, false, true, true},
+		{"lock screen missing", config.ShutdownActionLock, nil, false, false, false, true},
+		{"woke from sleep", config.ShutdownActionSleep, nil, false, true, true, true},
+		{"sleep never happened", config.ShutdownActionSleep, nil, false, false, false, true},
+		{"woke from hibernate", config.ShutdownActionHibernate, nil, false, true, true, true},
+		{"hibernate refused", config.ShutdownActionHibernate, failed, false, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waited := false
+			got := actionTookEffect(tt.action, tt.runErr, func() { waited = true },
+				func() bool { return tt.locked }, func() bool { return tt.resumed })
+			if got != tt.want {
+				t.Errorf("actionTookEffect() = %v, want %v", got, tt.want)
+			}
+			if waited != tt.waits {
+				t.Errorf("waited = %v, want %v", waited, tt.waits)
+			}
+		})
+	}
+}
+
+// eventRecorder passes the events it receives to a channel
+type eventRecorder chan notify.Event
+
+func (r eventRecorder) Notify(event notify.Event) error {
+	r <- event
+	return nil
+}
+
+func TestNotifyDryRunKeepsAlertsLocal(t *testing.T) {
+	settings := config.Settings{ShutdownDelay: 30, ShutdownAction: config.ShutdownActionShutdown}
+	events := make(eventRecorder, 1)
+	sm := &SentryManager{deps: Dependencies{Notifier: events}}
+
+	sm.notify(criticalEvent(settings, "Alert", "Shutting down", "{action} in {delay}s", config.WebhookStatusShuttingDown, "HomeNet", "1m0s"))
+	event := <-events
+	if event.Level != notify.Critical || event.Template == "" || event.Fields["delay"] != "30" {
+		t.Errorf("Critical event = %+v, want level Critical with the template and delay 30", event)
+	}
+
+	sm.deps.DryRun = true
+	sm.notify(criticalEvent(settings, "Alert", "Shutting down", "{action} in {delay}s", config.WebhookStatusShuttingDown, "HomeNet", "1m0s"))
+	if event := <-events; event.Template != "" {
+		t.Errorf("Dry run event template = %q, want none so no webhook is posted", event.Template)
+	}
+}
@@ -0,0 +1,145 @@
+package sentry
+
+import (
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/network"
+	"home-sentry/pkg/notify"
+	"time"
+)
+
+// Scenario scripts what the simulated network reports on each check
+type Scenario struct {
+	Name        string
+	Description string
+	checks      []simulatedCheck
+}
+
+// simulatedCheck is what the network looks like during one monitor check
+type simulatedCheck struct {
+	away    bool // connected to a network other than home
+	noWiFi  bool // WiFi disabled or disconnected
+	present bool // phone answers presence checks
+}
+
+var (
+	simHome    = simulatedCheck{present: true}
+	simMissing = simulatedCheck{}
+	simAway    = simulatedCheck{away: true}
+	simNoWiFi  = simulatedCheck{noWiFi: true}
+)
+
+var scenarios = []Scenario{
+	{
+		Name:        "phone-leaves",
+		Description: "Phone is home for a few checks, then disappears until the (dry-run) shutdown",
+		checks:      []simulatedCheck{simHome, simHome, simHome, simMissing, simMissing, simMissing},
+	},
+	{
+		Name:        "phone-returns",
+		Description: "Phone disappears briefly and comes back before the grace period runs out",
+		checks:      []simulatedCheck{simHome, simHome, simMissing, simMissing, simHome, simHome},
+	},
+	{
+		Name:        "leave-home",
+		Description: "Laptop leaves home together with the phone, so protection stands down",
+		checks:      []simulatedCheck{simHome, simHome, simAway, simAway, simHome},
+	},
+	{
+		Name:        "wifi-off",
+		Description: "WiFi is switched off while at home",
+		checks:      []simulatedCheck{simHome, simHome, simNoWiFi, simNoWiFi, simHome},
+	},
+}
+
+// Scenarios returns the built-in simulation scenarios
+func Scenarios() []Scenario {
+	return scenarios
+}
+
+// FindScenario looks up a built-in scenario by name
+func FindScenario(name string) (Scenario, bool) {
+	for _, sc := range scenarios {
+		if sc.Name == name {
+			return sc, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// simulationSettings are fixed settings for simulations, independent of the
+// user's settings file
+func simulationSettings() config.Settings {
+	settings := config.DefaultSettings()
+	settings.HomeSSID = "Simulated Home"
+	settings.PhoneMAC = "02-00-00-00-00-01"
+	settings.DetectionType = config.DetectionTypeMAC
+	settings.GraceChecks = 3
+	settings.PollInterval = 1
+	settings.ShutdownDelay = config.ShutdownMinDelay
+	return settings
+}
+
+// scriptedNetwork plays back a scenario one check at a time
+type scriptedNetwork struct {
+	settings config.Settings
+	current  simulatedCheck
+}
+
+func (n *scriptedNetwork) CurrentSSID() string {
+	switch {
+	case n.current.noWiFi:
+		return network.UnknownSSID
+	case n.current.away:
+		return "Simulated Coffee Shop"
+	default:
+		return n.settings.HomeSSID
+	}
+}
+
+func (n *scriptedNetwork) IsPresent(config.Settings) bool {
+	return n.current.present
+}
+
+// staticSettings always returns the same settings
+type staticSettings struct {
+	settings config.Settings
+}
+
+func (s staticSettings) Load() (config.Settings, error) {
+	return s.settings, nil
+}
+
+// RunSimulation drives the real state machine through a scenario using a
+// scripted network. Nothing is read from or written to disk and the shutdown
+// is a dry run. onChange receives every status change.
+func RunSimulation(scenario Scenario, onChange func(StatusChange)) {
+	runSimulation(scenario, onChange, time.Sleep)
+}
+
+func runSimulation(scenario Scenario, onChange func(StatusChange), sleep func(time.Duration)) {
+	settings := simulationSettings()
+	script := &scriptedNetwork{settings: settings}
+
+	sm := &SentryManager{
+		status:         StatusRoaming,
+		cancelShutdown: make(chan struct{}),
+		knownMACs:      make(map[string]bool),
+		StatusCallback: onChange,
+		deps: Dependencies{
+			Presence: script,
+			SSID:     script,
+			Settings: staticSettings{settings: settings},
+			Notifier: notify.Toast{},
+			DryRun:   true,
+		},
+	}
+	sm.onShutdown = sm.triggerShutdownWithCountdown
+
+	for i, check := range scenario.checks {
+		script.current = check
+		_, wait := sm.step()
+		if i < len(scenario.checks)-1 {
+			sleep(wait)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package sentry
+
+import (
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/network"
+	"sort"
+	"time"
+)
+
+// DevicePresence is one device of the home network and whether the last
+// scan found it
+type DevicePresence struct {
+	MAC       string `json:"mac"`
+	IP        string `json:"ip,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+	Vendor    string `json:"vendor,omitempty"`
+	Present   bool   `json:"present"`
+	Monitored bool   `json:"monitored"` // The configured phone
+}
+
+// Snapshot is a point-in-time view of the monitor for dashboards and status
+// output
+type Snapshot struct {
+	Status       SentryStatus     `json:"status"`
+	SSID         string           `json:"ssid"`
+	Device       string           `json:"device"`
+	PhonePresent bool             `json:"phone_present"`
+	LastScan     time.Time        `json:"last_scan,omitempty"` // Zero until a scan was recorded
+	Devices      []DevicePresence `json:"devices"`
+	DevicesHome  int              `json:"devices_home"`
+}
+
+// RecordScan remembers the result of a scan of the home network for
+// Snapshot; scans elsewhere are not recorded
+func (s *SentryManager) RecordScan(devices []network.NetworkDevice, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastScan = append([]network.NetworkDevice(nil), devices...)
+	s.lastScanAt = at
+}
+
+// Snapshot returns the current status together with every device from the
+// last home scan. Known devices missing from that scan are listed as not present.
+func (s *SentryManager) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := Snapshot{
+		Status:       s.status,
+		SSID:         s.currentSSID,
+		Device:       s.deviceID,
+		PhonePresent: s.status == StatusMonitoring,
+		LastScan:     s.lastScanAt,
+		Devices:      []DevicePresence{},
+	}
+	monitoredMAC, _ := config.SanitizeMAC(s.deviceID)
+
+	seen := make(map[string]bool, len(s.lastScan))
+	for _, device := range s.lastScan {
+		mac, err := config.SanitizeMAC(device.MAC)
+		if err != nil || mac == "" || seen[mac] {
+			continue
+		}
+		seen[mac] = true
+		snap.Devices = append(snap.Devices, DevicePresence{
+			MAC:       mac,
+			IP:        device.IP,
+			Hostname:  device.Hostname,
+			Vendor:    device.Vendor,
+			Present:   true,
+			Monitored: (monitoredMAC != "" && mac == monitoredMAC) || (s.deviceID != "" && device.IP == s.deviceID),
+		})
+	}
+	sort.Slice(snap.Devices, func(i, j int) bool {
+		return snap.Devices[i].MAC < snap.Devices[j].MAC
+	})
+	snap.DevicesHome = len(snap.Devices)
+
+	for _, mac := range sortedKeys(s.knownMACs) {
+		if seen[mac] {
+			continue
+		}
+		snap.Devices = append(snap.Devices, DevicePresence{
+			MAC:       mac,
+			Monitored: monitoredMAC != "" && mac == monitoredMAC,
+		})
+	}
+	return snap
+}
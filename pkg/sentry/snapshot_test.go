@@ -0,0 +1,49 @@
+package sentry
+
+import (
+	"home-sentry/pkg/network"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	sm := &SentryManager{
+		status:    StatusMonitoring,
+		deviceID:  "AA:BB:CC:DD:EE:02",
+		knownMACs: map[string]bool{"aa-bb-cc-dd-ee-01": true, "aa-bb-cc-dd-ee-02": true, "aa-bb-cc-dd-ee-09": true},
+	}
+
+	if snap := sm.Snapshot(); !snap.LastScan.IsZero() || snap.DevicesHome != 0 {
+		t.Errorf("Snapshot before any scan = %+v, want no scan", snap)
+	}
+
+	scannedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sm.RecordScan([]network.NetworkDevice{
+		{IP: "192.168.1.11", MAC: "aa-bb-cc-dd-ee-02"},
+		{IP: "192.168.1.10", MAC: "AA:BB:CC:DD:EE:01"},
+		{IP: "192.168.1.12", MAC: "aa-bb-cc-dd-ee-03"},
+	}, scannedAt)
+
+	snap := sm.Snapshot()
+	if !snap.LastScan.Equal(scannedAt) {
+		t.Errorf("LastScan = %v, want %v", snap.LastScan, scannedAt)
+	}
+	if !snap.PhonePresent {
+		t.Error("PhonePresent = false while monitoring")
+	}
+	if snap.DevicesHome != 3 {
+		t.Errorf("DevicesHome = %d, want 3", snap.DevicesHome)
+	}
+	if len(snap.Devices) != 4 {
+		t.Fatalf("Got %d devices, want 3 present and 1 known absent: %+v", len(snap.Devices), snap.Devices)
+	}
+	if snap.Devices[0].MAC != "aa-bb-cc-dd-ee-01" || !snap.Devices[0].Present {
+		t.Errorf("First device = %+v, want present aa-bb-cc-dd-ee-01", snap.Devices[0])
+	}
+	if !snap.Devices[1].Monitored {
+		t.Errorf("Phone not flagged as monitored: %+v", snap.Devices[1])
+	}
+	if absent := snap.Devices[3]; absent.MAC != "aa-bb-cc-dd-ee-09" || absent.Present {
+		t.Errorf("Last device = %+v, want absent aa-bb-cc-dd-ee-09", absent)
+	}
+}
@@ -0,0 +1,109 @@
+package sentry
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"home-sentry/pkg/config"
+	"os"
+)
+
+// stateFileVersion is the current state file layout. Version 1 was the bare
+// SentryState without a signature.
+const stateFileVersion = 2
+
+// maxStateFileSize leaves room for the known-device list
+const maxStateFileSize = 64 * 1024
+
+// errStateTampered means the state file's signature does not match its
+// contents, or a signed file lost its signature
+var errStateTampered = errors.New("state file signature mismatch")
+
+// signedState is the on-disk layout: the state exactly as it was signed and
+// an HMAC-SHA256 over it
+type signedState struct {
+	Version int             `json:"version"`
+	State   json.RawMessage `json:"state"`
+	HMAC    string          `json:"hmac,omitempty"`
+}
+
+// stateKey returns the signing key; tests replace it
+var stateKey = config.StateKey
+
+func signState(state json.RawMessage, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(state)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encodeState signs state and returns the file contents. Without a key the
+// state is written unsigned, and loading it later falls back to the checks
+// for unsigned files.
+func encodeState(state SentryState) ([]byte, error) {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	file := signedState{Version: stateFileVersion, State: raw}
+	if key, err := stateKey(); err == nil {
+		file.HMAC = signState(raw, key)
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// decodeState parses a state file. signed reports whether the signature was
+// present and verified; unsigned files (version 1, or written without a key)
+// parse with signed false. errStateTampered is returned when a signature
+// does not verify.
+func decodeState(data []byte) (state SentryState, signed bool, err error) {
+	if len(data) > maxStateFileSize {
+		return SentryState{}, false, fmt.Errorf("state file too large (%d bytes)", len(data))
+	}
+
+	var file signedState
+	if err := json.Unmarshal(data, &file); err != nil {
+		return SentryState{}, false, err
+	}
+	if file.Version == 0 {
+		// Version 1: the state itself
+		err := json.Unmarshal(data, &state)
+		return state, false, err
+	}
+	if file.Version > stateFileVersion {
+		return SentryState{}, false, fmt.Errorf("state file version %d is newer than supported (%d)", file.Version, stateFileVersion)
+	}
+
+	if file.HMAC != "" {
+		key, err := stateKey()
+		if err != nil {
+			return SentryState{}, false, err
+		}
+		// MarshalIndent re-indents the embedded state, so sign the compact form
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, file.State); err != nil {
+			return SentryState{}, false, err
+		}
+		want := signState(compact.Bytes(), key)
+		if !hmac.Equal([]byte(file.HMAC), []byte(want)) {
+			return SentryState{}, false, errStateTampered
+		}
+		signed = true
+	}
+	if err := json.Unmarshal(file.State, &state); err != nil {
+		return SentryState{}, false, err
+	}
+	return state, signed, nil
+}
+
+// readStateFile reads and decodes the state file at path
+func readStateFile(path string) (SentryState, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SentryState{}, false, err
+	}
+	return decodeState(data)
+}
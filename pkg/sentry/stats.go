@@ -0,0 +1,65 @@
+package sentry
+
+import (
+	"os"
+	"time"
+)
+
+// Stats summarizes how the monitor has behaved over time
+type Stats struct {
+	StartedAt       time.Time // When this manager was created
+	MonitoringSince time.Time // Start of the current unpaused period; zero while paused
+	HomeSince       time.Time // Start of the current stay on home WiFi; zero when away
+	LastPhoneSeen   time.Time
+	Shutdowns       int // Shutdown countdowns that ran to completion
+	Cancels         int // Shutdown countdowns cancelled by the user
+}
+
+// Stats returns a snapshot of the monitor statistics
+func (s *SentryManager) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		StartedAt:       s.startedAt,
+		MonitoringSince: s.monitoringSince,
+		HomeSince:       s.homeSince,
+		LastPhoneSeen:   s.lastSeen,
+		Shutdowns:       s.shutdownCount,
+		Cancels:         s.cancelCount,
+	}
+}
+
+// LoadStats reads the persisted statistics without starting a manager. Only
+// the long-lived fields (last sighting and counters) are available.
+func LoadStats() (Stats, error) {
+	state, _, err := readStateFile(StateFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Stats{}, nil
+		}
+		return Stats{}, err
+	}
+	return Stats{
+		LastPhoneSeen: state.LastSeen,
+		Shutdowns:     state.ShutdownCount,
+		Cancels:       state.CancelCount,
+	}, nil
+}
+
+// trackPeriods starts or ends the continuous at-home and protected periods
+func (s *SentryManager) trackPeriods(atHome, protected bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !atHome {
+		s.homeSince = time.Time{}
+	} else if s.homeSince.IsZero() {
+		s.homeSince = now
+	}
+
+	if !protected {
+		s.monitoringSince = time.Time{}
+	} else if s.monitoringSince.IsZero() {
+		s.monitoringSince = now
+	}
+}
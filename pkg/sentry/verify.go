@@ -0,0 +1,116 @@
+package sentry
+
+import (
+	"fmt"
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/logger"
+	"home-sentry/pkg/network"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// shutdownCommand builds the command that performs a shutdown action
+func shutdownCommand(action string) *exec.Cmd {
+	switch action {
+	case config.ShutdownActionHibernate:
+		return exec.Command("rundll32.exe", "powrprof.dll,SetSuspendState", "1,1,0")
+	case config.ShutdownActionSleep:
+		return exec.Command("rundll32.exe", "powrprof.dll,SetSuspendState", "0,1,0")
+	case config.ShutdownActionLock:
+		return exec.Command("rundll32.exe", "user32.dll,LockWorkStation")
+	case config.ShutdownActionLogoff:
+		return exec.Command("shutdown", "/l", "/f")
+	default:
+		return exec.Command("shutdown", "/s", "/t", "0")
+	}
+}
+
+// runShutdownAction performs a shutdown action and waits for its command
+func runShutdownAction(action string) error {
+	cmd := shutdownCommand(action)
+	network.HideConsole(cmd)
+	return cmd.Run()
+}
+
+// actionTookEffect reports whether a shutdown action worked. wait sleeps for
+// the verification delay. rundll32 returns without an error whether or not
+// the machine went to sleep or hibernated, so those count only if resumed
+// finds a wake-up after the command ran; still running after a shutdown or
+// logoff means it was refused (privileges, policy, an app blocking it).
+func actionTookEffect(action string, runErr error, wait func(), sessionLocked, resumed func() bool) bool {
+	if runErr != nil {
+		return false
+	}
+	switch action {
+	case config.ShutdownActionSleep, config.ShutdownActionHibernate:
+		wait()
+		return resumed()
+	case config.ShutdownActionLock:
+		wait()
+		return sessionLocked()
+	default:
+		wait()
+		return false
+	}
+}
+
+// verifyShutdown escalates when the shutdown action run at started did not
+// take effect: it raises a local alert and the critical webhook, then tries
+// the fallback action once
+func (s *SentryManager) verifyShutdown(settings config.Settings, runErr error, started time.Time) {
+	if settings.ShutdownVerifySec == 0 {
+		return
+	}
+	action := settings.ShutdownAction
+	wait := func() { time.Sleep(time.Duration(settings.ShutdownVerifySec) * time.Second) }
+	resumed := func() bool { return resumedSince(started) }
+	if actionTookEffect(action, runErr, wait, isSessionLocked, resumed) {
+		return
+	}
+
+	logger.Error("SHUTDOWN FAILED: %s did not take effect", action)
+	s.mu.Lock()
+	ssid := s.currentSSID
+	s.mu.Unlock()
+	s.notify(criticalEvent(settings, "Home Sentry: SHUTDOWN FAILED",
+		fmt.Sprintf("%s did not take effect - the machine is still running", action),
+		settings.ShutdownFailedPayloadTemplate(), config.WebhookStatusShutdownFailed, ssid,
+		formatAbsence(s.absentFor(time.Now()))))
+
+	fallback := settings.ShutdownFallbackAction
+	if fallback == "" || fallback == action {
+		return
+	}
+	logger.Info("Running fallback action %s...", fallback)
+	if err := runShutdownAction(fallback); err != nil {
+		logger.Error("Fallback action %s failed: %v", fallback, err)
+	}
+}
+
+// resumedSince reports whether the System event log has a wake from sleep or
+// hibernate (Power-Troubleshooter event 1) logged after started
+func resumedSince(started time.Time) bool {
+	// Wall-clock time, so the hours spent asleep are counted
+	window := time.Now().Round(0).Sub(started.Round(0)) + time.Second
+	query := fmt.Sprintf("*[System[Provider[@Name='Microsoft-Windows-Power-Troubleshooter'] and EventID=1 and TimeCreated[timediff(@SystemTime) <= %d]]]",
+		window.Milliseconds())
+	cmd := exec.Command("wevtutil", "qe", "System", "/q:"+query, "/c:1", "/f:text")
+	network.HideConsole(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(output)) != ""
+}
+
+// isSessionLocked reports whether the lock screen is showing
+func isSessionLocked() bool {
+	cmd := exec.Command("tasklist", "/FI", "IMAGENAME eq LogonUI.exe", "/NH")
+	network.HideConsole(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(output)), "logonui.exe")
+}
@@ -0,0 +1,47 @@
+package sentry
+
+import (
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/notify"
+	"time"
+)
+
+// watchSettings hands the user's settings to a watch session with sounds
+// silenced and profile switching off; both are left to the tray app
+type watchSettings struct {
+	SettingsProvider
+}
+
+func (w watchSettings) Load() (config.Settings, error) {
+	settings, err := w.SettingsProvider.Load()
+	settings.SilentMode = true
+	settings.AutoSwitchProfile = false
+	return settings, err
+}
+
+// NewWatch creates a monitor for following status changes from a terminal.
+// It checks the real network with the user's settings, starting from the
+// persisted state, but it is a dry run that never writes the state file or
+// the settings and shows no alerts, so it can run next to the tray app.
+// onChange receives the result of every check.
+func NewWatch(onChange func(StatusChange)) *SentryManager {
+	deps := DefaultDependencies()
+	deps.Presence = &networkPresence{readOnly: true}
+	deps.Settings = watchSettings{deps.Settings}
+	deps.Notifier = notify.Discard{}
+	deps.DryRun = true
+
+	sm := &SentryManager{
+		status:         StatusRoaming,
+		cancelShutdown: make(chan struct{}),
+		knownMACs:      make(map[string]bool),
+		stateFile:      StateFilePath(),
+		startedAt:      time.Now(),
+		StatusCallback: onChange,
+		deps:           deps,
+	}
+	sm.onShutdown = sm.triggerShutdownWithCountdown
+	sm.loadState()
+	sm.stateFile = "" // Read the tray's state, never write it
+	return sm
+}
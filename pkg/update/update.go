@@ -0,0 +1,141 @@
+// Package update checks GitHub releases for a newer Home Sentry build.
+// It only informs - nothing is ever downloaded or installed.
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a single release lookup
+const DefaultTimeout = 10 * time.Second
+
+// maxResponseSize caps how much of the API response is read
+const maxResponseSize = 1 << 20
+
+// releasesURL is the GitHub API endpoint for the latest release
+var releasesURL = "https://api.github.com/repos/sushantmoza/home-sentry/releases/latest"
+
+var httpClient = &http.Client{Timeout: DefaultTimeout}
+
+// Release describes a published GitHub release
+type Release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// LatestRelease fetches the most recent published release
+func LatestRelease(ctx context.Context) (Release, error) {
+	var release Release
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return release, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "home-sentry")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return release, fmt.Errorf("release lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return release, fmt.Errorf("release lookup returned status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&release); err != nil {
+		return release, fmt.Errorf("invalid release response: %w", err)
+	}
+	if release.TagName == "" {
+		return release, fmt.Errorf("release response has no tag")
+	}
+	return release, nil
+}
+
+// Check looks up the latest release and reports whether it is newer than current.
+// Development builds (non-semver versions such as "dev") never report an update.
+func Check(ctx context.Context, current string) (Release, bool, error) {
+	release, err := LatestRelease(ctx)
+	if err != nil {
+		return release, false, err
+	}
+	if _, ok := parseVersion(current); !ok {
+		return release, false, nil
+	}
+	return release, CompareVersions(release.TagName, current) > 0, nil
+}
+
+// version is a parsed semantic version
+type version struct {
+	core       [3]int
+	prerelease string
+}
+
+// parseVersion parses "v1.2.3", "1.2" or "1.2.3-beta.1"; build metadata is ignored
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.prerelease = s[i+1:]
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.core[i] = n
+	}
+	return v, true
+}
+
+// CompareVersions returns -1, 0 or 1 when a is older than, equal to or newer
+// than b. Unparseable versions sort before everything else. Pre-releases sort
+// before the matching release, and are otherwise compared as plain strings.
+func CompareVersions(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+
+	for i := range va.core {
+		if va.core[i] != vb.core[i] {
+			if va.core[i] < vb.core[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case va.prerelease == vb.prerelease:
+		return 0
+	case va.prerelease == "":
+		return 1
+	case vb.prerelease == "":
+		return -1
+	}
+	return strings.Compare(va.prerelease, vb.prerelease)
+}
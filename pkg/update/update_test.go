@@ -0,0 +1,80 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.4.0", "1.4.0", 0},
+		{"v1.5.0", "1.4.9", 1},
+		{"1.4.0", "v1.10.0", -1},
+		{"2.0", "1.9.9", 1},
+		{"1.4.0-beta.1", "1.4.0", -1},
+		{"1.4.0", "1.4.0-rc.1", 1},
+		{"1.4.0+build5", "1.4.0", 0},
+		{"dev", "1.0.0", -1},
+		{"1.0.0", "dev", 1},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name":"v1.6.0","html_url":"https://example.com/releases/v1.6.0"}`))
+	}))
+	defer server.Close()
+
+	orig := releasesURL
+	releasesURL = server.URL
+	defer func() { releasesURL = orig }()
+
+	tests := []struct {
+		current string
+		want    bool
+	}{
+		{"1.5.2", true},
+		{"1.6.0", false},
+		{"1.7.0", false},
+		{"dev", false},
+	}
+
+	for _, tt := range tests {
+		release, newer, err := Check(context.Background(), tt.current)
+		if err != nil {
+			t.Fatalf("Check(%q) error = %v", tt.current, err)
+		}
+		if release.TagName != "v1.6.0" {
+			t.Errorf("TagName = %q, want v1.6.0", release.TagName)
+		}
+		if newer != tt.want {
+			t.Errorf("Check(%q) newer = %v, want %v", tt.current, newer, tt.want)
+		}
+	}
+}
+
+func TestCheckErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	orig := releasesURL
+	releasesURL = server.URL
+	defer func() { releasesURL = orig }()
+
+	if _, _, err := Check(context.Background(), "1.0.0"); err == nil {
+		t.Error("Expected error for non-200 response")
+	}
+}
@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds how long a webhook call may take
+const DefaultTimeout = 10 * time.Second
+
+// maxResponseBody limits how much of an error response is kept for logging
+const maxResponseBody = 512
+
+var httpClient = &http.Client{Timeout: DefaultTimeout}
+
+// Render substitutes {placeholder} tokens in the template with the given values.
+// Only the known keys are replaced - no format-string evaluation takes place,
+// so user-controlled values can never inject directives. When the template looks
+// like JSON, values are escaped so they stay inside their JSON strings.
+func Render(template string, values map[string]string) string {
+	isJSON := looksLikeJSON(template)
+
+	pairs := make([]string, 0, len(values)*2)
+	for key, value := range values {
+		if isJSON {
+			value = escapeJSONString(value)
+		}
+		pairs = append(pairs, "{"+key+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// Post sends the payload to the webhook URL. JSON payloads are sent with an
+// application/json content type, anything else as plain text.
+func Post(url, payload string) error {
+	contentType := "text/plain; charset=utf-8"
+	if json.Valid([]byte(payload)) {
+		contentType = "application/json"
+	}
+
+	resp, err := httpClient.Post(url, contentType, bytes.NewBufferString(payload))
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func looksLikeJSON(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")
+}
+
+// escapeJSONString escapes a value for use inside a JSON string literal
+func escapeJSONString(s string) string {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	// Strip the surrounding quotes added by Marshal
+	return string(encoded[1 : len(encoded)-1])
+}
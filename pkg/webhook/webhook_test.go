@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	values := map[string]string{
+		"delay":    "10",
+		"ssid":     `Home"WiFi`,
+		"device":   "aa-bb-cc-dd-ee-ff",
+		"hostname": "LAPTOP",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"plain text", "{hostname} shuts down in {delay}s", "LAPTOP shuts down in 10s"},
+		{"json escapes values", `{"msg":"{ssid}"}`, `{"msg":"Home\"WiFi"}`},
+		{"unknown placeholder untouched", "{device} {unknown}", "aa-bb-cc-dd-ee-ff {unknown}"},
+		{"format verbs not evaluated", "%s {delay} %d", "%s 10 %d"},
+		{"empty template", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Render(tt.template, values)
+			if result != tt.expected {
+				t.Errorf("Render(%q) = %q, want %q", tt.template, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPost(t *testing.T) {
+	var gotBody, gotContentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotContentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if err := Post(server.URL, `{"a":1}`); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if gotBody != `{"a":1}` {
+		t.Errorf("body = %q, want %q", gotBody, `{"a":1}`)
+	}
+	if gotContentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", gotContentType)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	if err := Post(failing.URL, "hello"); err == nil {
+		t.Error("Post() should return error on non-2xx status")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"home-sentry/pkg/config"
+	"home-sentry/pkg/logger"
+	"home-sentry/pkg/network"
+	"home-sentry/pkg/paths"
+	"home-sentry/pkg/sentry"
+	"home-sentry/pkg/startup"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runUninstall removes everything Home Sentry leaves on the machine: the
+// auto-start entry, the encryption key, settings, state, logs and scan
+// dumps. With --purge the whole app-data directory is deleted as well.
+func runUninstall(args []string) {
+	purge := false
+	assumeYes := false
+	for _, arg := range args {
+		switch arg {
+		case "--purge":
+			purge = true
+		case "--yes", "-y":
+			assumeYes = true
+		default:
+			fmt.Println("Usage: home-sentry uninstall [--purge] [--yes]")
+			return
+		}
+	}
+
+	appDir := paths.AppDir()
+
+	fmt.Println("This will remove:")
+	fmt.Println("  - the auto-start entry")
+	fmt.Println("  - the settings encryption key")
+	fmt.Printf("  - settings: %s\n", config.GetSettingsPath())
+	fmt.Printf("  - state:    %s\n", sentry.StateFilePath())
+	fmt.Printf("  - logs:     %s\n", logger.GetLogDir())
+	fmt.Printf("  - scan dumps: %s\n", filepath.Join(logger.GetLogDir(), network.ScanDumpPattern))
+	if purge {
+		fmt.Printf("  - the whole app-data directory: %s\n", appDir)
+	}
+	fmt.Println("Quit any running Home Sentry instance first.")
+
+	if !assumeYes && !confirm("Continue? [y/N]: ") {
+		fmt.Println("Uninstall cancelled.")
+		return
+	}
+
+	var removed, failed []string
+	record := func(what string, err error) {
+		switch {
+		case err == nil:
+			removed = append(removed, what)
+		case errors.Is(err, os.ErrNotExist):
+			// Nothing to remove
+		default:
+			failed = append(failed, fmt.Sprintf("%s: %v", what, err))
+		}
+	}
+
+	if startup.IsEnabled() {
+		record("auto-start entry", startup.Disable())
+	}
+	record("encryption key", config.NewKeyStorage().ClearKey())
+	record("settings file", os.Remove(config.GetSettingsPath()))
+	record("state file", os.Remove(sentry.StateFilePath()))
+
+	// Our own log file is open; close it so it can be deleted
+	logger.Close()
+	logFiles, _ := filepath.Glob(filepath.Join(logger.GetLogDir(), "home-sentry-*.log"))
+	for _, file := range logFiles {
+		record("log "+filepath.Base(file), os.Remove(file))
+	}
+	dumps, _ := filepath.Glob(filepath.Join(logger.GetLogDir(), network.ScanDumpPattern))
+	for _, file := range dumps {
+		record("scan dump "+filepath.Base(file), os.Remove(file))
+	}
+
+	if purge {
+		if _, err := os.Stat(appDir); err == nil {
+			record("app-data directory", os.RemoveAll(appDir))
+		}
+	}
+
+	if len(removed) == 0 {
+		fmt.Println("Nothing to remove.")
+	} else {
+		fmt.Println("Removed:")
+		for _, item := range removed {
+			fmt.Println("  - " + item)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Println("Could not remove:")
+		for _, item := range failed {
+			fmt.Println("  - " + config.SanitizeDisplayString(item))
+		}
+		os.Exit(1)
+	}
+}
+
+// confirm asks a yes/no question on stdin; anything but y/yes means no
+func confirm(prompt string) bool {
+	answer := strings.ToLower(readLine(prompt))
+	return answer == "y" || answer == "yes"
+}
+
+// readLine prints prompt and returns the trimmed line typed in reply, or ""
+// if stdin could not be read
+func readLine(prompt string) string {
+	fmt.Print(prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(answer)
+}